@@ -0,0 +1,340 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// Subcommands and replies of AccelStepperData.
+const (
+	accelConfig       = 0x00
+	accelZero         = 0x01
+	accelStep         = 0x02
+	accelTo           = 0x03
+	accelEnable       = 0x04
+	accelStop         = 0x05
+	accelReport       = 0x06
+	accelAcceleration = 0x08
+	accelSpeed        = 0x09
+	accelMoveComplete = 0x0A
+	multiConfig       = 0x20
+	multiTo           = 0x21
+	multiStop         = 0x23
+	multiMoveComplete = 0x24
+)
+
+// MaxAccelSteppers is the number of steppers AccelStepper drives and
+// MaxStepperGroups the number of MultiStepper groups.
+const (
+	MaxAccelSteppers = 10
+	MaxStepperGroups = 5
+)
+
+// StepSize is the step size of a stepper driven by AccelStepper.
+type StepSize byte
+
+const (
+	WholeStep   StepSize = 0x00
+	HalfStep    StepSize = 0x01
+	QuarterStep StepSize = 0x02
+)
+
+// AccelStepperConfig describes a stepper motor for the AccelStepper
+// feature.
+type AccelStepperConfig struct {
+	// Device is the number of the motor, from 0 to MaxAccelSteppers-1.
+	Device int
+
+	Interface StepperInterface
+	StepSize  StepSize
+
+	// Pins are the step and direction pins of a driver, or the motor
+	// pins of a two, three or four wire stepper.
+	Pins []uint8
+
+	// Enable, when set, drives EnablePin to enable the motor.
+	Enable    bool
+	EnablePin uint8
+
+	// Invert is a bit mask of the pins to invert: bits 0 to 3 for
+	// Pins, bit 4 for EnablePin.
+	Invert byte
+}
+
+// AccelStepper is a stepper motor driven by the AccelStepper feature,
+// which moves to positions with acceleration.
+type AccelStepper struct {
+	c      *Client
+	Device int
+}
+
+// AccelStepperEvent is published when an AccelStepper completed a move
+// or stopped.
+type AccelStepperEvent struct {
+	Header
+	Device   int
+	Position int
+}
+
+// MultiStepper is a group of AccelSteppers whose moves are coordinated
+// so that they all arrive at the same time, as the axes of a plotter.
+type MultiStepper struct {
+	c        *Client
+	Group    int
+	Steppers []*AccelStepper
+}
+
+// MultiStepperEvent is published when the steppers of a group completed
+// a coordinated move or stopped.
+type MultiStepperEvent struct {
+	Header
+	Group int
+}
+
+// AccelStepper sets up the stepper motor described by cfg.
+func (c *Client) AccelStepper(cfg AccelStepperConfig) (*AccelStepper, error) {
+	if cfg.Device < 0 || cfg.Device >= MaxAccelSteppers {
+		return nil, fmt.Errorf("invalid stepper device %d", cfg.Device)
+	}
+	pins := int(cfg.Interface)
+	if cfg.Interface == StepperDriver {
+		pins = 2
+	}
+	if pins < 2 || pins > 4 {
+		return nil, fmt.Errorf("invalid stepper interface %d", cfg.Interface)
+	}
+	if len(cfg.Pins) != pins {
+		return nil, fmt.Errorf("stepper interface %d needs %d pins, got %d", cfg.Interface, pins, len(cfg.Pins))
+	}
+	if cfg.StepSize > QuarterStep {
+		return nil, fmt.Errorf("invalid step size %d", cfg.StepSize)
+	}
+	all := cfg.Pins
+	if cfg.Enable {
+		all = append(all[:len(all):len(all)], cfg.EnablePin)
+	}
+	iface := byte(cfg.Interface)<<4 | byte(cfg.StepSize)<<1
+	if cfg.Enable {
+		iface |= 1
+	}
+	data := []byte{accelConfig, byte(cfg.Device), iface}
+	for _, pin := range all {
+		if err := c.checkPin(int(pin)); err != nil {
+			return nil, err
+		}
+		data = append(data, pin)
+	}
+	if cfg.Invert != 0 {
+		data = append(data, cfg.Invert&0x1F)
+	}
+	m := wire.SysEx{Command: byte(AccelStepperData), Data: data}
+	if err := c.sendConfig(fmt.Sprintf("accelstepper/%d", cfg.Device), m); err != nil {
+		return nil, err
+	}
+	for _, pin := range cfg.Pins {
+		c.modeSet(pin, Stepper)
+	}
+	return &AccelStepper{c: c, Device: cfg.Device}, nil
+}
+
+// Zero makes the current position of the stepper its zero position.
+func (s *AccelStepper) Zero() error {
+	return s.send(accelZero)
+}
+
+// Move moves the stepper by steps relative to its current position. An
+// AccelStepperEvent is published when the move completes.
+func (s *AccelStepper) Move(steps int) error {
+	v, err := encodeSteps(steps)
+	if err != nil {
+		return err
+	}
+	return s.send(accelStep, v...)
+}
+
+// MoveTo moves the stepper to an absolute position. An
+// AccelStepperEvent is published when the move completes.
+func (s *AccelStepper) MoveTo(pos int) error {
+	v, err := encodeSteps(pos)
+	if err != nil {
+		return err
+	}
+	return s.send(accelTo, v...)
+}
+
+// Enable turns the outputs of the stepper on or off.
+func (s *AccelStepper) Enable(on bool) error {
+	return s.send(accelEnable, boolByte(on))
+}
+
+// Stop decelerates the stepper to a stop. An AccelStepperEvent with the
+// final position is published when it stopped.
+func (s *AccelStepper) Stop() error {
+	return s.send(accelStop)
+}
+
+// SetAcceleration sets the acceleration and deceleration of the stepper
+// in steps per second squared. Zero disables acceleration.
+func (s *AccelStepper) SetAcceleration(accel float64) error {
+	v, err := encodeAccelFloat(accel)
+	if err != nil {
+		return err
+	}
+	return s.send(accelAcceleration, v...)
+}
+
+// SetSpeed sets the speed of the stepper in steps per second, or the
+// maximum speed if acceleration is enabled.
+func (s *AccelStepper) SetSpeed(speed float64) error {
+	v, err := encodeAccelFloat(speed)
+	if err != nil {
+		return err
+	}
+	return s.send(accelSpeed, v...)
+}
+
+// Position asks the board for the current position of the stepper.
+func (s *AccelStepper) Position(ctx context.Context) (int, error) {
+	v, err := s.c.query(ctx, queryKey{AccelStepperData, s.Device}, func() error {
+		return s.send(accelReport)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.(int), nil
+}
+
+func (s *AccelStepper) send(sub byte, args ...byte) error {
+	data := append([]byte{sub, byte(s.Device)}, args...)
+	return s.c.sendSysEx(AccelStepperData, data...)
+}
+
+// MultiStepper groups steppers, from 2 to MaxAccelSteppers of them,
+// for coordinated moves. Group is from 0 to MaxStepperGroups-1.
+func (c *Client) MultiStepper(group int, steppers ...*AccelStepper) (*MultiStepper, error) {
+	if group < 0 || group >= MaxStepperGroups {
+		return nil, fmt.Errorf("invalid stepper group %d", group)
+	}
+	if len(steppers) < 2 || len(steppers) > MaxAccelSteppers {
+		return nil, fmt.Errorf("a stepper group needs 2 to %d steppers, got %d", MaxAccelSteppers, len(steppers))
+	}
+	data := []byte{multiConfig, byte(group)}
+	for _, s := range steppers {
+		data = append(data, byte(s.Device))
+	}
+	m := wire.SysEx{Command: byte(AccelStepperData), Data: data}
+	if err := c.sendConfig(fmt.Sprintf("multistepper/%d", group), m); err != nil {
+		return nil, err
+	}
+	return &MultiStepper{c: c, Group: group, Steppers: steppers}, nil
+}
+
+// MoveTo moves the steppers of the group to absolute positions, one per
+// stepper in the order of the group, at the speed of the slowest. A
+// MultiStepperEvent is published when they all arrived.
+func (g *MultiStepper) MoveTo(positions ...int) error {
+	if len(positions) != len(g.Steppers) {
+		return fmt.Errorf("stepper group %d needs %d positions, got %d", g.Group, len(g.Steppers), len(positions))
+	}
+	data := []byte{multiTo, byte(g.Group)}
+	for _, pos := range positions {
+		v, err := encodeSteps(pos)
+		if err != nil {
+			return err
+		}
+		data = append(data, v...)
+	}
+	return g.c.sendSysEx(AccelStepperData, data...)
+}
+
+// Stop stops the steppers of the group immediately.
+func (g *MultiStepper) Stop() error {
+	return g.c.sendSysEx(AccelStepperData, multiStop, byte(g.Group))
+}
+
+func (c *Client) parseAccelStepper(data []byte) {
+	if len(data) >= 2 && data[0] == multiMoveComplete {
+		c.bus.publish(MultiStepperEvent{Header{c.eventTime()}, int(data[1])})
+		return
+	}
+	if len(data) < 7 {
+		return
+	}
+	dev, pos := int(data[1]), decodeSteps(data[2:7])
+	switch data[0] {
+	case accelReport:
+		c.pending.resolve(queryKey{AccelStepperData, dev}, pos)
+	case accelMoveComplete:
+		c.bus.publish(AccelStepperEvent{Header{c.eventTime()}, dev, pos})
+	}
+}
+
+// encodeSteps encodes a step count or position as a sign and a 31-bit
+// magnitude in five 7-bit bytes.
+func encodeSteps(n int) ([]byte, error) {
+	if n <= -1<<31 || n >= 1<<31 {
+		return nil, fmt.Errorf("stepper position %d out of range", n)
+	}
+	neg := n < 0
+	if neg {
+		n = -n
+	}
+	b := wire.EncodeUint(uint64(n), 5)
+	if neg {
+		b[4] |= 0x08
+	}
+	return b, nil
+}
+
+func decodeSteps(b []byte) int {
+	n := int(wire.DecodeUint(b[:4])) | int(b[4]&0x07)<<28
+	if b[4]&0x08 != 0 {
+		n = -n
+	}
+	return n
+}
+
+// encodeAccelFloat encodes x in the four byte float format of
+// AccelStepper: a 23-bit significand, a decimal exponent from -11 to 4
+// and a sign.
+func encodeAccelFloat(x float64) ([]byte, error) {
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return nil, errors.New("firmata: invalid stepper value")
+	}
+	var sign uint64
+	if x < 0 {
+		sign, x = 1, -x
+	}
+	for exp := -11; exp <= 4; exp++ {
+		sig := math.Round(x / math.Pow10(exp))
+		if sig < 1<<23 {
+			return wire.EncodeUint(uint64(sig)|uint64(exp+11)<<23|sign<<27, 4), nil
+		}
+	}
+	return nil, fmt.Errorf("stepper value %v out of range", x)
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,142 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// Resolution returns the resolution in bits the board reports for pin
+// in mode, or 0 if the pin doesn't support the mode.
+func (c *Client) Resolution(pin uint8, mode PinMode) int {
+	res, ok := c.board().mode(int(pin), mode).(byte)
+	if !ok {
+		return 0
+	}
+	return int(res)
+}
+
+// AnalogWriteValue writes value to a PWM, servo or DAC pin. Unlike
+// AnalogWrite, value may use the full resolution of the pin, which is
+// checked against the resolution the board reports for the current
+// mode of the pin. DAC pins, pins above 15 and values above 14 bits are
+// written with an extended analog message.
+func (c *Client) AnalogWriteValue(pin uint8, value int) error {
+	if err := c.checkPin(int(pin)); err != nil {
+		return err
+	}
+	c.stateMu.Lock()
+	mode, ok := c.modes[pin]
+	c.stateMu.Unlock()
+	if ok && mode != Servo {
+		// Servo "resolution" is the width of the pulse, not a range
+		// of values, so servo angles are not checked.
+		if res := c.Resolution(pin, mode); res > 0 && value >= 1<<uint(res) {
+			return fmt.Errorf("value %d exceeds the %d-bit resolution of pin %v", value, res, pin)
+		}
+	}
+	if value < 0 {
+		return fmt.Errorf("negative value %d", value)
+	}
+	return c.analogWrite(pin, value, ok && mode == DAC)
+}
+
+// analogWrite writes value with an analog message if possible and an
+// extended analog message otherwise or if extended is set.
+func (c *Client) analogWrite(pin uint8, value int, extended bool) error {
+	var err error
+	if extended || pin > 15 || value >= 1<<14 {
+		err = c.extendedAnalogWrite(pin, value)
+	} else {
+		err = c.send(wire.Analog{Channel: pin, Value: uint16(value)})
+	}
+	if err != nil {
+		return err
+	}
+	c.stateMu.Lock()
+	c.recordOutput(pin, value)
+	c.stateMu.Unlock()
+	return nil
+}
+
+// extendedAnalogWrite sends value as 7-bit bytes, LSB first, in an
+// EXTENDED_ANALOG message.
+func (c *Client) extendedAnalogWrite(pin uint8, value int) error {
+	data := append([]byte{pin & 0x7F}, wire.EncodeUint(uint64(value), 0)...)
+	return c.sendSysEx(ExtendedAnalog, data...)
+}
+
+// ReadAllAnalog reads one sample of every analog input, keyed by pin.
+// Reporting is enabled for the channels that don't report yet and
+// disabled again once they were sampled, so polling programs don't have
+// to handle a continuous stream. Pins used as digital pins are skipped.
+// If some pins are not sampled within timeout, the samples read so far
+// are returned with an error wrapping ErrTimeout.
+func (c *Client) ReadAllAnalog(timeout time.Duration) (map[int]int, error) {
+	samples := Subscribe[AnalogEvent](c, Filter{})
+	defer Unsubscribe(c, samples)
+
+	want := make(map[int]bool)
+	var enabled []uint
+	defer func() {
+		for _, pin := range enabled {
+			c.EnableAnalogInput(pin, false)
+		}
+	}()
+	for pin, ch := range c.board().channels {
+		if ch > 15 || !c.UsedAsAnalog(pin) {
+			continue
+		}
+		want[pin] = true
+		c.stateMu.Lock()
+		reporting := c.analogReporting[ch]
+		c.stateMu.Unlock()
+		if reporting {
+			continue
+		}
+		if err := c.EnableAnalogInput(uint(pin), true); err != nil {
+			return nil, err
+		}
+		enabled = append(enabled, uint(pin))
+	}
+
+	values := make(map[int]int, len(want))
+	deadline := c.clock.After(timeout)
+	for len(values) < len(want) {
+		select {
+		case ev, ok := <-samples:
+			if !ok {
+				return values, fmt.Errorf("firmata: connection closed while reading analog inputs")
+			}
+			if want[ev.Pin] {
+				values[ev.Pin] = ev.Value
+			}
+		case <-deadline:
+			var missing []int
+			for pin := range want {
+				if _, ok := values[pin]; !ok {
+					missing = append(missing, pin)
+				}
+			}
+			sort.Ints(missing)
+			return values, fmt.Errorf("firmata: no sample from pins %v: %w", missing, ErrTimeout)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import "fmt"
+
+// BoardState is a desired configuration of the board. Pins missing from
+// a map are left as they are.
+type BoardState struct {
+	Modes map[uint8]PinMode
+
+	// Outputs holds the levels, 0 or 1, of digital outputs and the
+	// values of PWM, servo and DAC pins, which are checked against the
+	// resolution of the pin.
+	Outputs map[uint8]int
+
+	// Reporting enables or disables the reporting of input pins.
+	// Digital inputs are reported by port, so enabling one pin enables
+	// its port.
+	Reporting map[uint8]bool
+
+	// Pipelines sets the pipelines of analog pins, see SetPipeline. A
+	// pipeline with the same stages as the current one is kept, along
+	// with the state of its filters.
+	Pipelines map[uint8]Pipeline
+}
+
+// Apply brings the board to the desired state, sending only the
+// commands needed to change what differs from the state the client
+// last set. Calling it repeatedly with the same state is cheap, so it
+// can be used from control loops. Mode changes go through Reconfigure.
+func (c *Client) Apply(desired BoardState) error {
+	for pin, mode := range desired.Modes {
+		c.stateMu.Lock()
+		cur, ok := c.modes[pin]
+		c.stateMu.Unlock()
+		if ok && cur == mode {
+			continue
+		}
+		if err := c.Reconfigure(pin, mode); err != nil {
+			return fmt.Errorf("pin %d: %v", pin, err)
+		}
+	}
+
+	for pin, v := range desired.Outputs {
+		c.stateMu.Lock()
+		cur, ok := c.outputs[pin]
+		mode := c.modes[pin]
+		c.stateMu.Unlock()
+		if ok && cur == v {
+			continue
+		}
+		var err error
+		switch mode {
+		case Output:
+			err = c.DigitalWrite(pin, v != 0)
+		case PWM, Servo, DAC:
+			err = c.AnalogWriteValue(pin, v)
+		default:
+			err = fmt.Errorf("mode %v is not an output", mode)
+		}
+		if err != nil {
+			return fmt.Errorf("pin %d: %v", pin, err)
+		}
+	}
+
+	for pin, enable := range desired.Reporting {
+		ch, analog := c.board().channels[int(pin)]
+		c.stateMu.Lock()
+		mode := c.modes[pin]
+		var cur bool
+		if mode == Analog && analog && ch < 16 {
+			cur = c.analogReporting[ch]
+		} else if pin/8 < 16 {
+			cur = c.digitalReporting[pin/8]
+		}
+		c.stateMu.Unlock()
+		if cur == enable {
+			continue
+		}
+		var err error
+		if mode == Analog {
+			err = c.EnableAnalogInput(uint(pin), enable)
+		} else {
+			err = c.EnableDigitalInput(uint(pin), enable)
+		}
+		if err != nil {
+			return fmt.Errorf("pin %d: %v", pin, err)
+		}
+	}
+
+	for pin, p := range desired.Pipelines {
+		if cur, ok := c.Pipeline(int(pin)); ok && cur.equal(p) {
+			continue
+		}
+		c.SetPipeline(int(pin), p)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// settle waits for the board to handle what the client sent so far and
+// returns the messages sent before.
+func settle(t *testing.T, c *firmata.Client, r *recorder) []wire.Message {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := c.QueryPinState(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	msgs := r.messages()
+	return msgs[:len(msgs)-1] // the pin state query
+}
+
+func TestApply(t *testing.T) {
+	r := newRecorder()
+	c, err := firmata.NewClientConn(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	settle(t, c, r)
+
+	state := firmata.BoardState{
+		Modes:     map[uint8]firmata.PinMode{13: firmata.Output, 2: firmata.Input},
+		Outputs:   map[uint8]int{13: 1},
+		Reporting: map[uint8]bool{2: true},
+	}
+	if err := c.Apply(state); err != nil {
+		t.Fatal(err)
+	}
+	if msgs := settle(t, c, r); len(msgs) == 0 {
+		t.Fatal("Apply sent nothing")
+	}
+	if r.Mode(13) != firmata.Output || r.Output(13) != 1 || r.Mode(2) != firmata.Input {
+		t.Errorf("board pins 13 and 2 = %v %d, %v", r.Mode(13), r.Output(13), r.Mode(2))
+	}
+
+	// Applying the same state again sends nothing.
+	if err := c.Apply(state); err != nil {
+		t.Fatal(err)
+	}
+	if msgs := settle(t, c, r); len(msgs) != 0 {
+		t.Errorf("second Apply sent %v", msgs)
+	}
+
+	state.Outputs[13] = 0
+	if err := c.Apply(state); err != nil {
+		t.Fatal(err)
+	}
+	if msgs := settle(t, c, r); len(msgs) != 1 {
+		t.Errorf("Apply of a new output sent %v; want a single write", msgs)
+	}
+	if r.Output(13) != 0 {
+		t.Error("pin 13 still high")
+	}
+
+	if err := c.Apply(firmata.BoardState{Outputs: map[uint8]int{2: 1}}); err == nil {
+		t.Error("Apply wrote to an input pin")
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import "errors"
+
+// ErrLikelyBaudMismatch is returned when the handshake fails while the
+// board sends mostly invalid bytes, which is what a wrong baud rate
+// looks like.
+var ErrLikelyBaudMismatch = errors.New("firmata: no valid messages from the board, the baud rate is likely wrong")
+
+// CandidateBauds are the baud rates tried by NewClientAnyBaud when none
+// are given, most common first.
+var CandidateBauds = []int{57600, 115200, 9600}
+
+// likelyBaudMismatch reports whether the bytes received so far are
+// mostly not Firmata.
+func (c *Client) likelyBaudMismatch() bool {
+	s := c.Stats()
+	return s.Skipped >= 16 && s.Skipped > 4*s.Messages
+}
@@ -0,0 +1,142 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// capabilityCache stores capability and analog mapping responses in a
+// directory, keyed by firmware name, version and an optional board id.
+type capabilityCache struct {
+	dir string
+	id  string
+}
+
+type cachedBoard struct {
+	PinModes       []map[PinMode]byte `json:"pin_modes"`
+	AnalogChannels map[int]byte       `json:"analog_channels"`
+}
+
+// WithCapabilityCache caches the capabilities of boards in dir. id
+// distinguishes boards running the same firmware, such as the USB
+// serial number, and may be empty. When the cache knows the firmware
+// reported by the board, the handshake completes right away and the
+// capabilities are refreshed in the background.
+func WithCapabilityCache(dir, id string) Option {
+	return func(c *Client) {
+		c.cache = &capabilityCache{dir: dir, id: id}
+	}
+}
+
+func (cc *capabilityCache) path(name string, major, minor int) string {
+	key := fmt.Sprintf("%s-%d.%d", name, major, minor)
+	if cc.id != "" {
+		key += "-" + cc.id
+	}
+	key = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, key)
+	return filepath.Join(cc.dir, key+".json")
+}
+
+func (cc *capabilityCache) load(name string, major, minor int) (*cachedBoard, bool) {
+	data, err := os.ReadFile(cc.path(name, major, minor))
+	if err != nil {
+		return nil, false
+	}
+	var b cachedBoard
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, false
+	}
+	return &b, true
+}
+
+func (cc *capabilityCache) store(name string, major, minor int, b *cachedBoard) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cc.dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(cc.path(name, major, minor), data, 0644)
+}
+
+// loadCachedCapabilities fills the pin modes and analog mapping from the
+// cache. It reports whether the firmware was found.
+func (c *Client) loadCachedCapabilities() bool {
+	b, ok := c.cache.load(c.firmwareName, c.firmwareVersion[0], c.firmwareVersion[1])
+	if !ok {
+		return false
+	}
+	modes := make([]map[PinMode]interface{}, len(b.PinModes))
+	for pin, m := range b.PinModes {
+		modes[pin] = make(map[PinMode]interface{}, len(m))
+		for mode, res := range m {
+			modes[pin][mode] = res
+		}
+	}
+	c.setBoard(func(next *boardPins) {
+		next.modes = modes
+		next.setAnalogMapping(b.AnalogChannels)
+	})
+	c.cached = b
+	c.capabilityDone = true
+	c.analogMappingDone = true
+	return true
+}
+
+// refreshCache compares the capabilities and analog mapping the board
+// reported with the cached ones, and stores them if they differ. A
+// WarningEvent is published when the board no longer matches its cache
+// entry.
+func (c *Client) refreshCache() error {
+	if len(c.firmwareVersion) < 2 {
+		// The board has not reported its firmware yet.
+		return nil
+	}
+	pins := c.board()
+	b := &cachedBoard{
+		PinModes:       make([]map[PinMode]byte, len(pins.modes)),
+		AnalogChannels: make(map[int]byte, len(pins.channels)),
+	}
+	for pin, modes := range pins.modes {
+		b.PinModes[pin] = make(map[PinMode]byte, len(modes))
+		for mode, res := range modes {
+			r, _ := res.(byte)
+			b.PinModes[pin][mode] = r
+		}
+	}
+	for pin, ch := range pins.channels {
+		b.AnalogChannels[pin] = ch
+	}
+	if c.cached != nil && reflect.DeepEqual(c.cached, b) {
+		return nil
+	}
+	if c.cached != nil {
+		c.bus.publish(WarningEvent{Header{c.clock.Now()}, "board capabilities differ from the cache, the cache is updated"})
+	}
+	c.cached = b
+	return c.cache.store(c.firmwareName, c.firmwareVersion[0], c.firmwareVersion[1], b)
+}
@@ -0,0 +1,192 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"fmt"
+	"sync"
+)
+
+// defaultCallbackWorkers is the number of goroutines running callbacks
+// unless set with WithCallbackWorkers.
+const defaultCallbackWorkers = 4
+
+// WithCallbackWorkers sets the number of goroutines that run the
+// callbacks registered with OnEvent.
+func WithCallbackWorkers(n int) Option {
+	return func(c *Client) {
+		c.workers.size = n
+	}
+}
+
+// callbackSub queues the events of a callback subscription. The events
+// of one subscription are handled one at a time, in order.
+type callbackSub struct {
+	fn func(Event)
+
+	mu      sync.Mutex
+	queue   []Event
+	running bool
+}
+
+// push queues ev and reports false if the queue is full. It reports
+// whether the subscription has to be scheduled.
+func (s *callbackSub) push(ev Event) (ok, schedule bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) >= subscriptionBuffer {
+		return false, false
+	}
+	s.queue = append(s.queue, ev)
+	if s.running {
+		return true, false
+	}
+	s.running = true
+	return true, true
+}
+
+// run handles up to a batch of queued events and reports whether more
+// are left.
+func (s *callbackSub) run() bool {
+	for i := 0; i < 16; i++ {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			s.running = false
+			s.mu.Unlock()
+			return false
+		}
+		ev := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+		s.fn(ev)
+	}
+	return true
+}
+
+// workerPool runs callback subscriptions on a bounded number of
+// goroutines. A subscription is in the ready list at most once, so one
+// slow callback occupies at most one worker.
+type workerPool struct {
+	size int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	ready   []*callbackSub
+	started bool
+	closed  bool
+}
+
+func (p *workerPool) schedule(s *callbackSub) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	if !p.started {
+		p.started = true
+		p.cond = sync.NewCond(&p.mu)
+		n := p.size
+		if n < 1 {
+			n = defaultCallbackWorkers
+		}
+		for i := 0; i < n; i++ {
+			go p.work()
+		}
+	}
+	p.ready = append(p.ready, s)
+	p.cond.Signal()
+}
+
+func (p *workerPool) work() {
+	for {
+		p.mu.Lock()
+		for len(p.ready) == 0 && !p.closed {
+			p.cond.Wait()
+		}
+		if p.closed {
+			p.mu.Unlock()
+			return
+		}
+		s := p.ready[0]
+		p.ready = p.ready[1:]
+		p.mu.Unlock()
+		if s.run() {
+			// Let other subscriptions run before the rest of the batch.
+			p.mu.Lock()
+			p.ready = append(p.ready, s)
+			p.cond.Signal()
+			p.mu.Unlock()
+		}
+	}
+}
+
+// backlog returns the number of subscriptions waiting for a worker.
+func (p *workerPool) backlog() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.ready)
+}
+
+func (p *workerPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	if p.cond != nil {
+		p.cond.Broadcast()
+	}
+}
+
+// OnEvent calls fn with the events of type T that match filter and
+// returns a function that stops the calls. Callbacks run on a pool of
+// worker goroutines, never on the goroutine reading from the board, and
+// the events of one callback are delivered in order. Events are dropped
+// while a callback is too far behind. A panicking callback is reported
+// as an ErrorEvent. Pooled event payloads such as I2CEvent.Data are
+// released when fn returns and must be copied to be kept.
+func OnEvent[T Event](c *Client, filter Filter, fn func(T)) (cancel func()) {
+	s := &callbackSub{fn: func(ev Event) {
+		defer func() {
+			if r := recover(); r != nil {
+				c.bus.publish(ErrorEvent{Header{c.clock.Now()}, fmt.Errorf("event callback: %v", r)})
+			}
+		}()
+		defer releaseEvent(ev)
+		fn(ev.(T))
+	}}
+	c.bus.add(&subscription{
+		key:    s,
+		filter: filter,
+		deliver: func(ev Event) bool {
+			if _, ok := eventOf(ev).(T); !ok {
+				return true
+			}
+			ev = eventOf(ev)
+			retainEvent(ev)
+			ok, schedule := s.push(ev)
+			if !ok {
+				releaseEvent(ev)
+			}
+			if schedule {
+				c.workers.schedule(s)
+			}
+			return ok
+		},
+		close: func() {},
+	})
+	var once sync.Once
+	return func() {
+		once.Do(func() { c.bus.remove(s) })
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"bytes"
+	"sort"
+)
+
+// PinCapability lists the modes a pin supports.
+type PinCapability struct {
+	Pin int
+
+	// Modes maps each supported mode to its resolution in bits, or to
+	// the width of the pulse for Servo.
+	Modes map[PinMode]int
+
+	// AnalogChannel is the analog channel of the pin, or -1 if the pin
+	// is not an analog input.
+	AnalogChannel int
+}
+
+// Supports reports whether the pin supports mode.
+func (p PinCapability) Supports(mode PinMode) bool {
+	_, ok := p.Modes[mode]
+	return ok
+}
+
+// SortedModes returns the supported modes in increasing order.
+func (p PinCapability) SortedModes() []PinMode {
+	modes := make([]PinMode, 0, len(p.Modes))
+	for mode := range p.Modes {
+		modes = append(modes, mode)
+	}
+	sort.Slice(modes, func(i, j int) bool { return modes[i] < modes[j] })
+	return modes
+}
+
+// Capabilities returns the modes supported by each pin of the board, as
+// reported at connection time or by QueryCapabilities. Pins without any
+// mode, such as those used by the serial port, are included.
+func (c *Client) Capabilities() []PinCapability {
+	b := c.board()
+	caps := make([]PinCapability, len(b.modes))
+	for pin, modes := range b.modes {
+		p := PinCapability{Pin: pin, Modes: make(map[PinMode]int, len(modes)), AnalogChannel: -1}
+		for mode, res := range modes {
+			r, _ := res.(byte)
+			p.Modes[mode] = int(r)
+		}
+		if ch, ok := b.channels[pin]; ok {
+			p.AnalogChannel = int(ch)
+		}
+		caps[pin] = p
+	}
+	return caps
+}
+
+// boardPins is what the board reported about its pins. A published
+// boardPins is never modified; the reader replaces it as a whole.
+type boardPins struct {
+	modes    []map[PinMode]interface{}
+	channels map[int]byte // analog channel by pin
+	pins     map[byte]int // pin by analog channel
+}
+
+// board returns the pins the board reported so far.
+func (c *Client) board() *boardPins {
+	c.capsMu.RLock()
+	defer c.capsMu.RUnlock()
+	if c.caps == nil {
+		return &boardPins{}
+	}
+	return c.caps
+}
+
+// setBoard publishes the pins changed by update, which is given a copy
+// of the current ones.
+func (c *Client) setBoard(update func(b *boardPins)) {
+	c.capsMu.Lock()
+	defer c.capsMu.Unlock()
+	var b boardPins
+	if c.caps != nil {
+		b = *c.caps
+	}
+	update(&b)
+	c.caps = &b
+}
+
+// setAnalogMapping replaces the analog channels by those of mapping,
+// which gives the channel of each pin or 127 for none.
+func (b *boardPins) setAnalogMapping(mapping map[int]byte) {
+	b.channels = make(map[int]byte)
+	b.pins = make(map[byte]int)
+	for pin, channel := range mapping {
+		if channel != 127 {
+			b.channels[pin] = channel
+			b.pins[channel] = pin
+		}
+	}
+}
+
+// mode returns the resolution of pin in mode, or nil if the pin doesn't
+// exist or doesn't support the mode.
+func (b *boardPins) mode(pin int, mode PinMode) interface{} {
+	if pin < 0 || pin >= len(b.modes) {
+		return nil
+	}
+	return b.modes[pin][mode]
+}
+
+func (b *boardPins) supports(pin int, mode PinMode) bool {
+	return b.mode(pin, mode) != nil
+}
+
+// parseCapabilities decodes a capability response: for each pin, pairs
+// of mode and resolution ended by 127. Pins without modes keep their
+// place with an empty map.
+func parseCapabilities(data []byte) []map[PinMode]interface{} {
+	pins := make([]map[PinMode]interface{}, 0)
+	for {
+		end := bytes.IndexByte(data, 127)
+		if end < 0 {
+			return pins
+		}
+		modes := make(map[PinMode]interface{})
+		for i := 0; i+1 < end; i += 2 {
+			modes[PinMode(data[i])] = data[i+1]
+		}
+		pins = append(pins, modes)
+		data = data[end+1:]
+	}
+}
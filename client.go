@@ -15,159 +15,429 @@
 package firmata
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/tarm/serial"
+	"github.com/rakyll/go-firmata/wire"
 )
 
 // Arduino Firmata client for golang
 type Client struct {
-	dev  string
-	baud int
-	conn io.ReadWriteCloser
+	dev   string
+	baud  int
+	clock Clock
+
+	connMu       sync.Mutex // guards conn and serializes writes
+	lanes        writeLanes
+	conn         io.ReadWriteCloser
+	connGen      int // incremented when conn is replaced
+	closing      bool
+	closed       chan struct{} // closed by Close
+	writeTimeout time.Duration
+	stuckWrite   chan struct{} // closed when a timed out write ends
+	dial         func() (io.ReadWriteCloser, error)
+	backoff      *Backoff
+	standby      func() (io.ReadWriteCloser, error)
+	onStandby    bool // conn was opened by standby
 
 	protocolVersion []byte
 	firmwareVersion []int
+	versionQueried  atomic.Bool // a version or firmware query awaits its reply
+	firmwareQueried atomic.Bool // a firmware query awaits its report
+	resetSuspected  atomic.Bool // an unsolicited version report awaits corroboration
+	replaying       atomic.Bool
 	firmwareName    string
 
 	analogMappingDone bool
 	capabilityDone    bool
+	inited            chan struct{}
+	done              chan struct{}
+	readErr           error
+	initOnce          sync.Once
+	cache             *capabilityCache
+
+	portMu            sync.Mutex // serializes digital port writes
+	digitalPinState   [16]byte   // guarded by stateMu
+	digitalInputState Ports      // guarded by stateMu
 
-	digitalPinState [8]byte
+	stateMu          sync.Mutex
+	modes            map[uint8]PinMode
+	digitalReporting [16]bool
+	initialPorts     [16]bool // ports awaiting their first report
+	analogReporting  [16]bool
+	outputs          map[uint8]int // last value written to output pins
+	encoderPins      map[uint8]int // encoder attached to each pin
+	slopes           map[int]*slopeState
+	safeStates       map[uint8]bool
+	critical         map[uint8]bool
+	criticalRetries  *int
+	keepAlive        bool
+	emulation        emulation
+	rediscover       bool
+	stamps           stamper
+	i2cStreams       map[byte][]chan []byte // continuous reads by address
+	autoPWM          bool
 
-	analogPinsChannelMap map[int]byte
-	analogChannelPinsMap map[byte]int
-	pinModes             []map[PinMode]interface{}
+	capsMu          sync.RWMutex
+	caps            *boardPins   // replaced, never modified, by the reader
+	cached          *cachedBoard // last cache entry loaded or stored
+	capsReported    bool         // the board answered the capability query
+	mappingReported bool         // the board answered the analog mapping query
 
+	bus        bus
+	workers    workerPool
+	valuesOnce sync.Once
 	valueChan  chan FirmataValue
-	serialChan chan string
-	spiChan    chan []byte
-}
+	diagOnce   sync.Once
+	diagChan   <-chan DiagnosticEvent
+	serialOnce sync.Once
+	serialChan <-chan string
 
-// NewClient creates a new Client and connects to the Arduino board
-// over specified serial port. It blocks till a connection is
-// succesfully established and pin mappings are retrieved.
-func NewClient(dev string, baud int) (*Client, error) {
-	c := &serial.Config{Name: dev, Baud: baud}
-	conn, err := serial.OpenPort(c)
-	if err != nil {
-		return nil, err
-	}
+	encoderOnce sync.Once
+	encoderChan <-chan EncoderEvent
+	stringOnce  sync.Once
+	stringChan  <-chan StringEvent
+
+	pending pendingQueries
+	journal journal
+
+	limits      wire.Limits
+	parserStats wire.Stats
+	counters    counters
+
+	metaMu sync.RWMutex
+	meta   map[int]PinMeta
+	pipes  pipelines
+
+	sysExMu       sync.Mutex
+	sysExHandlers map[SysExCommand]func([]byte)
+	features      map[SysExCommand]Feature
+	required      []PinMode
+	probe         Probe
+	identity      *Identity
+	nextTask      byte
+}
 
+// NewClientConn creates a new Client over an already established
+// connection such as a network socket or a wrapped transport. Like
+// NewClient, it blocks till pin mappings are retrieved.
+func NewClientConn(conn io.ReadWriteCloser, opts ...Option) (*Client, error) {
 	client := &Client{
-		dev:       dev,
-		baud:      baud,
-		conn:      conn,
-		valueChan: make(chan FirmataValue),
+		conn:   conn,
+		clock:  realClock{},
+		modes:  make(map[uint8]PinMode),
+		closed: make(chan struct{}),
 	}
+	for _, opt := range opts {
+		opt(client)
+	}
+	client.stamps.epoch = client.clock.Now()
 
 	inited := client.replyReader()
-	conn.Write([]byte{byte(SystemReset)})
+	client.sendProbe()
 
+	retry := client.clock.After(time.Second * 15)
+	timeout := client.clock.After(time.Second * 30)
 	for {
 		select {
 		case <-inited:
-			return client, err
-		case <-time.After(time.Second * 15):
-			conn.Write([]byte{byte(SystemReset)})
-		case <-time.After(time.Second * 30):
+			if err := client.checkIdentity(); err != nil {
+				client.Close()
+				return nil, err
+			}
+			if err := client.RequireFeatures(client.required...); err != nil {
+				client.Close()
+				return nil, err
+			}
+			return client, nil
+		case <-client.done:
+			conn.Close()
+			if errors.Is(client.readErr, wire.ErrGarbage) {
+				return nil, ErrLikelyBaudMismatch
+			}
+			return nil, fmt.Errorf("cannot open connection to the device: %v", client.readErr)
+		case <-retry:
+			client.sendProbe()
+		case <-timeout:
 			conn.Close()
-			break
+			if client.likelyBaudMismatch() {
+				return nil, ErrLikelyBaudMismatch
+			}
+			return nil, errors.New("cannot open connection to the device; timeout")
 		}
 	}
-
-	return nil, errors.New("cannot open connection to the device; timeout")
 }
 
+// Close drives the pins registered with SetSafeState to their safe
+// levels and closes the connection.
 func (c *Client) Close() error {
-	return c.conn.Close()
+	c.teardownFeatures()
+	werr := c.writeSafeStates()
+	c.bus.closeAll()
+	c.workers.close()
+	c.connMu.Lock()
+	if !c.closing {
+		close(c.closed)
+	}
+	c.closing = true
+	conn := c.conn
+	c.connMu.Unlock()
+	if err := conn.Close(); err != nil {
+		return err
+	}
+	return werr
 }
 
 // SetPinMode sets the pin mode.
 func (c *Client) SetPinMode(pin uint8, mode PinMode) error {
-	if c.pinModes[pin][mode] == nil {
+	if err := c.checkPin(int(pin)); err != nil {
+		return err
+	}
+	if !c.board().supports(int(pin), mode) {
 		return fmt.Errorf("pin mode = %v not supported by pin %v", mode, pin)
 	}
-	return c.sendCommand([]byte{byte(SetPinMode), (pin & 0x7F), byte(mode)})
+	m := wire.PinMode{Pin: pin, Mode: byte(mode)}
+	if err := c.sendConfig(fmt.Sprintf("mode/%d", pin), m); err != nil {
+		return err
+	}
+	c.modeSet(pin, mode)
+	return nil
+}
+
+// SetPinModes sets the modes of several pins in a single write. All the
+// modes are checked first; if any is not supported, nothing is sent and
+// the error lists every unsupported assignment.
+func (c *Client) SetPinModes(modes map[uint8]PinMode) error {
+	pins := make([]int, 0, len(modes))
+	for pin := range modes {
+		pins = append(pins, int(pin))
+	}
+	sort.Ints(pins)
+
+	b := c.board()
+	var errs []error
+	for _, pin := range pins {
+		mode := modes[uint8(pin)]
+		if err := c.checkPin(pin); err != nil {
+			errs = append(errs, err)
+		} else if !b.supports(pin, mode) {
+			errs = append(errs, fmt.Errorf("pin mode = %v not supported by pin %v", mode, pin))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	var batch []byte
+	for _, pin := range pins {
+		batch = append(batch, wire.PinMode{Pin: uint8(pin), Mode: byte(modes[uint8(pin)])}.Bytes()...)
+	}
+	if err := c.sendCommand(batch); err != nil {
+		return err
+	}
+	for _, pin := range pins {
+		mode := modes[uint8(pin)]
+		c.journal.record(fmt.Sprintf("mode/%d", pin), wire.PinMode{Pin: uint8(pin), Mode: byte(mode)})
+		c.modeSet(uint8(pin), mode)
+	}
+	return nil
+}
+
+// modeSet updates the state of pin after its mode was set.
+func (c *Client) modeSet(pin uint8, mode PinMode) {
+	ch, analog := c.board().channels[int(pin)]
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	c.modes[pin] = mode
+	delete(c.outputs, pin)
+	if analog && mode != Analog && ch < 16 {
+		// The firmware stops reporting analog pins used as digital
+		// pins.
+		c.analogReporting[ch] = false
+	}
 }
 
 // Specified if a digital Pin should be watched for input.
 // Values will be streamed back over a channel which can be retrieved by the GetValues() call
+// The first report after enabling is an Initial DigitalEvent holding the level of every input pin of the port.
 func (c *Client) EnableDigitalInput(pin uint, val bool) error {
-	if pin < 0 || pin > uint(len(c.pinModes)) {
-		return fmt.Errorf("invalid pin number: %v", pin)
+	if err := c.checkPin(int(pin)); err != nil {
+		return err
 	}
-	port := (pin / 8) & 0x7F
-	pin = pin % 8
-
+	m := wire.DigitalReport{Port: byte(pin / 8), Enable: val}
+	if err := c.sendConfig(fmt.Sprintf("digital/%d", pin/8), m); err != nil {
+		return err
+	}
+	c.stateMu.Lock()
+	c.digitalReporting[pin/8] = val
+	c.initialPorts[pin/8] = false
+	c.stateMu.Unlock()
 	if val {
-		cmd := []byte{byte(EnableDigitalInput) | byte(port), 0x01}
-		return c.sendCommand(cmd)
+		c.awaitInitial(byte(pin / 8))
 	}
-	cmd := []byte{byte(EnableDigitalInput) | byte(port), 0x00}
-	return c.sendCommand(cmd)
+	return nil
 }
 
 // Set the value of a digital pin
 func (c *Client) DigitalWrite(pin uint8, val bool) error {
-	if pin < 0 || pin > uint8(len(c.pinModes)) && c.pinModes[pin][Output] != nil {
-		return fmt.Errorf("invalid pin number: %v", pin)
+	if err := c.checkPin(int(pin)); err != nil {
+		return err
+	}
+	write := func() error { return c.digitalWrite(pin, val) }
+	if err := write(); err != nil {
+		return err
+	}
+	if !c.isCritical(pin) {
+		return nil
 	}
-	port := (pin / 8) & 0x7F
-	portData := &c.digitalPinState[port]
-	pin = pin % 8
+	v := 0
 	if val {
-		(*portData) = (*portData) | (1 << pin)
+		v = 1
+	}
+	return c.confirm(context.Background(), pin, v, write)
+}
+
+func (c *Client) digitalWrite(pin uint8, val bool) error {
+	// portMu keeps the port bytes in the order they were computed, so
+	// that concurrent writes to a port never end with a stale byte.
+	c.portMu.Lock()
+	defer c.portMu.Unlock()
+
+	port := pin / 8
+	bit := byte(1) << (pin % 8)
+	c.stateMu.Lock()
+	if val {
+		c.digitalPinState[port] |= bit
 	} else {
-		(*portData) = (*portData) & ^(1 << pin)
+		c.digitalPinState[port] &^= bit
+	}
+	value := c.digitalPinState[port]
+	p := PriorityNormal
+	if _, safe := c.safeStates[pin]; safe || c.critical[pin] {
+		p = PriorityCritical
 	}
-	data := to7Bit(*(portData))
-	cmd := []byte{byte(DigitalMessage) | byte(port), data[0], data[1]}
-	return c.sendCommand(cmd)
+	c.stateMu.Unlock()
+
+	if err := c.sendPriority(wire.Digital{Port: port, Value: value}.Bytes(), p); err != nil {
+		return err
+	}
+	v := 0
+	if val {
+		v = 1
+	}
+	c.stateMu.Lock()
+	c.recordOutput(pin, v)
+	c.stateMu.Unlock()
+	return nil
+}
+
+// recordOutput remembers the value written to pin. c.stateMu must be
+// held.
+func (c *Client) recordOutput(pin uint8, v int) {
+	if c.outputs == nil {
+		c.outputs = make(map[uint8]int)
+	}
+	c.outputs[pin] = v
 }
 
 // Specified if a analog Pin should be watched for input.
 // Values will be streamed back over a channel which can be retrieved by the Values() call.
 func (c *Client) EnableAnalogInput(pin uint, val bool) error {
-	if pin < 0 || pin > uint(len(c.pinModes)) && c.pinModes[pin][Analog] != nil {
-		return fmt.Errorf("invalid pin number: %v\n", pin)
+	if err := c.checkPin(int(pin)); err != nil {
+		return err
 	}
-	ch := byte(c.analogPinsChannelMap[int(pin)])
-	if val {
-		cmd := []byte{byte(EnableAnalogInput) | ch, 0x01}
-		return c.sendCommand(cmd)
+	ch, ok := c.board().channels[int(pin)]
+	if !ok || ch > 15 {
+		return fmt.Errorf("pin %v is not an analog input", pin)
 	}
-	cmd := []byte{byte(EnableAnalogInput) | ch, 0x00}
-	return c.sendCommand(cmd)
+	m := wire.AnalogReport{Channel: ch, Enable: val}
+	if err := c.sendConfig(fmt.Sprintf("analog/%d", ch), m); err != nil {
+		return err
+	}
+	c.stateMu.Lock()
+	c.analogReporting[ch] = val
+	c.stateMu.Unlock()
+	return nil
 }
 
+// AnalogWrite writes pinData to a pin in PWM or servo mode. Other pins
+// fail with a *PWMError unless the client was created WithAutoPWM.
 func (c *Client) AnalogWrite(pin uint, pinData byte) error {
-	if pin < 0 || pin > uint(len(c.pinModes)) && c.pinModes[pin][Analog] != nil {
-		return fmt.Errorf("invalid pin number %v\n", pin)
+	if err := c.checkPin(int(pin)); err != nil {
+		return err
+	}
+	if err := c.checkPWM(uint8(pin)); err != nil {
+		return err
+	}
+	write := func() error { return c.analogWrite(uint8(pin), int(pinData), false) }
+	if err := write(); err != nil {
+		return err
+	}
+	if !c.isCritical(uint8(pin)) {
+		return nil
+	}
+	return c.confirm(context.Background(), uint8(pin), int(pinData), write)
+}
+
+// checkPin reports an error if pin is not a pin of the board. Pins above
+// 127 cannot be addressed by the digital port messages.
+func (c *Client) checkPin(pin int) error {
+	if pin < 0 || pin >= len(c.board().modes) || pin > 127 {
+		return fmt.Errorf("invalid pin number: %v", pin)
 	}
-	data := to7Bit(pinData)
-	cmd := []byte{byte(AnalogMessage) | byte(pin), data[0], data[1]}
-	return c.sendCommand(cmd)
+	return nil
 }
 
 func (c *Client) sendCommand(cmd []byte) error {
-	// TODO(jbd): Do not concat.
-	bStr := ""
-	for _, b := range cmd {
-		bStr = bStr + fmt.Sprintf(" %#2x", b)
+	return c.sendPriority(cmd, PriorityNormal)
+}
+
+func (c *Client) sendPriority(cmd []byte, p Priority) error {
+	c.lanes.acquire(p)
+	defer c.lanes.release()
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	n, err := c.write(c.conn, cmd)
+	c.counters.bytesSent.Add(uint64(n))
+	if err == nil {
+		c.counters.count(&c.counters.sent, frameType(cmd))
 	}
-	_, err := c.conn.Write(cmd)
 	return err
 }
 
+func (c *Client) send(m wire.Message) error {
+	return c.sendCommand(m.Bytes())
+}
+
 func (c *Client) SetAnalogSamplingInterval(ms byte) error {
-	data := to7Bit(ms)
-	return c.sendSysEx(SamplingInterval, data[0], data[1])
+	m := wire.SysEx{Command: byte(SamplingInterval), Data: wire.To7Bit(ms)}
+	return c.sendConfig("sampling", m)
 }
 
+// Values returns the channel of digital and analog values reported by
+// the board. All callers share the same channel. Values are dropped
+// when it is full.
+//
+// Goroutines reading the channel take values away from each other; use
+// ValuesShared to give each reader a complete stream.
 func (c *Client) Values() <-chan FirmataValue {
+	c.valuesOnce.Do(func() { c.valueChan = c.subscribeValues() })
 	return c.valueChan
 }
+
+// ValuesShared is like Values but returns a new channel on each call,
+// which receives every value, and a function that stops the delivery
+// and closes the channel.
+func (c *Client) ValuesShared() (<-chan FirmataValue, func()) {
+	ch := c.subscribeValues()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() { c.bus.remove((<-chan FirmataValue)(ch)) })
+	}
+}
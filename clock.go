@@ -0,0 +1,85 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Clock is the source of time of a Client. It is used for handshake and
+// read timeouts, delays and event timestamps, so tests can replace real
+// waits with a fake clock.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	Sleep(d time.Duration)
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithClock makes the client use clk instead of the system clock.
+func WithClock(clk Clock) Option {
+	return func(c *Client) {
+		c.clock = clk
+	}
+}
+
+// Delay pauses the calling goroutine for d, as measured by the client
+// clock.
+func (c *Client) Delay(d time.Duration) {
+	c.clock.Sleep(d)
+}
+
+// errClosed is returned by waits interrupted because the connection to
+// the board is gone.
+var errClosed = errors.New("firmata: connection closed")
+
+// DelayCtx is like Delay but returns early with an error when ctx is
+// done or the connection to the board is closed.
+func (c *Client) DelayCtx(ctx context.Context, d time.Duration) error {
+	select {
+	case <-c.clock.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.done:
+		return errClosed
+	}
+}
+
+// After returns a channel that receives the time after d, as measured
+// by the client clock.
+func (c *Client) After(d time.Duration) <-chan time.Time {
+	return c.clock.After(d)
+}
+
+// AfterFunc calls f in its own goroutine after d, unless ctx is done or
+// the connection is closed first.
+func (c *Client) AfterFunc(ctx context.Context, d time.Duration, f func()) {
+	go func() {
+		if c.DelayCtx(ctx, d) == nil {
+			f()
+		}
+	}()
+}
@@ -0,0 +1,47 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !js
+
+// Command firmata-relay shares a locally attached board with multiple
+// TCP clients. Clients connect with firmata.NewClientConn.
+//
+// Usage:
+//
+//	firmata-relay -dev /dev/ttyACM0 -baud 57600 -addr :3030
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/rakyll/go-firmata/relay"
+	"github.com/tarm/serial"
+)
+
+var (
+	dev  = flag.String("dev", "/dev/ttyACM0", "serial device of the board")
+	baud = flag.Int("baud", 57600, "baud rate of the board")
+	addr = flag.String("addr", ":3030", "TCP address to listen on")
+)
+
+func main() {
+	flag.Parse()
+	conn, err := serial.OpenPort(&serial.Config{Name: *dev, Baud: *baud})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("relaying %v on %v", *dev, *addr)
+	log.Fatal(relay.NewServer(conn).ListenAndServe(*addr))
+}
@@ -0,0 +1,124 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command firmata provides tools for working with Firmata sessions.
+//
+// Usage:
+//
+//	firmata decode file
+//	firmata gen-sketch [-o file] -features i2c,servo,...
+//
+// decode pretty-prints a session recorded with the trace package.
+//
+// gen-sketch writes a ConfigurableFirmata sketch that compiles in the
+// features an application uses, so the firmware and the client agree
+// on the supported modes. The known features are analog, pwm, servo,
+// i2c, onewire, stepper, serial, encoder and scheduler; digital input
+// and output are always included. neopixel is not supported.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/rakyll/go-firmata/trace"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: firmata decode file")
+	fmt.Fprintln(os.Stderr, "       firmata gen-sketch [-o file] -features i2c,servo,...")
+	os.Exit(2)
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("firmata: ")
+	flag.Usage = usage
+	flag.Parse()
+	switch {
+	case flag.NArg() == 2 && flag.Arg(0) == "decode":
+		if err := decode(os.Stdout, flag.Arg(1)); err != nil {
+			log.Fatal(err)
+		}
+	case flag.NArg() >= 1 && flag.Arg(0) == "gen-sketch":
+		if err := genSketchCmd(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		usage()
+	}
+}
+
+func genSketchCmd(args []string) error {
+	fs := flag.NewFlagSet("gen-sketch", flag.ExitOnError)
+	fs.Usage = usage
+	features := fs.String("features", "", "comma separated features to compile in")
+	out := fs.String("o", "", "write the sketch to `file` instead of standard output")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		usage()
+	}
+	if *out == "" {
+		return genSketch(os.Stdout, *features)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := genSketch(f, *features); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func decode(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := trace.NewReader(f)
+	if err != nil {
+		return err
+	}
+
+	var framer trace.Framer
+	var start, last int64
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, fr := range framer.Add(rec) {
+			t := fr.Time.UnixNano()
+			if start == 0 {
+				start, last = t, t
+			}
+			desc := fmt.Sprintf("% x", fr.Raw)
+			if fr.Message != nil {
+				desc = fmt.Sprintf("%T %+v", fr.Message, fr.Message)
+			}
+			fmt.Fprintf(w, "%10.3fms %+8.3fms %v %s\n",
+				float64(t-start)/1e6, float64(t-last)/1e6, fr.Dir, desc)
+			last = t
+		}
+	}
+}
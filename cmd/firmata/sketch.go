@@ -0,0 +1,195 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// sketchFeature is a ConfigurableFirmata module gen-sketch can compile in.
+type sketchFeature struct {
+	Key     string // the name given to --features
+	Include string // declarations of the module
+	Name    string // the variable holding the module
+	Mode    string // the PinMode the module adds, if any
+}
+
+var sketchFeatures = map[string]sketchFeature{
+	"analog":    {"", "#include <AnalogInputFirmata.h>\nAnalogInputFirmata analogInput;", "analogInput", "Analog"},
+	"pwm":       {"", "#include <AnalogOutputFirmata.h>\nAnalogOutputFirmata analogOutput;", "analogOutput", "PWM"},
+	"servo":     {"", "#include <Servo.h>\n#include <ServoFirmata.h>\nServoFirmata servo;", "servo", "Servo"},
+	"i2c":       {"", "#include <Wire.h>\n#include <I2CFirmata.h>\nI2CFirmata i2c;", "i2c", "I2C"},
+	"onewire":   {"", "#include <OneWireFirmata.h>\nOneWireFirmata oneWire;", "oneWire", ""},
+	"stepper":   {"", "#include <AccelStepperFirmata.h>\nAccelStepperFirmata accelStepper;", "accelStepper", "Stepper"},
+	"serial":    {"", "#include <SerialFirmata.h>\nSerialFirmata serial;", "serial", ""},
+	"encoder":   {"", "#include <EncoderFirmata.h>\nEncoderFirmata encoder;", "encoder", "Encoder"},
+	"scheduler": {"", "#include <FirmataScheduler.h>\nFirmataScheduler scheduler;", "scheduler", ""},
+}
+
+// unsupportedSketchFeatures are features gen-sketch is asked for but
+// cannot compile in, with the reason.
+var unsupportedSketchFeatures = map[string]string{
+	"neopixel": "ConfigurableFirmata has no NeoPixel module and the client does not implement a NeoPixel protocol",
+}
+
+// sketch is the data of sketchTemplate.
+type sketch struct {
+	Features  []sketchFeature
+	Has       map[string]bool
+	Modes     string
+	Reporting bool
+}
+
+var sketchTemplate = template.Must(template.New("sketch").Parse(`/*
+ * ConfigurableFirmata sketch generated by "firmata gen-sketch" with the
+ * features: digital{{range .Features}}, {{.Key}}{{end}}.
+{{- if .Modes}}
+ *
+ * Connect with firmata.WithRequiredFeatures({{.Modes}})
+ * to check the board runs this firmware.
+{{- end}}
+ */
+
+#include <ConfigurableFirmata.h>
+
+#include <DigitalInputFirmata.h>
+DigitalInputFirmata digitalInput;
+
+#include <DigitalOutputFirmata.h>
+DigitalOutputFirmata digitalOutput;
+{{range .Features}}
+{{.Include}}
+{{end}}
+{{- if or (index .Has "pwm") (index .Has "servo")}}
+#include <AnalogWrite.h>
+{{end}}
+#include <FirmataExt.h>
+FirmataExt firmataExt;
+{{if .Reporting}}
+#include <FirmataReporting.h>
+FirmataReporting reporting;
+{{end}}
+void systemResetCallback()
+{
+  for (byte i = 0; i < TOTAL_PINS; i++) {
+    if (IS_PIN_ANALOG(i)) {
+      Firmata.setPinMode(i, PIN_MODE_ANALOG);
+    } else if (IS_PIN_DIGITAL(i)) {
+      Firmata.setPinMode(i, OUTPUT);
+    }
+  }
+  firmataExt.reset();
+}
+
+void setup()
+{
+  Firmata.setFirmwareVersion(FIRMATA_FIRMWARE_MAJOR_VERSION, FIRMATA_FIRMWARE_MINOR_VERSION);
+
+  firmataExt.addFeature(digitalInput);
+  firmataExt.addFeature(digitalOutput);
+{{- range .Features}}
+  firmataExt.addFeature({{.Name}});
+{{- end}}
+{{- if .Reporting}}
+  firmataExt.addFeature(reporting);
+{{- end}}
+
+  Firmata.attach(SYSTEM_RESET, systemResetCallback);
+  Firmata.begin(57600);
+  systemResetCallback();
+}
+
+void loop()
+{
+  digitalInput.report();
+
+  while (Firmata.available()) {
+    Firmata.processInput();
+{{- if index .Has "scheduler"}}
+    if (!Firmata.isParsingMessage()) {
+      goto runtasks;
+    }
+  }
+  if (!Firmata.isParsingMessage()) {
+runtasks:
+    scheduler.runTasks();
+{{- end}}
+  }
+{{- if .Reporting}}
+
+  if (reporting.elapsed()) {
+{{- if index .Has "analog"}}
+    analogInput.report();
+{{- end}}
+{{- if index .Has "i2c"}}
+    i2c.report();
+{{- end}}
+{{- if index .Has "encoder"}}
+    encoder.report();
+{{- end}}
+  }
+{{- end}}
+{{- if index .Has "serial"}}
+
+  serial.update();
+{{- end}}
+{{- if index .Has "stepper"}}
+
+  accelStepper.update();
+{{- end}}
+}
+`))
+
+// genSketch writes a ConfigurableFirmata sketch with the comma separated
+// features to w.
+func genSketch(w io.Writer, features string) error {
+	s := sketch{Has: make(map[string]bool)}
+	var modes []string
+	for _, f := range strings.Split(features, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" || s.Has[f] {
+			continue
+		}
+		if why, ok := unsupportedSketchFeatures[f]; ok {
+			return fmt.Errorf("feature %q is not supported: %s", f, why)
+		}
+		sf, ok := sketchFeatures[f]
+		if !ok {
+			return fmt.Errorf("unknown feature %q, known features are %s", f, strings.Join(knownSketchFeatures(), ", "))
+		}
+		sf.Key = f
+		s.Has[f] = true
+		s.Features = append(s.Features, sf)
+		if sf.Mode != "" {
+			modes = append(modes, "firmata."+sf.Mode)
+		}
+	}
+	s.Modes = strings.Join(modes, ", ")
+	s.Reporting = s.Has["analog"] || s.Has["i2c"] || s.Has["encoder"]
+	return sketchTemplate.Execute(w, s)
+}
+
+func knownSketchFeatures() []string {
+	var names []string
+	for name := range sketchFeatures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -16,6 +16,8 @@ package firmata
 
 import (
 	"fmt"
+
+	"github.com/rakyll/go-firmata/wire"
 )
 
 type FirmataCommand byte
@@ -32,20 +34,25 @@ const (
 
 	// message command bytes (128-255/0x80-0xFF)
 
-	DigitalMessage     FirmataCommand = 0x90 // send data for a digital pin
-	AnalogMessage      FirmataCommand = 0xE0 // send data for an analog pin (or PWM)
-	EnableAnalogInput  FirmataCommand = 0xC0 // enable analog input by pin #
-	EnableDigitalInput FirmataCommand = 0xD0 // enable digital input by port pair
-	SetPinMode         FirmataCommand = 0xF4 // set a pin to INPUT/OUTPUT/PWM/etc
-	ReportVersion      FirmataCommand = 0xF9 // report protocol version
-	SystemReset        FirmataCommand = 0xFF // reset from MIDI
-	StartSysEx         FirmataCommand = 0xF0 // start a MIDI Sysex message
-	EndSysEx           FirmataCommand = 0xF7 // end a MIDI Sysex message
+	DigitalMessage     FirmataCommand = wire.DigitalMessage // send data for a digital pin
+	AnalogMessage      FirmataCommand = wire.AnalogMessage  // send data for an analog pin (or PWM)
+	EnableAnalogInput  FirmataCommand = wire.ReportAnalog   // enable analog input by pin #
+	EnableDigitalInput FirmataCommand = wire.ReportDigital  // enable digital input by port pair
+	SetPinMode         FirmataCommand = wire.SetPinMode     // set a pin to INPUT/OUTPUT/PWM/etc
+	ReportVersion      FirmataCommand = wire.ReportVersion  // report protocol version
+	SystemReset        FirmataCommand = wire.SystemReset    // reset from MIDI
+	StartSysEx         FirmataCommand = wire.StartSysEx     // start a MIDI Sysex message
+	EndSysEx           FirmataCommand = wire.EndSysEx       // end a MIDI Sysex message
 
 	// extended command set using sysex (0-127/0x00-0x7F)
 	/* 0x00-0x0F reserved for user-defined commands */
 	ServoConfig           SysExCommand = 0x70 // set max angle, minPulse, maxPulse, freq
 	StringData            SysExCommand = 0x71 // a string message with 14-bits per char
+	ToneData              SysExCommand = 0x5F // play or stop a tone on a pin
+	StepperData           SysExCommand = 0x72 // control a stepper motor (legacy StepperFirmata)
+	AccelStepperData      SysExCommand = 0x62 // control a stepper motor with acceleration
+	EncoderData           SysExCommand = 0x61 // attach and read rotary encoders
+	PulseIn               SysExCommand = 0x74 // time a pulse on a pin
 	ShiftData             SysExCommand = 0x75 // a bitstream to/from a shift register
 	I2CRequest            SysExCommand = 0x76 // send an I2C read/write request
 	I2CReply              SysExCommand = 0x77 // a reply to an I2C read request
@@ -59,15 +66,21 @@ const (
 	AnalogMappingResponse SysExCommand = 0x6A // reply with mapping info
 	ReportFirmware        SysExCommand = 0x79 // report name and version of the firmware
 	SamplingInterval      SysExCommand = 0x7A // set the poll rate of the main loop
+	Scheduler             SysExCommand = 0x7B // create and schedule tasks of commands
 	SysExNonRealtime      SysExCommand = 0x7E // MIDI Reserved for non-realtime messages
 	SysExRealtime         SysExCommand = 0x7F // MIDI Reserved for realtime messages
 	Serial                SysExCommand = 0x60
 	SysExSPI              SysExCommand = 0x80
 
+	// Serial subcommands and ports follow the Firmata serial protocol.
+	// Before it, SerialFlush was 0x30, SerialClose 0x40 and SoftSerial
+	// 0x00.
 	SerialConfig SerialSubCommand = 0x10
-	SerialComm   SerialSubCommand = 0x20
-	SerialFlush  SerialSubCommand = 0x30
-	SerialClose  SerialSubCommand = 0x40
+	SerialComm   SerialSubCommand = 0x20 // write
+	SerialRead   SerialSubCommand = 0x30
+	SerialReply  SerialSubCommand = 0x40
+	SerialClose  SerialSubCommand = 0x50
+	SerialFlush  SerialSubCommand = 0x60
 
 	SPIConfig SPISubCommand = 0x10
 	SPIComm   SPISubCommand = 0x20
@@ -77,10 +90,14 @@ const (
 	SPI_MODE2 = 0x08
 	SPI_MODE3 = 0x0C
 
-	SoftSerial  SerialPort = 0x00
+	HardSerial0 SerialPort = 0x00
 	HardSerial1 SerialPort = 0x01
 	HardSerial2 SerialPort = 0x02
 	HardSerial3 SerialPort = 0x03
+	SoftSerial  SerialPort = 0x08 // the first software serial port
+	SoftSerial1 SerialPort = 0x09
+	SoftSerial2 SerialPort = 0x0A
+	SoftSerial3 SerialPort = 0x0B
 
 	// pin modes
 	Input  PinMode = 0x00
@@ -91,6 +108,17 @@ const (
 	Shift  PinMode = 0x05
 	I2C    PinMode = 0x06
 	SPI    PinMode = 0x07
+
+	// Stepper is the mode of pins driven by the Stepper and
+	// AccelStepper firmware features.
+	Stepper PinMode = 0x08
+
+	// Encoder is the mode of pins read by the Encoder feature.
+	Encoder PinMode = 0x09
+
+	// DAC is the mode of true analog outputs, advertised by firmware
+	// for boards such as the Due and the Zero.
+	DAC PinMode = 0x11
 )
 
 func (m PinMode) String() string {
@@ -109,6 +137,14 @@ func (m PinMode) String() string {
 		return "SHIFT"
 	case m == I2C:
 		return "I2C"
+	case m == SPI:
+		return "SPI"
+	case m == Stepper:
+		return "STEPPER"
+	case m == Encoder:
+		return "ENCODER"
+	case m == DAC:
+		return "DAC"
 	}
 	return "UNKNOWN"
 }
@@ -143,6 +179,16 @@ func (c SysExCommand) String() string {
 		return fmt.Sprintf("ServoConfig (0x%x)", byte(c))
 	case c == StringData:
 		return fmt.Sprintf("StringData (0x%x)", byte(c))
+	case c == ToneData:
+		return fmt.Sprintf("ToneData (0x%x)", byte(c))
+	case c == StepperData:
+		return fmt.Sprintf("StepperData (0x%x)", byte(c))
+	case c == AccelStepperData:
+		return fmt.Sprintf("AccelStepperData (0x%x)", byte(c))
+	case c == EncoderData:
+		return fmt.Sprintf("EncoderData (0x%x)", byte(c))
+	case c == PulseIn:
+		return fmt.Sprintf("PulseIn (0x%x)", byte(c))
 	case c == ShiftData:
 		return fmt.Sprintf("ShiftData (0x%x)", byte(c))
 	case c == I2CRequest:
@@ -169,6 +215,8 @@ func (c SysExCommand) String() string {
 		return fmt.Sprintf("ReportFirmware (0x%x)", byte(c))
 	case c == SamplingInterval:
 		return fmt.Sprintf("SamplingInterval (0x%x)", byte(c))
+	case c == Scheduler:
+		return fmt.Sprintf("Scheduler (0x%x)", byte(c))
 	case c == SysExNonRealtime:
 		return fmt.Sprintf("SysExNonRealtime (0x%x)", byte(c))
 	case c == SysExRealtime:
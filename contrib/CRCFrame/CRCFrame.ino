@@ -0,0 +1,108 @@
+/*
+  Copyright 2014 Krishna Raman
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+/*
+  Companion snippet for transport.CRC. Paste it into a StandardFirmata
+  based sketch, call crcFrame() from the sysex callback for CRC_FRAME and
+  replace Firmata.begin(57600) with:
+
+    Serial.begin(57600);
+    Firmata.begin(crcStream);
+
+  Every frame the host sends arrives as CRC_FRAME; frames with a bad
+  checksum are dropped instead of being executed. Frames the board sends
+  are wrapped the same way by CRCStream.
+*/
+
+#define CRC_FRAME 0x0C
+
+byte crc8Update(byte crc, byte b)
+{
+  crc ^= b;
+  for (byte j = 0; j < 8; j++) {
+    crc = (crc & 0x80) ? (crc << 1) ^ 0x07 : crc << 1;
+  }
+  return crc;
+}
+
+// CRCStream sends each Firmata frame wrapped in a CRC_FRAME sysex. Bytes
+// are encoded and added to the checksum as they are written, so frames
+// of any length, such as the capability response, are wrapped whole.
+class CRCStream : public Stream {
+  bool open;
+  byte crc;
+  byte left;
+
+  void put(byte b) {
+    Serial.write(b & 0x7F);
+    Serial.write(b >> 7);
+    crc = crc8Update(crc, b);
+  }
+
+  void closeFrame() {
+    Serial.write(crc & 0x7F);
+    Serial.write(crc >> 7);
+    Serial.write(END_SYSEX);
+    open = false;
+  }
+
+public:
+  size_t write(uint8_t b) {
+    if (!open) {
+      switch (b & 0xF0) {
+        case DIGITAL_MESSAGE:
+        case ANALOG_MESSAGE:
+          left = 3;
+          break;
+        default:
+          left = (b == REPORT_VERSION) ? 3 : 0;
+      }
+      crc = 0;
+      open = true;
+      Serial.write(START_SYSEX);
+      Serial.write(CRC_FRAME);
+    }
+    put(b);
+    if ((left && --left == 0) || b == END_SYSEX) {
+      closeFrame();
+    }
+    return 1;
+  }
+  int available() { return Serial.available(); }
+  int read() { return Serial.read(); }
+  int peek() { return Serial.peek(); }
+  void flush() { Serial.flush(); }
+} crcStream;
+
+// crcFrame verifies a CRC_FRAME payload and feeds the unwrapped frame to
+// the Firmata parser. The checksum is computed in a first pass over the
+// payload, so no copy of the frame is needed.
+void crcFrame(byte argc, byte *argv)
+{
+  if (argc < 2 || argc % 2 != 0) {
+    return;
+  }
+  byte crc = 0;
+  for (byte i = 0; i < argc - 2; i += 2) {
+    crc = crc8Update(crc, argv[i] | (argv[i + 1] << 7));
+  }
+  if (crc != (byte)(argv[argc - 2] | (argv[argc - 1] << 7))) {
+    return;
+  }
+  for (byte i = 0; i < argc - 2; i += 2) {
+    Firmata.parse(argv[i] | (argv[i + 1] << 7));
+  }
+}
@@ -0,0 +1,35 @@
+/*
+  Copyright 2014 Krishna Raman
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+/*
+  ECHO feature for Client.EchoTest. Paste it into a StandardFirmata based
+  sketch and call echoSysex(argc, argv) from the sysex callback for ECHO.
+
+  ECHO request: any 7-bit data
+  ECHO reply:   the same data
+*/
+
+#define ECHO 0x09
+
+void echoSysex(byte argc, byte *argv)
+{
+  Serial.write(START_SYSEX);
+  Serial.write(ECHO);
+  for (byte i = 0; i < argc; i++) {
+    Serial.write(argv[i]);
+  }
+  Serial.write(END_SYSEX);
+}
@@ -46,15 +46,24 @@
 
 #define SYSEX_SERIAL 0x60
 
+// Firmata serial protocol 1.0. One port can be open at a time.
 #define SERIAL_CONFIG 0x10
-#define SERIAL_COMM 0x20
-#define SERIAL_FLUSH 0x30
-#define SERIAL_CLOSE 0x40
+#define SERIAL_WRITE 0x20
+#define SERIAL_READ 0x30
+#define SERIAL_REPLY 0x40
+#define SERIAL_CLOSE 0x50
+#define SERIAL_FLUSH 0x60
+
+#define SERIAL_READ_CONTINUOUSLY 0x00
+#define SERIAL_STOP_READING 0x01
 
-#define SW_SERIAL 0x00
 #define HW_SERIAL1 0x01
 #define HW_SERIAL2 0x02
 #define HW_SERIAL3 0x03
+#define SW_SERIAL0 0x08
+#define SW_SERIAL3 0x0B
+
+#define SERIAL_REPLY_MAX 32
 
 #define MAX_QUERIES 8
 #define MINIMUM_SAMPLING_INTERVAL 10
@@ -111,10 +120,8 @@ unsigned int i2cReadDelayTime =
 Servo servos[MAX_SERVOS];
 
 Stream *serialPort = NULL;
-byte *serialReadBuffer = NULL;
-int serialReadBufferPos = 0;
-byte serialReadTermChar = '\n';
-int serialReadBufferLen = 0;
+byte serialPortId;
+boolean serialReading = false;
 
 /*==============================================================================
  * FUNCTIONS
@@ -474,6 +481,14 @@ void sysexCallback(byte command, byte argc, byte *argv) {
       enableI2CPins();
     }
 
+    // optional bus clock in kHz, after Wire.begin() which resets it
+    if (argc >= 4) {
+      unsigned long clockKHz = argv[2] + (argv[3] << 7);
+      if (clockKHz > 0) {
+        Wire.setClock(clockKHz * 1000L);
+      }
+    }
+
     break;
   case SERVO_CONFIG:
     if (argc > 4) {
@@ -571,54 +586,72 @@ void sysexCallback(byte command, byte argc, byte *argv) {
       }
       long baud =
           ((long)argv[1]) | (((long)argv[2]) << 7) | (((long)argv[3]) << 14);
-      serialReadBufferLen = (int)(argv[4] | argv[5] << 7 | argv[6] << 14);
-      serialReadBuffer = (byte *)calloc(sizeof(byte), serialReadBufferLen);
-      serialReadTermChar = (byte)(argv[7] | argv[8] << 7);
-      serialReadBufferPos = 0;
-
-      // byte txPin = argv[4];
-      // byte rxPin = argv[5];
       switch (port) {
+#if defined(HAVE_HWSERIAL1) || defined(UBRR1H)
       case HW_SERIAL1:
         Serial1.begin(baud);
         serialPort = &Serial1;
         break;
+#endif
+#if defined(HAVE_HWSERIAL2) || defined(UBRR2H)
       case HW_SERIAL2:
         Serial2.begin(baud);
         serialPort = &Serial2;
         break;
+#endif
+#if defined(HAVE_HWSERIAL3) || defined(UBRR3H)
       case HW_SERIAL3:
         Serial3.begin(baud);
         serialPort = &Serial3;
         break;
+#endif
+      default:
+        if (port >= SW_SERIAL0 && port <= SW_SERIAL3 && argc >= 6) {
+          SoftwareSerial *sw = new SoftwareSerial(argv[4], argv[5]);
+          sw->begin(baud);
+          serialPort = sw;
+        } else {
+          Firmata.sendString("Unsupported serial port");
+        }
       }
+      serialPortId = port;
+      serialReading = false;
       break;
     }
-    case SERIAL_COMM: {
-      if (serialPort == NULL) {
+    case SERIAL_WRITE: {
+      if (serialPort == NULL || port != serialPortId) {
         break;
       }
-      byte data;
-      // reassemble data bytes and forward to sw serial write buffer
-      for (int i = 1; i < argc; i += 2) {
-        data = argv[i] + (argv[i + 1] << 7);
-        serialPort->write(data);
+      // reassemble data bytes and forward to the serial port
+      for (int i = 1; i + 1 < argc; i += 2) {
+        serialPort->write((byte)(argv[i] | (argv[i + 1] << 7)));
       }
       break;
     }
+    case SERIAL_READ:
+      if (serialPort == NULL || port != serialPortId) {
+        break;
+      }
+      serialReading = argc < 2 || argv[1] == SERIAL_READ_CONTINUOUSLY;
+      break;
     case SERIAL_FLUSH:
-      if (serialPort == NULL) {
+      if (serialPort == NULL || port != serialPortId) {
         break;
       }
       serialPort->flush();
       break;
     case SERIAL_CLOSE:
-      if (serialPort == NULL) {
+      if (serialPort == NULL || port != serialPortId) {
         break;
       }
-      ((HardwareSerial *)serialPort)->end();
+      if (port >= SW_SERIAL0) {
+        ((SoftwareSerial *)serialPort)->end();
+        delete (SoftwareSerial *)serialPort;
+      } else {
+        ((HardwareSerial *)serialPort)->end();
+      }
       serialPort = NULL;
-      free(serialReadBuffer);
+      serialReading = false;
       break;
     }
     break;
@@ -773,23 +806,16 @@ void loop() {
   while (Firmata.available())
     Firmata.processInput();
 
-  if (serialPort != NULL) {
-    while (serialPort->available() > 0) {
+  if (serialPort != NULL && serialReading && serialPort->available() > 0) {
+    Serial.write(START_SYSEX);
+    Serial.write(SYSEX_SERIAL);
+    Serial.write(SERIAL_REPLY | serialPortId);
+    for (byte n = 0; n < SERIAL_REPLY_MAX && serialPort->available() > 0; n++) {
       byte inChar = serialPort->read();
-      serialReadBuffer[serialReadBufferPos++] = inChar;
-      if (inChar == serialReadTermChar ||
-          (serialReadBufferPos + 2) >= serialReadBufferLen) {
-        Serial.write(START_SYSEX);
-        Serial.write(SYSEX_SERIAL);
-        Serial.write(SERIAL_COMM);
-        for (int i = 0; i < serialReadBufferPos; i++) {
-          Serial.write((byte)(serialReadBuffer[i] & 0x7F));
-          Serial.write((byte)((serialReadBuffer[i] >> 7) & 0x7F));
-        }
-        Serial.write(END_SYSEX);
-        serialReadBufferPos = 0;
-      }
+      Serial.write((byte)(inChar & 0x7F));
+      Serial.write((byte)((inChar >> 7) & 0x7F));
     }
+    Serial.write(END_SYSEX);
   }
 
   /* SEND FTDI WRITE BUFFER - make sure that the FTDI buffer doesn't go over
@@ -0,0 +1,112 @@
+/*
+  Copyright 2014 Krishna Raman
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+/*
+  HX711_DATA feature for the hx711 driver. Paste it into a StandardFirmata
+  based sketch and call hx711Sysex(argc, argv) from the sysex callback for
+  HX711_DATA.
+
+  HX711_DATA config: 0x01 dout sck gain   (gain pulses: 1=A128 2=B32 3=A64)
+  HX711_DATA read:   0x02 dout
+  reply:             0x02 dout v0 v1 v2 v3 (24-bit value, 7 bits per byte)
+*/
+
+#define HX711_DATA 0x0D
+#define HX711_MAX 4
+
+struct hx711 {
+  byte dout;
+  byte sck;
+  byte gain;
+} hx711s[HX711_MAX];
+byte hx711Count = 0;
+
+long hx711Read(struct hx711 *h)
+{
+  unsigned long start = millis();
+  while (digitalRead(h->dout) == HIGH) {
+    if (millis() - start > 200) {
+      return 0;
+    }
+  }
+  unsigned long v = 0;
+  noInterrupts();
+  for (byte i = 0; i < 24; i++) {
+    digitalWrite(h->sck, HIGH);
+    delayMicroseconds(1);
+    v = (v << 1) | digitalRead(h->dout);
+    digitalWrite(h->sck, LOW);
+    delayMicroseconds(1);
+  }
+  // extra pulses select channel and gain of the next conversion
+  for (byte i = 0; i < h->gain; i++) {
+    digitalWrite(h->sck, HIGH);
+    delayMicroseconds(1);
+    digitalWrite(h->sck, LOW);
+    delayMicroseconds(1);
+  }
+  interrupts();
+  return v;
+}
+
+void hx711Sysex(byte argc, byte *argv)
+{
+  if (argc < 2) {
+    return;
+  }
+  byte dout = argv[1];
+  struct hx711 *h = NULL;
+  for (byte i = 0; i < hx711Count; i++) {
+    if (hx711s[i].dout == dout) {
+      h = &hx711s[i];
+    }
+  }
+  switch (argv[0]) {
+    case 0x01:
+      if (argc < 4) {
+        return;
+      }
+      if (h == NULL) {
+        if (hx711Count == HX711_MAX) {
+          return;
+        }
+        h = &hx711s[hx711Count++];
+      }
+      h->dout = dout;
+      h->sck = argv[2];
+      h->gain = argv[3];
+      pinMode(h->dout, INPUT);
+      pinMode(h->sck, OUTPUT);
+      digitalWrite(h->sck, LOW);
+      break;
+    case 0x02: {
+      if (h == NULL) {
+        return;
+      }
+      unsigned long v = hx711Read(h);
+      Serial.write(START_SYSEX);
+      Serial.write(HX711_DATA);
+      Serial.write(0x02);
+      Serial.write(dout);
+      for (byte i = 0; i < 4; i++) {
+        Serial.write((byte)(v & 0x7F));
+        v >>= 7;
+      }
+      Serial.write(END_SYSEX);
+      break;
+    }
+  }
+}
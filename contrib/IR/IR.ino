@@ -0,0 +1,102 @@
+/*
+  Copyright 2014 Krishna Raman
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+/*
+  IR_DATA feature for the ir package, built on the IRremote library (2.x).
+  Paste it into a StandardFirmata based sketch, call irSysex(argc, argv)
+  from the sysex callback for IR_DATA and irLoop() from loop().
+
+  IR_DATA receiver config: 0x01 pin
+  IR_DATA received code:   0x02 protocol bits v0 v1 v2 v3 v4
+  IR_DATA send:            0x03 protocol bits v0 v1 v2 v3 v4 repeats
+  (protocol: 1=NEC 2=RC5, value 7 bits per byte LSB first)
+*/
+
+#include <IRremote.h>
+
+#define IR_DATA 0x0E
+
+IRrecv *irrecv = NULL;
+IRsend irsend;
+decode_results irResults;
+
+unsigned long irValue(byte *argv)
+{
+  unsigned long v = 0;
+  for (int i = 4; i >= 0; i--) {
+    v = (v << 7) | (argv[i] & 0x7F);
+  }
+  return v;
+}
+
+void irSysex(byte argc, byte *argv)
+{
+  switch (argv[0]) {
+    case 0x01:
+      if (argc < 2) {
+        return;
+      }
+      delete irrecv;
+      irrecv = new IRrecv(argv[1]);
+      irrecv->enableIRIn();
+      break;
+    case 0x03: {
+      if (argc < 9) {
+        return;
+      }
+      unsigned long v = irValue(&argv[3]);
+      for (byte i = 0; i <= argv[8]; i++) {
+        if (argv[1] == 1) {
+          irsend.sendNEC(v, argv[2]);
+        } else if (argv[1] == 2) {
+          irsend.sendRC5(v, argv[2]);
+        }
+        delay(40);
+      }
+      if (irrecv != NULL) {
+        irrecv->enableIRIn(); // sending disables the receiver
+      }
+      break;
+    }
+  }
+}
+
+void irLoop()
+{
+  if (irrecv == NULL || !irrecv->decode(&irResults)) {
+    return;
+  }
+  byte protocol = 0;
+  if (irResults.decode_type == NEC) {
+    protocol = 1;
+  } else if (irResults.decode_type == RC5) {
+    protocol = 2;
+  }
+  if (protocol != 0) {
+    unsigned long v = irResults.value;
+    Serial.write(START_SYSEX);
+    Serial.write(IR_DATA);
+    Serial.write(0x02);
+    Serial.write(protocol);
+    Serial.write(irResults.bits);
+    for (byte i = 0; i < 5; i++) {
+      Serial.write((byte)(v & 0x7F));
+      v >>= 7;
+    }
+    Serial.write(END_SYSEX);
+  }
+  irrecv->resume();
+}
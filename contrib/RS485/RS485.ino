@@ -0,0 +1,143 @@
+/*
+  Copyright 2014 Krishna Raman
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+/*
+  Addressing shim for transport.RS485. Paste it into a StandardFirmata
+  based sketch, set RS485_ADDRESS to a different value on every board of
+  the line and replace Firmata.begin(57600) with:
+
+    Serial.begin(57600);
+    Firmata.begin(rs485Stream);
+
+  and call rs485Stream.flush() at the end of loop(). Frames are COBS
+  encoded and delimited by zero bytes; each holds the board address, the
+  Firmata bytes and a CRC-8 (polynomial 0x07) of both. Frames for other
+  boards and corrupted frames are ignored. With a transceiver that needs
+  it, drive RS485_DE_PIN high while sending.
+*/
+
+#define RS485_ADDRESS 1
+#define RS485_DE_PIN -1
+
+byte rs485Crc8(const byte *data, int len)
+{
+  byte crc = 0;
+  for (int i = 0; i < len; i++) {
+    crc ^= data[i];
+    for (byte j = 0; j < 8; j++) {
+      crc = (crc & 0x80) ? (crc << 1) ^ 0x07 : crc << 1;
+    }
+  }
+  return crc;
+}
+
+class RS485Stream : public Stream {
+  byte in[128];    // COBS frame being received
+  byte inLen;
+  byte data[128];  // decoded payload of the current frame
+  byte dataLen;
+  byte dataPos;
+  byte out[128];   // address, Firmata bytes and room for the CRC
+  byte outLen;
+
+  // decodeFrame decodes the frame in in and keeps its payload if it is
+  // for this board and intact.
+  void decodeFrame() {
+    byte len = 0;
+    for (byte i = 0; i < inLen;) {
+      byte code = in[i];
+      if (code == 0 || i + code > inLen) {
+        return;
+      }
+      for (byte j = 1; j < code; j++) {
+        data[len++] = in[i + j];
+      }
+      i += code;
+      if (code < 0xFF && i < inLen) {
+        data[len++] = 0;
+      }
+    }
+    if (len < 2 || data[0] != RS485_ADDRESS ||
+        rs485Crc8(data, len - 1) != data[len - 1]) {
+      return;
+    }
+    dataPos = 1;
+    dataLen = len - 1;
+  }
+
+  void fill() {
+    while (dataPos >= dataLen && Serial.available()) {
+      byte b = Serial.read();
+      if (b != 0) {
+        if (inLen < sizeof(in)) {
+          in[inLen++] = b;
+        }
+        continue;
+      }
+      decodeFrame();
+      inLen = 0;
+    }
+  }
+
+public:
+  RS485Stream() : inLen(0), dataLen(0), dataPos(0), outLen(0) {}
+
+  size_t write(uint8_t b) {
+    if (outLen == 0) {
+      out[outLen++] = RS485_ADDRESS;
+    }
+    if (outLen < sizeof(out) - 1) {
+      out[outLen++] = b;
+    }
+    return 1;
+  }
+
+  // flush sends the bytes written since the last flush as one frame.
+  void flush() {
+    if (outLen == 0) {
+      return;
+    }
+    out[outLen] = rs485Crc8(out, outLen);
+    outLen++;
+    if (RS485_DE_PIN >= 0) {
+      digitalWrite(RS485_DE_PIN, HIGH);
+    }
+    byte block[255];
+    byte code = 1;
+    for (byte i = 0; i < outLen; i++) {
+      if (out[i] == 0) {
+        Serial.write(code);
+        Serial.write(block, code - 1);
+        code = 1;
+        continue;
+      }
+      block[code - 1] = out[i];
+      code++;
+    }
+    Serial.write(code);
+    Serial.write(block, code - 1);
+    Serial.write((byte)0);
+    Serial.flush();
+    if (RS485_DE_PIN >= 0) {
+      digitalWrite(RS485_DE_PIN, LOW);
+    }
+    outLen = 0;
+  }
+
+  int available() { fill(); return dataLen - dataPos; }
+  int read() { fill(); return dataPos < dataLen ? data[dataPos++] : -1; }
+  int peek() { fill(); return dataPos < dataLen ? data[dataPos] : -1; }
+} rs485Stream;
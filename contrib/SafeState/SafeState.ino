@@ -0,0 +1,77 @@
+/*
+  Copyright 2014 Krishna Raman
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+/*
+  SAFE_STATE keep-alive feature for Client.KeepAlive. Paste it into a
+  StandardFirmata based sketch, call safeStateSysex(argc, argv) from the
+  sysex callback for SAFE_STATE and safeStateLoop() from loop().
+
+  SAFE_STATE set pin level: 0x01 pin level
+  SAFE_STATE set timeout:   0x02 t0 t1 t2 (milliseconds, 7 bits per byte
+                            LSB first, 0 disables)
+  SAFE_STATE ping:          0x03
+
+  When no ping arrives within the timeout every registered pin is driven
+  to its level once, until the next ping.
+*/
+
+#define SAFE_STATE 0x0B
+#define SAFE_STATE_PINS 128
+
+byte safeLevel[SAFE_STATE_PINS];
+boolean safeSet[SAFE_STATE_PINS];
+unsigned long safeTimeout = 0;
+unsigned long safeLastPing = 0;
+boolean safeApplied = false;
+
+void safeStateSysex(byte argc, byte *argv)
+{
+  switch (argv[0]) {
+    case 0x01:
+      if (argc < 3 || argv[1] >= SAFE_STATE_PINS) {
+        return;
+      }
+      safeLevel[argv[1]] = argv[2] ? HIGH : LOW;
+      safeSet[argv[1]] = true;
+      break;
+    case 0x02:
+      if (argc < 4) {
+        return;
+      }
+      safeTimeout = (unsigned long)argv[1] | ((unsigned long)argv[2] << 7) |
+                    ((unsigned long)argv[3] << 14);
+      safeLastPing = millis();
+      safeApplied = false;
+      break;
+    case 0x03:
+      safeLastPing = millis();
+      safeApplied = false;
+      break;
+  }
+}
+
+void safeStateLoop()
+{
+  if (safeTimeout == 0 || safeApplied || millis() - safeLastPing < safeTimeout) {
+    return;
+  }
+  for (byte pin = 0; pin < SAFE_STATE_PINS && pin < TOTAL_PINS; pin++) {
+    if (safeSet[pin]) {
+      digitalWrite(pin, safeLevel[pin]);
+    }
+  }
+  safeApplied = true;
+}
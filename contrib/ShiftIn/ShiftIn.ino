@@ -0,0 +1,65 @@
+/*
+  Copyright 2014 Krishna Raman
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+/*
+  SHIFT_DATA feature for the hc165 driver. Paste it into a StandardFirmata
+  based sketch and call shiftInSysex(argc, argv) from the sysex callback
+  for SHIFT_DATA.
+
+  SHIFT_DATA shift in: 0x01 data clock latch count
+  reply:               0x01 data b0lo b0hi ... (count bytes, MSB first on
+                       the wire, 7 bits per byte)
+*/
+
+#define SHIFT_DATA 0x75
+#define SHIFT_IN_MAX 32
+
+void shiftInSysex(byte argc, byte *argv)
+{
+  if (argc < 5 || argv[0] != 0x01) {
+    return;
+  }
+  byte data = argv[1];
+  byte clock = argv[2];
+  byte latch = argv[3];
+  byte count = argv[4];
+  if (count > SHIFT_IN_MAX) {
+    count = SHIFT_IN_MAX;
+  }
+  pinMode(data, INPUT);
+  pinMode(clock, OUTPUT);
+  pinMode(latch, OUTPUT);
+  // input H is on the data line right after loading, so the clock
+  // starts high to keep the first rising edge of shiftIn from
+  // shifting it away
+  digitalWrite(clock, HIGH);
+
+  // a low pulse on SH/LD loads the inputs into the registers
+  digitalWrite(latch, LOW);
+  delayMicroseconds(5);
+  digitalWrite(latch, HIGH);
+  delayMicroseconds(5);
+
+  byte reply[2 + 2 * SHIFT_IN_MAX];
+  reply[0] = 0x01;
+  reply[1] = data;
+  for (byte i = 0; i < count; i++) {
+    byte b = shiftIn(data, clock, MSBFIRST);
+    reply[2 + 2 * i] = b & 0x7F;
+    reply[3 + 2 * i] = b >> 7;
+  }
+  Firmata.sendSysex(SHIFT_DATA, 2 + 2 * count, reply);
+}
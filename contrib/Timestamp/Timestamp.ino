@@ -0,0 +1,39 @@
+/*
+  Copyright 2014 Krishna Raman
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+/*
+  TIMESTAMP feature for firmata.WithTimestamps(firmata.TimestampBoard).
+  Paste it into a StandardFirmata based sketch and call
+  timestampSysex() right before each report to stamp, for instance
+  before outputPort() in checkDigitalInputs() and before
+  Firmata.sendAnalog() in loop().
+
+  TIMESTAMP: u0 u1 u2 u3 u4 (micros(), 7 bits per byte LSB first)
+*/
+
+#define TIMESTAMP 0x0F
+
+void timestampSysex()
+{
+  unsigned long us = micros();
+  Serial.write(START_SYSEX);
+  Serial.write(TIMESTAMP);
+  for (byte i = 0; i < 5; i++) {
+    Serial.write((byte)(us & 0x7F));
+    us >>= 7;
+  }
+  Serial.write(END_SYSEX);
+}
@@ -0,0 +1,38 @@
+/*
+  Copyright 2014 Krishna Raman
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+/*
+  UPTIME feature for Client.QueryUptime and Client.SyncTime. Paste it
+  into a StandardFirmata based sketch and call uptimeSysex() from the
+  sysex callback for UPTIME.
+
+  UPTIME query: (no data)
+  UPTIME reply: m0 m1 m2 m3 m4 (millis(), 7 bits per byte LSB first)
+*/
+
+#define UPTIME 0x0A
+
+void uptimeSysex()
+{
+  unsigned long ms = millis();
+  Serial.write(START_SYSEX);
+  Serial.write(UPTIME);
+  for (byte i = 0; i < 5; i++) {
+    Serial.write((byte)(ms & 0x7F));
+    ms >>= 7;
+  }
+  Serial.write(END_SYSEX);
+}
@@ -0,0 +1,174 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package control runs closed loops on a board, such as holding a
+// temperature or a motor speed, with a PID controller that reads an
+// analog input and drives a PWM output.
+package control
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+)
+
+// PID is a proportional-integral-derivative controller. Its output is
+// clamped to [Min, Max], and the integral stops growing while the
+// output is clamped so that it doesn't wind up.
+type PID struct {
+	Kp, Ki, Kd float64
+	Min, Max   float64
+
+	integral float64
+	last     float64 // last measurement
+	started  bool
+}
+
+// Update returns the output for a measurement taken dt after the
+// previous one. The derivative acts on the measurement rather than the
+// error, so changes of the setpoint don't kick the output.
+func (p *PID) Update(setpoint, measured float64, dt time.Duration) float64 {
+	s := dt.Seconds()
+	err := setpoint - measured
+	var deriv float64
+	if p.started && s > 0 {
+		deriv = -(measured - p.last) / s
+	}
+	p.last, p.started = measured, true
+
+	integral := p.integral + err*s
+	out := p.Kp*err + p.Ki*integral + p.Kd*deriv
+	switch {
+	case out > p.Max:
+		out = p.Max
+		if err < 0 {
+			p.integral = integral
+		}
+	case out < p.Min:
+		out = p.Min
+		if err > 0 {
+			p.integral = integral
+		}
+	default:
+		p.integral = integral
+	}
+	return out
+}
+
+// Reset clears the integral and the last measurement.
+func (p *PID) Reset() {
+	p.integral, p.last, p.started = 0, 0, false
+}
+
+// Loop holds an analog input at a setpoint by driving a PWM output.
+type Loop struct {
+	c   *firmata.Client
+	in  uint8
+	out uint8
+
+	// PID computes the output. Both its Min and Max zero mean the full
+	// range of the PWM pin.
+	PID PID
+
+	// Interval is the time between two updates of the output.
+	Interval time.Duration
+
+	mu       sync.Mutex
+	setpoint float64
+}
+
+// New returns a loop reading the analog pin in and driving the PWM pin
+// out every interval. The measurement is the Scaled value of the pin
+// events, so SetPinMeta or SetPipeline can convert it to a unit.
+func New(c *firmata.Client, in, out uint8, pid PID, interval time.Duration) *Loop {
+	return &Loop{c: c, in: in, out: out, PID: pid, Interval: interval}
+}
+
+// SetSetpoint sets the value the loop holds the input at.
+func (l *Loop) SetSetpoint(v float64) {
+	l.mu.Lock()
+	l.setpoint = v
+	l.mu.Unlock()
+}
+
+// Setpoint returns the value the loop holds the input at.
+func (l *Loop) Setpoint() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.setpoint
+}
+
+// Run sets up the pins and updates the output until ctx is done. The
+// output is driven to PID.Min when Run returns. No update is made
+// before the first sample of the input arrives.
+func (l *Loop) Run(ctx context.Context) error {
+	if l.Interval <= 0 {
+		return errors.New("control: interval must be positive")
+	}
+	if err := l.c.SetPinMode(l.out, firmata.PWM); err != nil {
+		return err
+	}
+	if l.PID.Min == 0 && l.PID.Max == 0 {
+		res := l.c.Resolution(l.out, firmata.PWM)
+		if res <= 0 {
+			res = 8
+		}
+		l.PID.Max = float64(int(1)<<uint(res) - 1)
+	}
+	if err := l.c.EnableAnalogInput(uint(l.in), true); err != nil {
+		return err
+	}
+	samples, cancel := l.c.SubscribePin(int(l.in))
+	defer cancel()
+	defer l.write(l.PID.Min)
+
+	t := time.NewTicker(l.Interval)
+	defer t.Stop()
+	var (
+		measured float64
+		have     bool
+		prev     time.Time
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev, ok := <-samples:
+			if !ok {
+				return errors.New("control: input events stopped")
+			}
+			measured, have = ev.Scaled, true
+		case now := <-t.C:
+			if !have {
+				continue
+			}
+			dt := l.Interval
+			if !prev.IsZero() {
+				dt = now.Sub(prev)
+			}
+			prev = now
+			if err := l.write(l.PID.Update(l.Setpoint(), measured, dt)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (l *Loop) write(v float64) error {
+	return l.c.AnalogWriteValue(l.out, int(math.Round(v)))
+}
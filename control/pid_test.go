@@ -0,0 +1,62 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package control_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rakyll/go-firmata/control"
+)
+
+func TestPID(t *testing.T) {
+	p := control.PID{Kp: 2, Ki: 1, Min: 0, Max: 100}
+	if out := p.Update(10, 5, time.Second); out != 15 { // 2*5 + 1*5
+		t.Errorf("first update = %v; want 15", out)
+	}
+	if out := p.Update(10, 5, time.Second); out != 20 { // 2*5 + 1*10
+		t.Errorf("second update = %v; want 20", out)
+	}
+	p.Reset()
+	if out := p.Update(10, 10, time.Second); out != 0 {
+		t.Errorf("update after Reset = %v; want 0", out)
+	}
+}
+
+func TestPIDDerivativeOnMeasurement(t *testing.T) {
+	p := control.PID{Kd: 1, Min: -100, Max: 100}
+	p.Update(0, 0, time.Second)
+	// A setpoint change alone doesn't kick the output.
+	if out := p.Update(50, 0, time.Second); out != 0 {
+		t.Errorf("update after a setpoint change = %v; want 0", out)
+	}
+	if out := p.Update(50, 4, 2*time.Second); out != -2 {
+		t.Errorf("update of a rising measurement = %v; want -2", out)
+	}
+}
+
+func TestPIDAntiWindup(t *testing.T) {
+	p := control.PID{Ki: 1, Min: 0, Max: 10}
+	for i := 0; i < 100; i++ {
+		if out := p.Update(100, 0, time.Second); out != 10 {
+			t.Fatalf("update %d = %v; want the clamped 10", i, out)
+		}
+	}
+	// Without wind up the output leaves the clamp as soon as the error
+	// changes sign.
+	if out := p.Update(0, 100, time.Second); out >= 10 {
+		t.Errorf("update past the setpoint = %v; want below 10", out)
+	}
+}
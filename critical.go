@@ -0,0 +1,83 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"context"
+	"fmt"
+)
+
+// defaultCriticalRetries is the number of times a critical write is
+// retried unless set with WithCriticalRetries.
+const defaultCriticalRetries = 3
+
+// WithCriticalRetries sets how many times a critical write that the
+// board doesn't confirm is written again.
+func WithCriticalRetries(n int) Option {
+	return func(c *Client) {
+		c.criticalRetries = &n
+	}
+}
+
+// MarkCritical makes every DigitalWrite and AnalogWrite to pin
+// critical: the client queries the state of the pin after writing it
+// and writes again until the board reports the written value, for
+// writes that must not be lost on a lossy link. Pins with a safe state
+// are always written that way when the client is closed.
+func (c *Client) MarkCritical(pin uint8) error {
+	if err := c.checkPin(int(pin)); err != nil {
+		return err
+	}
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.critical == nil {
+		c.critical = make(map[uint8]bool)
+	}
+	c.critical[pin] = true
+	return nil
+}
+
+func (c *Client) isCritical(pin uint8) bool {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	return c.critical[pin]
+}
+
+// confirm checks that the board reports value for pin, calling write
+// again until it does or the retries are exhausted.
+func (c *Client) confirm(ctx context.Context, pin uint8, value int, write func() error) error {
+	retries := defaultCriticalRetries
+	if c.criticalRetries != nil {
+		retries = *c.criticalRetries
+	}
+	var err error
+	for attempt := 0; ; attempt++ {
+		var s PinState
+		s, err = c.QueryPinState(ctx, pin)
+		if err == nil && s.State == value {
+			return nil
+		}
+		if err == nil {
+			err = fmt.Errorf("board reports %d", s.State)
+		}
+		if attempt == retries || ctx.Err() != nil {
+			break
+		}
+		if werr := write(); werr != nil {
+			return werr
+		}
+	}
+	return fmt.Errorf("write of %d to pin %v not confirmed: %v", value, pin, err)
+}
@@ -0,0 +1,92 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import "fmt"
+
+// DiagnosticKind is the kind of a protocol anomaly.
+type DiagnosticKind int
+
+const (
+	// UnknownSysEx is a SysEx message no feature or handler decodes.
+	UnknownSysEx DiagnosticKind = iota + 1
+
+	// TruncatedFrame is a SysEx message interrupted by another command.
+	TruncatedFrame
+
+	// OversizedFrame is a SysEx message longer than the limit of the
+	// client, see WithParserLimits.
+	OversizedFrame
+
+	// PinOutOfRange is a report of a pin, port or analog channel the
+	// board didn't announce.
+	PinOutOfRange
+
+	// UnexpectedReset is a restart of the board after the handshake,
+	// announced by a version report and corroborated by a firmware
+	// report or the loss of the pin modes.
+	UnexpectedReset
+)
+
+func (k DiagnosticKind) String() string {
+	switch k {
+	case UnknownSysEx:
+		return "unknown sysex"
+	case TruncatedFrame:
+		return "truncated frame"
+	case OversizedFrame:
+		return "oversized frame"
+	case PinOutOfRange:
+		return "pin out of range"
+	case UnexpectedReset:
+		return "unexpected reset"
+	}
+	return fmt.Sprintf("DiagnosticKind(%d)", int(k))
+}
+
+// DiagnosticEvent reports a protocol anomaly that the client otherwise
+// ignores, which usually points at a firmware misconfiguration.
+type DiagnosticEvent struct {
+	Header
+	Kind   DiagnosticKind
+	Detail string
+}
+
+// Diagnostics returns the channel of protocol anomalies. All callers
+// share the same channel. Events are dropped when it is full.
+func (c *Client) Diagnostics() <-chan DiagnosticEvent {
+	c.diagOnce.Do(func() { c.diagChan = Subscribe[DiagnosticEvent](c, Filter{}) })
+	return c.diagChan
+}
+
+func (c *Client) diagnose(kind DiagnosticKind, format string, args ...interface{}) {
+	c.bus.publish(DiagnosticEvent{Header{c.clock.Now()}, kind, fmt.Sprintf(format, args...)})
+}
+
+// diagnoseFrames reports the frames the decoder discarded since the
+// counts in last were taken, and updates them. Leftovers of an earlier
+// session, read before the handshake, are not reported.
+func (c *Client) diagnoseFrames(last *[2]uint64) {
+	truncated, overflows := c.parserStats.Truncated.Load(), c.parserStats.Overflows.Load()
+	if c.isInited() {
+		if n := truncated - last[0]; n > 0 {
+			c.diagnose(TruncatedFrame, "%d sysex messages lost their end", n)
+		}
+		if n := overflows - last[1]; n > 0 {
+			c.diagnose(OversizedFrame, "%d sysex messages exceeded the limit", n)
+		}
+	}
+	last[0], last[1] = truncated, overflows
+}
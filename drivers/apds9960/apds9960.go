@@ -0,0 +1,354 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package apds9960 implements a driver for the APDS-9960 proximity,
+// ambient light, color and gesture sensor. The client must have I2C
+// enabled with I2CConfig.
+package apds9960
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+)
+
+// Address is the I2C address of the sensor.
+const Address = 0x39
+
+const (
+	regEnable  = 0x80
+	regATime   = 0x81
+	regPPulse  = 0x8E
+	regControl = 0x8F
+	regConfig2 = 0x90
+	regID      = 0x92
+	regCData   = 0x94
+	regPData   = 0x9C
+	regGPEnTh  = 0xA0
+	regGExTh   = 0xA1
+	regGConf1  = 0xA2
+	regGConf2  = 0xA3
+	regGPulse  = 0xA6
+	regGConf4  = 0xAB
+	regGFLevel = 0xAE
+	regGStatus = 0xAF
+	regGFIFO   = 0xFC
+
+	enablePower     = 0x01
+	enableALS       = 0x02
+	enableProximity = 0x04
+	enableWait      = 0x08
+	enableGesture   = 0x40
+
+	gestureSensitivity = 20 // minimum ratio change to report a gesture
+	gestureThreshold   = 10 // minimum photodiode count of a dataset
+)
+
+var ids = map[byte]bool{0xAB: true, 0x9C: true, 0xA8: true}
+
+// Color is a reading of the clear and RGB photodiodes.
+type Color struct {
+	Clear, Red, Green, Blue uint16
+}
+
+// Gesture is a detected hand movement.
+type Gesture int
+
+const (
+	Up Gesture = iota + 1
+	Down
+	Left
+	Right
+)
+
+func (g Gesture) String() string {
+	switch g {
+	case Up:
+		return "UP"
+	case Down:
+		return "DOWN"
+	case Left:
+		return "LEFT"
+	case Right:
+		return "RIGHT"
+	}
+	return "UNKNOWN"
+}
+
+// Channels carries the readings of a started sensor.
+type Channels struct {
+	Proximity <-chan byte
+	Color     <-chan Color
+	Gesture   <-chan Gesture
+}
+
+// Device is an APDS-9960 attached to the board.
+type Device struct {
+	c    *firmata.Client
+	addr byte
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+	err  error // the error that stopped polling
+}
+
+// New returns the sensor at addr. Init must be called before reading.
+func New(c *firmata.Client, addr byte) *Device {
+	return &Device{c: c, addr: addr}
+}
+
+// Init verifies the sensor identity and enables the proximity, color
+// and gesture engines.
+func (d *Device) Init() error {
+	id, err := d.read(regID)
+	if err != nil {
+		return err
+	}
+	if !ids[id] {
+		return errors.New("apds9960: unexpected device id")
+	}
+	return d.writeAll(
+		regEnable, 0x00,
+		regATime, 0xDB, // 103ms integration
+		regPPulse, 0x87, // 16us, 8 pulses
+		regControl, 0x09, // 100mA LED, 4x proximity gain, 4x ALS gain
+		regConfig2, 0x01,
+		regGPEnTh, 40,
+		regGExTh, 30,
+		regGConf1, 0x40, // interrupt after 4 datasets
+		regGConf2, 0x41, // 4x gain, 100mA LED, 2.8ms wait
+		regGPulse, 0xC9, // 32us, 10 pulses
+		regGConf4, 0x00,
+		regEnable, enablePower|enableALS|enableProximity|enableWait|enableGesture)
+}
+
+// Proximity returns the proximity reading; higher is closer.
+func (d *Device) Proximity() (byte, error) {
+	return d.read(regPData)
+}
+
+// Color returns the clear and RGB channel readings.
+func (d *Device) Color() (Color, error) {
+	b, err := d.c.I2CRead(d.addr, regCData, 8)
+	if err != nil {
+		return Color{}, err
+	}
+	if len(b) != 8 {
+		return Color{}, errors.New("apds9960: short read")
+	}
+	return Color{
+		Clear: uint16(b[1])<<8 | uint16(b[0]),
+		Red:   uint16(b[3])<<8 | uint16(b[2]),
+		Green: uint16(b[5])<<8 | uint16(b[4]),
+		Blue:  uint16(b[7])<<8 | uint16(b[6]),
+	}, nil
+}
+
+// Start polls the sensor every interval and delivers readings on the
+// returned channels. Slow receivers miss readings rather than stalling
+// the others. The channels are closed after Stop or on a read error,
+// which Err then returns.
+func (d *Device) Start(interval time.Duration) (*Channels, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stop != nil {
+		return nil, errors.New("apds9960: already started")
+	}
+	d.err = nil
+	prox := make(chan byte, 1)
+	color := make(chan Color, 1)
+	gestures := make(chan Gesture, 4)
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+	go d.loop(interval, prox, color, gestures, d.stop, d.done)
+	return &Channels{Proximity: prox, Color: color, Gesture: gestures}, nil
+}
+
+// Stop stops polling the sensor.
+func (d *Device) Stop() {
+	d.mu.Lock()
+	stop, done := d.stop, d.done
+	d.stop, d.done = nil, nil
+	d.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// Err returns the read error that stopped polling, or nil.
+func (d *Device) Err() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.err
+}
+
+func (d *Device) loop(interval time.Duration, prox chan byte, color chan Color, gestures chan Gesture, stop, done chan struct{}) {
+	defer close(done)
+	defer close(prox)
+	defer close(color)
+	defer close(gestures)
+	err := d.poll(interval, prox, color, gestures, stop)
+	d.mu.Lock()
+	d.err = err
+	d.mu.Unlock()
+}
+
+// poll reads the sensor until stop is closed or a read fails.
+func (d *Device) poll(interval time.Duration, prox chan byte, color chan Color, gestures chan Gesture, stop chan struct{}) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	var datasets [][4]byte
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-t.C:
+		}
+		p, err := d.Proximity()
+		if err != nil {
+			return err
+		}
+		trySend(prox, p)
+		c, err := d.Color()
+		if err != nil {
+			return err
+		}
+		trySend(color, c)
+
+		status, err := d.read(regGStatus)
+		if err != nil {
+			return err
+		}
+		if status&0x01 != 0 {
+			sets, err := d.readFIFO()
+			if err != nil {
+				return err
+			}
+			datasets = append(datasets, sets...)
+			continue
+		}
+		if len(datasets) > 0 {
+			if g := decodeGesture(datasets); g != 0 {
+				trySend(gestures, g)
+			}
+			datasets = nil
+		}
+	}
+}
+
+// fifoChunk is the number of datasets read at once: 32 bytes, the
+// largest I2C reply of Wire based firmwares.
+const fifoChunk = 8
+
+// readFIFO reads the available up/down/left/right datasets.
+func (d *Device) readFIFO() ([][4]byte, error) {
+	n, err := d.read(regGFLevel)
+	if err != nil || n == 0 {
+		return nil, err
+	}
+	sets := make([][4]byte, 0, n)
+	for left := int(n); left > 0; left -= fifoChunk {
+		k := left
+		if k > fifoChunk {
+			k = fifoChunk
+		}
+		b, err := d.c.I2CRead(d.addr, regGFIFO, k*4)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != k*4 {
+			return nil, errors.New("apds9960: short read")
+		}
+		for i := 0; i < len(b); i += 4 {
+			sets = append(sets, [4]byte{b[i], b[i+1], b[i+2], b[i+3]})
+		}
+	}
+	return sets, nil
+}
+
+// decodeGesture compares the up/down and left/right ratios of the first
+// and last significant datasets of a gesture.
+func decodeGesture(sets [][4]byte) Gesture {
+	var first, last *[4]byte
+	for i := range sets {
+		s := &sets[i]
+		if s[0] > gestureThreshold && s[1] > gestureThreshold && s[2] > gestureThreshold && s[3] > gestureThreshold {
+			if first == nil {
+				first = s
+			}
+			last = s
+		}
+	}
+	if first == nil || first == last {
+		return 0
+	}
+	ud := ratio(last[0], last[1]) - ratio(first[0], first[1])
+	lr := ratio(last[2], last[3]) - ratio(first[2], first[3])
+	if abs(ud) < gestureSensitivity && abs(lr) < gestureSensitivity {
+		return 0
+	}
+	if abs(ud) > abs(lr) {
+		if ud > 0 {
+			return Down
+		}
+		return Up
+	}
+	if lr > 0 {
+		return Right
+	}
+	return Left
+}
+
+func ratio(a, b byte) int {
+	return (int(a) - int(b)) * 100 / (int(a) + int(b))
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func trySend[T any](ch chan T, v T) {
+	select {
+	case ch <- v:
+	default:
+	}
+}
+
+func (d *Device) read(reg int) (byte, error) {
+	b, err := d.c.I2CRead(d.addr, reg, 1)
+	if err != nil {
+		return 0, err
+	}
+	if len(b) != 1 {
+		return 0, errors.New("apds9960: short read")
+	}
+	return b[0], nil
+}
+
+func (d *Device) writeAll(pairs ...byte) error {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if err := d.c.I2CWrite(d.addr, pairs[i], pairs[i+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apds9960
+
+import (
+	"testing"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/simulator"
+)
+
+func newDevice(t *testing.T) (*Device, *simulator.Board) {
+	b := simulator.New(nil)
+	c, err := firmata.NewClientConn(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if err := c.I2CConfig(0); err != nil {
+		t.Fatal(err)
+	}
+	return New(c, Address), b
+}
+
+func TestDecodeGesture(t *testing.T) {
+	tests := []struct {
+		name string
+		sets [][4]byte
+		want Gesture
+	}{
+		{"down", [][4]byte{{50, 100, 80, 80}, {75, 75, 80, 80}, {100, 50, 80, 80}}, Down},
+		{"up", [][4]byte{{100, 50, 80, 80}, {50, 100, 80, 80}}, Up},
+		{"right", [][4]byte{{80, 80, 50, 100}, {80, 80, 100, 50}}, Right},
+		{"left", [][4]byte{{80, 80, 100, 50}, {80, 80, 50, 100}}, Left},
+		{"too weak", [][4]byte{{5, 10, 8, 8}, {10, 5, 8, 8}}, 0},
+		{"one dataset", [][4]byte{{50, 100, 80, 80}, {10, 5, 8, 8}}, 0},
+		{"too little change", [][4]byte{{80, 80, 80, 80}, {85, 80, 80, 80}}, 0},
+	}
+	for _, tt := range tests {
+		if got := decodeGesture(tt.sets); got != tt.want {
+			t.Errorf("%s: decodeGesture = %v; want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadFIFO(t *testing.T) {
+	d, b := newDevice(t)
+	const level = fifoChunk + 2 // two reads
+	b.SetI2C(Address, regGFLevel, level)
+	fifo := make([]byte, 4*fifoChunk)
+	for i := range fifo {
+		fifo[i] = byte(i)
+	}
+	b.SetI2C(Address, regGFIFO, fifo...)
+	sets, err := d.readFIFO()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sets) != level {
+		t.Fatalf("readFIFO returned %d datasets; want %d", len(sets), level)
+	}
+	// The simulator answers each read from the start of the FIFO.
+	for i, s := range sets {
+		j := byte(i % fifoChunk * 4)
+		if want := [4]byte{j, j + 1, j + 2, j + 3}; s != want {
+			t.Errorf("dataset %d = %v; want %v", i, s, want)
+		}
+	}
+}
+
+func TestInitUnexpectedID(t *testing.T) {
+	d, b := newDevice(t)
+	b.SetI2C(Address, regID, 0x12)
+	if err := d.Init(); err == nil {
+		t.Error("Init of an unknown device succeeded")
+	}
+	b.SetI2C(Address, regID, 0xAB)
+	if err := d.Init(); err != nil {
+		t.Errorf("Init: %v", err)
+	}
+}
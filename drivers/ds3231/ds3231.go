@@ -0,0 +1,157 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ds3231 implements a driver for the DS3231 and DS1307 I2C
+// real-time clocks. The client must have I2C enabled with I2CConfig.
+package ds3231
+
+import (
+	"errors"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+)
+
+// Address is the I2C address of both the DS3231 and the DS1307.
+const Address = 0x68
+
+const (
+	regSeconds     = 0x00
+	regAgingOffset = 0x10
+	regTempMSB     = 0x11
+)
+
+// ErrNotSupported is returned for features the DS1307 lacks.
+var ErrNotSupported = errors.New("ds3231: not supported by DS1307")
+
+// Device is a real-time clock attached to the board.
+type Device struct {
+	c      *firmata.Client
+	ds1307 bool
+}
+
+// New returns a DS3231 clock.
+func New(c *firmata.Client) *Device {
+	return &Device{c: c}
+}
+
+// NewDS1307 returns a DS1307 clock. It shares the time registers with
+// the DS3231 but has no aging offset or temperature sensor.
+func NewDS1307(c *firmata.Client) *Device {
+	return &Device{c: c, ds1307: true}
+}
+
+// Time reads the current time. The clock has no notion of time zones,
+// the result is in UTC.
+func (d *Device) Time() (time.Time, error) {
+	b, err := d.c.I2CRead(Address, regSeconds, 7)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(b) != 7 {
+		return time.Time{}, errors.New("ds3231: short read")
+	}
+	sec := fromBCD(b[0] & 0x7F)
+	min := fromBCD(b[1] & 0x7F)
+	hour := fromBCD(b[2] & 0x3F)
+	if b[2]&0x40 != 0 {
+		// 12 hour mode, bit 5 is PM.
+		hour = fromBCD(b[2] & 0x1F)
+		if hour == 12 {
+			hour = 0
+		}
+		if b[2]&0x20 != 0 {
+			hour += 12
+		}
+	}
+	day := fromBCD(b[4] & 0x3F)
+	month := time.Month(fromBCD(b[5] & 0x1F))
+	year := 2000 + fromBCD(b[6])
+	if !d.ds1307 && b[5]&0x80 != 0 {
+		year += 100
+	}
+	return time.Date(year, month, day, hour, min, sec, 0, time.UTC), nil
+}
+
+// SetTime sets the clock to t, converted to UTC, in 24 hour mode. On
+// the DS1307 it also starts the oscillator if it was halted.
+func (d *Device) SetTime(t time.Time) error {
+	t = t.UTC()
+	year := t.Year() - 2000
+	if year < 0 || year > 199 || (d.ds1307 && year > 99) {
+		return errors.New("ds3231: year out of range")
+	}
+	month := toBCD(int(t.Month()))
+	if year > 99 {
+		month |= 0x80
+		year -= 100
+	}
+	return d.c.I2CWrite(Address, regSeconds,
+		toBCD(t.Second()),
+		toBCD(t.Minute()),
+		toBCD(t.Hour()),
+		byte(t.Weekday())+1,
+		toBCD(t.Day()),
+		month,
+		toBCD(year))
+}
+
+// AgingOffset reads the aging offset register used to trim the
+// oscillator frequency.
+func (d *Device) AgingOffset() (int8, error) {
+	if d.ds1307 {
+		return 0, ErrNotSupported
+	}
+	b, err := d.c.I2CRead(Address, regAgingOffset, 1)
+	if err != nil {
+		return 0, err
+	}
+	if len(b) != 1 {
+		return 0, errors.New("ds3231: short read")
+	}
+	return int8(b[0]), nil
+}
+
+// SetAgingOffset writes the aging offset register. Positive values slow
+// the clock down, negative values speed it up.
+func (d *Device) SetAgingOffset(offset int8) error {
+	if d.ds1307 {
+		return ErrNotSupported
+	}
+	return d.c.I2CWrite(Address, regAgingOffset, byte(offset))
+}
+
+// Temperature reads the die temperature in degrees Celsius with a
+// resolution of 0.25°C.
+func (d *Device) Temperature() (float64, error) {
+	if d.ds1307 {
+		return 0, ErrNotSupported
+	}
+	b, err := d.c.I2CRead(Address, regTempMSB, 2)
+	if err != nil {
+		return 0, err
+	}
+	if len(b) != 2 {
+		return 0, errors.New("ds3231: short read")
+	}
+	return float64(int8(b[0])) + float64(b[1]>>6)*0.25, nil
+}
+
+func fromBCD(b byte) int {
+	return int(b>>4)*10 + int(b&0x0F)
+}
+
+func toBCD(v int) byte {
+	return byte(v/10)<<4 | byte(v%10)
+}
@@ -0,0 +1,109 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ds3231_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/drivers/ds3231"
+	"github.com/rakyll/go-firmata/simulator"
+)
+
+func newClient(t *testing.T) (*firmata.Client, *simulator.Board) {
+	b := simulator.New(nil)
+	c, err := firmata.NewClientConn(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if err := c.I2CConfig(0); err != nil {
+		t.Fatal(err)
+	}
+	return c, b
+}
+
+func TestSetTime(t *testing.T) {
+	c, _ := newClient(t)
+	d := ds3231.New(c)
+	for _, want := range []time.Time{
+		time.Date(2024, 2, 29, 23, 59, 58, 0, time.UTC),
+		time.Date(2107, 12, 31, 0, 0, 0, 0, time.UTC), // century bit
+	} {
+		if err := d.SetTime(want); err != nil {
+			t.Fatal(err)
+		}
+		got, err := d.Time()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(want) {
+			t.Errorf("Time() = %v; want %v", got, want)
+		}
+	}
+	if err := d.SetTime(time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("SetTime in 1999 succeeded")
+	}
+	if err := ds3231.NewDS1307(c).SetTime(time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("SetTime in 2100 succeeded on a DS1307")
+	}
+}
+
+func TestTime12Hour(t *testing.T) {
+	tests := []struct {
+		hour byte
+		want int
+	}{
+		{0x40 | 0x12, 0},         // 12 AM
+		{0x40 | 0x20 | 0x12, 12}, // 12 PM
+		{0x40 | 0x20 | 0x03, 15}, // 3 PM
+		{0x40 | 0x11, 11},        // 11 AM
+	}
+	for _, tt := range tests {
+		c, b := newClient(t)
+		b.SetI2C(ds3231.Address, 0, 0x30, 0x15, tt.hour, 0x01, 0x17, 0x10, 0x26)
+		got, err := ds3231.New(c).Time()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Hour() != tt.want {
+			t.Errorf("hour register %#x read as %d; want %d", tt.hour, got.Hour(), tt.want)
+		}
+	}
+}
+
+func TestTemperature(t *testing.T) {
+	c, b := newClient(t)
+	b.SetI2C(ds3231.Address, 0x11, 0xE7, 0xC0) // -25 + 0.75
+	got, err := ds3231.New(c).Temperature()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != -24.25 {
+		t.Errorf("Temperature() = %v; want -24.25", got)
+	}
+}
+
+func TestDS1307NotSupported(t *testing.T) {
+	c, _ := newClient(t)
+	d := ds3231.NewDS1307(c)
+	if _, err := d.Temperature(); err != ds3231.ErrNotSupported {
+		t.Errorf("Temperature() error = %v; want ErrNotSupported", err)
+	}
+	if err := d.SetAgingOffset(1); err != ds3231.ErrNotSupported {
+		t.Errorf("SetAgingOffset() error = %v; want ErrNotSupported", err)
+	}
+}
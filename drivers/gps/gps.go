@@ -0,0 +1,183 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gps reads NMEA 0183 sentences from a GPS module wired to one
+// of the board's serial ports and turns them into position fixes.
+//
+//	c.SerialConfig(firmata.HardSerial1, 9600, 0, 0)
+//	for fix := range gps.Listen(c.SerialData()) {
+//		fmt.Println(fix.Latitude, fix.Longitude)
+//	}
+package gps
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const knotsToMetersPerSecond = 0.514444
+
+// Fix is a position, velocity and time solution.
+type Fix struct {
+	Time      time.Time
+	Latitude  float64 // degrees, negative south
+	Longitude float64 // degrees, negative west
+	Altitude  float64 // meters above mean sea level
+	Speed     float64 // meters per second over ground
+	Course    float64 // degrees from true north
+
+	// Quality is the GGA fix quality, 0 meaning no fix.
+	Quality    int
+	Satellites int
+
+	// Valid reports whether the receiver flagged the RMC data as valid.
+	Valid bool
+}
+
+// Parser assembles NMEA lines from arbitrarily split chunks of serial
+// data. It emits a Fix for every RMC sentence, completed with the
+// altitude and satellite data of the latest GGA sentence.
+type Parser struct {
+	buf []byte
+	gga Fix
+}
+
+// Feed adds a chunk of serial data and returns the fixes completed by it.
+// Malformed sentences and sentences with bad checksums are skipped.
+func (p *Parser) Feed(chunk string) []Fix {
+	var fixes []Fix
+	p.buf = append(p.buf, chunk...)
+	for {
+		i := strings.IndexByte(string(p.buf), '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimSpace(string(p.buf[:i]))
+		p.buf = p.buf[i+1:]
+		fix, ok := p.parseLine(line)
+		if ok {
+			fixes = append(fixes, fix)
+		}
+	}
+	if len(p.buf) > 512 {
+		// No line ending in sight; the stream is garbage.
+		p.buf = p.buf[:0]
+	}
+	return fixes
+}
+
+func (p *Parser) parseLine(line string) (Fix, bool) {
+	fields, err := Split(line)
+	if err != nil || len(fields[0]) < 5 {
+		return Fix{}, false
+	}
+	switch fields[0][2:] {
+	case "GGA":
+		if len(fields) < 10 {
+			return Fix{}, false
+		}
+		p.gga.Quality, _ = strconv.Atoi(fields[6])
+		p.gga.Satellites, _ = strconv.Atoi(fields[7])
+		p.gga.Altitude, _ = strconv.ParseFloat(fields[9], 64)
+	case "RMC":
+		if len(fields) < 10 {
+			return Fix{}, false
+		}
+		fix := p.gga
+		fix.Valid = fields[2] == "A"
+		fix.Latitude = coordinate(fields[3], fields[4])
+		fix.Longitude = coordinate(fields[5], fields[6])
+		knots, _ := strconv.ParseFloat(fields[7], 64)
+		fix.Speed = knots * knotsToMetersPerSecond
+		fix.Course, _ = strconv.ParseFloat(fields[8], 64)
+		fix.Time = timestamp(fields[9], fields[1])
+		return fix, true
+	}
+	return Fix{}, false
+}
+
+// Split verifies the checksum of an NMEA sentence and returns its
+// comma separated fields, starting with the talker and sentence ID.
+func Split(sentence string) ([]string, error) {
+	if !strings.HasPrefix(sentence, "$") {
+		return nil, errors.New("gps: missing $")
+	}
+	body := sentence[1:]
+	if i := strings.LastIndexByte(body, '*'); i >= 0 {
+		want, err := strconv.ParseUint(body[i+1:], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("gps: bad checksum %q", body[i+1:])
+		}
+		body = body[:i]
+		var sum byte
+		for j := 0; j < len(body); j++ {
+			sum ^= body[j]
+		}
+		if sum != byte(want) {
+			return nil, errors.New("gps: checksum mismatch")
+		}
+	}
+	return strings.Split(body, ","), nil
+}
+
+// coordinate converts a (d)ddmm.mmmm value and hemisphere to degrees.
+func coordinate(v, hemi string) float64 {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0
+	}
+	deg := float64(int(f / 100))
+	deg += (f - deg*100) / 60
+	if hemi == "S" || hemi == "W" {
+		deg = -deg
+	}
+	return deg
+}
+
+// timestamp combines an RMC ddmmyy date and hhmmss.ss time in UTC.
+func timestamp(date, clock string) time.Time {
+	if len(date) != 6 || len(clock) < 6 {
+		return time.Time{}
+	}
+	t, err := time.Parse("020106150405", date+clock[:6])
+	if err != nil {
+		return time.Time{}
+	}
+	if len(clock) > 7 && clock[6] == '.' {
+		if frac, err := strconv.ParseFloat("0"+clock[6:], 64); err == nil {
+			t = t.Add(time.Duration(frac * float64(time.Second)))
+		}
+	}
+	return t
+}
+
+// Listen parses serial data as delivered by Client.SerialData and
+// delivers fixes on the returned channel, which is closed when data is
+// closed.
+func Listen(data <-chan string) <-chan Fix {
+	out := make(chan Fix)
+	go func() {
+		defer close(out)
+		var p Parser
+		for chunk := range data {
+			for _, fix := range p.Feed(chunk) {
+				out <- fix
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,103 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gps
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+const (
+	gga = "$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*47\r\n"
+	rmc = "$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W*6A\r\n"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestFeed(t *testing.T) {
+	var p Parser
+	var fixes []Fix
+	// Feed the sentences in chunks that split them anywhere.
+	stream := gga + rmc
+	for len(stream) > 0 {
+		n := 7
+		if n > len(stream) {
+			n = len(stream)
+		}
+		fixes = append(fixes, p.Feed(stream[:n])...)
+		stream = stream[n:]
+	}
+	if len(fixes) != 1 {
+		t.Fatalf("got %d fixes; want 1", len(fixes))
+	}
+	fix := fixes[0]
+	if !fix.Valid || fix.Quality != 1 || fix.Satellites != 8 {
+		t.Errorf("Valid, Quality, Satellites = %v, %d, %d; want true, 1, 8", fix.Valid, fix.Quality, fix.Satellites)
+	}
+	if !near(fix.Latitude, 48+7.038/60) || !near(fix.Longitude, 11+31.0/60) {
+		t.Errorf("position = %v, %v", fix.Latitude, fix.Longitude)
+	}
+	if !near(fix.Altitude, 545.4) || !near(fix.Speed, 22.4*knotsToMetersPerSecond) || !near(fix.Course, 84.4) {
+		t.Errorf("Altitude, Speed, Course = %v, %v, %v", fix.Altitude, fix.Speed, fix.Course)
+	}
+	if want := time.Date(1994, 3, 23, 12, 35, 19, 0, time.UTC); !fix.Time.Equal(want) {
+		t.Errorf("Time = %v; want %v", fix.Time, want)
+	}
+}
+
+func TestFeedSkipsBadChecksum(t *testing.T) {
+	var p Parser
+	bad := rmc[:len(rmc)-4] + "6B\r\n"
+	if fixes := p.Feed(bad); len(fixes) != 0 {
+		t.Errorf("Feed of a bad checksum returned %v", fixes)
+	}
+	if fixes := p.Feed(rmc); len(fixes) != 1 {
+		t.Errorf("Feed after a bad sentence returned %d fixes; want 1", len(fixes))
+	}
+}
+
+func TestCoordinate(t *testing.T) {
+	tests := []struct {
+		v, hemi string
+		want    float64
+	}{
+		{"4807.038", "N", 48.1173},
+		{"4807.038", "S", -48.1173},
+		{"01131.000", "W", -11.516666666},
+		{"", "E", 0},
+	}
+	for _, tt := range tests {
+		if got := coordinate(tt.v, tt.hemi); math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("coordinate(%q, %q) = %v; want %v", tt.v, tt.hemi, got, tt.want)
+		}
+	}
+}
+
+func TestListen(t *testing.T) {
+	data := make(chan string, 2)
+	data <- gga
+	data <- rmc
+	close(data)
+	var n int
+	for range Listen(data) {
+		n++
+	}
+	if n != 1 {
+		t.Errorf("Listen delivered %d fixes; want 1", n)
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hc165 implements a driver for chains of 74HC165 parallel-in
+// serial-out shift registers, which add digital inputs to a board. The
+// whole chain is shifted in by the board in a single request: flash the
+// SHIFT_DATA feature from contrib/ShiftIn.
+//
+// The inputs are numbered as virtual pins, 0 to 7 for inputs A to H of
+// the register wired to the board, 8 to 15 for the next one and so on.
+package hc165
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/wire"
+)
+
+const (
+	subShiftIn = 0x01
+
+	readTimeout = time.Second
+)
+
+// Chain is a chain of 74HC165s sharing the clock and latch lines, with
+// the serial output of the last one on a digital pin of the board.
+type Chain struct {
+	c     *firmata.Client
+	data  byte
+	clock byte
+	latch byte
+	n     int
+
+	mu    sync.Mutex // serializes reads
+	last  []byte
+	valid bool
+
+	replies chan []byte
+}
+
+// Change is a change of the level of an input.
+type Change struct {
+	Pin  int
+	High bool
+	Time time.Time
+}
+
+// New returns the chain of chips registers read on the data, clock and
+// latch (SH/LD) pins.
+func New(c *firmata.Client, data, clock, latch byte, chips int) (*Chain, error) {
+	if chips < 1 || chips > 32 {
+		return nil, errors.New("hc165: invalid number of chips")
+	}
+	ch := &Chain{c: c, data: data, clock: clock, latch: latch, n: chips, replies: make(chan []byte, 1)}
+	f, err := featureOf(c)
+	if err != nil {
+		return nil, err
+	}
+	f.add(ch)
+	return ch, nil
+}
+
+// Pins returns the number of inputs of the chain.
+func (ch *Chain) Pins() int {
+	return ch.n * 8
+}
+
+// Read latches and returns the levels of all the inputs.
+func (ch *Chain) Read() ([]bool, error) {
+	b, err := ch.read()
+	if err != nil {
+		return nil, err
+	}
+	levels := make([]bool, ch.Pins())
+	for i := range levels {
+		levels[i] = high(b, i)
+	}
+	return levels, nil
+}
+
+// Pin latches the inputs and returns the level of pin.
+func (ch *Chain) Pin(pin int) (bool, error) {
+	if pin < 0 || pin >= ch.Pins() {
+		return false, errors.New("hc165: invalid pin")
+	}
+	b, err := ch.read()
+	if err != nil {
+		return false, err
+	}
+	return high(b, pin), nil
+}
+
+// Watch reads the inputs every interval and sends their changes on the
+// returned channel, which is closed by the returned stop function.
+// The first read reports the inputs that are high. Changes are dropped
+// when the channel is full and reads that fail are retried at the next
+// interval.
+func (ch *Chain) Watch(interval time.Duration) (<-chan Change, func()) {
+	changes := make(chan Change, 64)
+	done := make(chan struct{})
+	go func() {
+		defer close(changes)
+		prev := make([]byte, ch.n)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			if b, err := ch.read(); err == nil {
+				now := time.Now()
+				for i := 0; i < ch.Pins(); i++ {
+					if high(b, i) == high(prev, i) {
+						continue
+					}
+					select {
+					case changes <- Change{i, high(b, i), now}:
+					default:
+					}
+				}
+				prev = b
+			}
+			select {
+			case <-t.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return changes, func() { once.Do(func() { close(done) }) }
+}
+
+func high(b []byte, pin int) bool {
+	return b[pin/8]&(1<<uint(pin%8)) != 0
+}
+
+// read returns the bytes shifted out of the chain, one per chip
+// starting with the chip wired to the board, input H in the top bit.
+func (ch *Chain) read() ([]byte, error) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	select {
+	case <-ch.replies: // drop a stale reply of a timed out read
+	default:
+	}
+	err := ch.c.SendSysEx(firmata.ShiftData, subShiftIn, ch.data&0x7F, ch.clock&0x7F, ch.latch&0x7F, byte(ch.n))
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case b := <-ch.replies:
+		if len(b) != ch.n {
+			return nil, errors.New("hc165: short read")
+		}
+		return b, nil
+	case <-time.After(readTimeout):
+		return nil, errors.New("hc165: read timed out")
+	}
+}
+
+// feature is the SHIFT_DATA feature registered with a client, which
+// hands the replies to its chains by data pin. It is torn down with the
+// client.
+type feature struct {
+	mu     sync.Mutex
+	chains map[byte]*Chain
+}
+
+// registerMu keeps concurrent calls to New from registering two features
+// with a client.
+var registerMu sync.Mutex
+
+func featureOf(c *firmata.Client) (*feature, error) {
+	registerMu.Lock()
+	defer registerMu.Unlock()
+	if f, ok := c.RegisteredFeature(firmata.ShiftData).(*feature); ok {
+		return f, nil
+	}
+	f := &feature{chains: make(map[byte]*Chain)}
+	if err := c.Register(f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *feature) add(ch *Chain) {
+	f.mu.Lock()
+	f.chains[ch.data] = ch
+	f.mu.Unlock()
+}
+
+func (f *feature) SysExCommands() []firmata.SysExCommand {
+	return []firmata.SysExCommand{firmata.ShiftData}
+}
+
+func (f *feature) Setup(c *firmata.Client) error { return nil }
+
+func (f *feature) Teardown(c *firmata.Client) error {
+	f.mu.Lock()
+	f.chains = make(map[byte]*Chain)
+	f.mu.Unlock()
+	return nil
+}
+
+// Decode hands a shift in reply to its chain: subShiftIn, data pin and
+// each byte as two 7-bit bytes, LSB first.
+func (f *feature) Decode(cmd firmata.SysExCommand, data []byte) firmata.Event {
+	if len(data) < 2 || data[0] != subShiftIn {
+		return nil
+	}
+	f.mu.Lock()
+	ch := f.chains[data[1]]
+	f.mu.Unlock()
+	if ch == nil {
+		return nil
+	}
+	b := wire.DecodeBytes(data[2:])
+	select {
+	case ch.replies <- b:
+	default:
+	}
+	return nil
+}
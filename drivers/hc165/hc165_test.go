@@ -0,0 +1,153 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hc165_test
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/drivers/hc165"
+	"github.com/rakyll/go-firmata/simulator"
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// board is a simulated board running the SHIFT_DATA feature, which
+// answers shift ins with the bytes set for each data pin.
+type board struct {
+	*simulator.Board
+
+	mu     sync.Mutex
+	inputs map[byte][]byte
+}
+
+func newBoard() *board {
+	return &board{Board: simulator.New(nil), inputs: make(map[byte][]byte)}
+}
+
+func (b *board) set(data byte, inputs ...byte) {
+	b.mu.Lock()
+	b.inputs[data] = inputs
+	b.mu.Unlock()
+}
+
+func (b *board) Write(p []byte) (int, error) {
+	d := wire.NewDecoder(bytes.NewReader(p))
+	for {
+		m, err := d.Decode()
+		if err != nil {
+			break
+		}
+		s, ok := m.(wire.SysEx)
+		if !ok || s.Command != byte(firmata.ShiftData) || len(s.Data) < 2 || s.Data[0] != 0x01 {
+			continue
+		}
+		b.mu.Lock()
+		data := append([]byte{0x01, s.Data[1]}, wire.EncodeBytes(b.inputs[s.Data[1]])...)
+		b.mu.Unlock()
+		b.Inject(wire.SysEx{Command: byte(firmata.ShiftData), Data: data}.Bytes())
+	}
+	return b.Board.Write(p)
+}
+
+func newChain(t *testing.T, chips int) (*hc165.Chain, *board) {
+	b := newBoard()
+	c, err := firmata.NewClientConn(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	ch, err := hc165.New(c, 2, 3, 4, chips)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ch, b
+}
+
+func TestRead(t *testing.T) {
+	ch, b := newChain(t, 2)
+	b.set(2, 0x81, 0x02)
+	levels, err := ch.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(levels) != 16 {
+		t.Fatalf("Read() returned %d levels; want 16", len(levels))
+	}
+	for i, high := range levels {
+		want := i == 0 || i == 7 || i == 9
+		if high != want {
+			t.Errorf("pin %d high = %v; want %v", i, high, want)
+		}
+	}
+	if high, err := ch.Pin(9); err != nil || !high {
+		t.Errorf("Pin(9) = %v, %v; want true, nil", high, err)
+	}
+	if _, err := ch.Pin(16); err == nil {
+		t.Error("Pin(16) succeeded")
+	}
+
+	b.set(2, 0x81) // one byte short
+	if _, err := ch.Read(); err == nil {
+		t.Error("Read of a short reply succeeded")
+	}
+}
+
+func TestNewInvalidChips(t *testing.T) {
+	c, err := firmata.NewClientConn(simulator.New(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, n := range []int{0, 33} {
+		if _, err := hc165.New(c, 2, 3, 4, n); err == nil {
+			t.Errorf("New with %d chips succeeded", n)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	ch, b := newChain(t, 1)
+	b.set(2, 0x01)
+	changes, stop := ch.Watch(time.Millisecond)
+	defer stop()
+
+	next := func() hc165.Change {
+		select {
+		case c := <-changes:
+			return c
+		case <-time.After(time.Second):
+			t.Fatal("no change")
+			return hc165.Change{}
+		}
+	}
+	if c := next(); c.Pin != 0 || !c.High {
+		t.Errorf("first change = %+v; want pin 0 high", c)
+	}
+	b.set(2, 0x04)
+	got := map[int]bool{}
+	for i := 0; i < 2; i++ {
+		c := next()
+		got[c.Pin] = c.High
+	}
+	if len(got) != 2 || got[0] || !got[2] {
+		t.Errorf("changes = %v; want pin 0 low and pin 2 high", got)
+	}
+	stop()
+	for range changes {
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hx711 implements a driver for HX711 load cell amplifiers. The
+// HX711 needs microsecond clock timing, so the bit-banging runs on the
+// board: flash the HX711_DATA feature from contrib/HX711.
+package hx711
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// SysEx is the user-defined SysEx command of the HX711_DATA feature.
+const SysEx firmata.SysExCommand = 0x0D
+
+const (
+	subConfig = 0x01
+	subRead   = 0x02
+
+	readTimeout = time.Second
+)
+
+// Gain selects the input channel and its gain.
+type Gain byte
+
+const (
+	Gain128 Gain = 1 // channel A
+	Gain32  Gain = 2 // channel B
+	Gain64  Gain = 3 // channel A
+)
+
+// Device is an HX711 with its DOUT and SCK lines on two digital pins.
+type Device struct {
+	c    *firmata.Client
+	dout byte
+	sck  byte
+
+	mu     sync.Mutex
+	offset float64
+	scale  float64
+
+	replies chan int32
+}
+
+// New configures the HX711 with the given pins and gain.
+func New(c *firmata.Client, dout, sck byte, gain Gain) (*Device, error) {
+	d := &Device{c: c, dout: dout, sck: sck, scale: 1, replies: make(chan int32, 1)}
+	f, err := featureOf(c)
+	if err != nil {
+		return nil, err
+	}
+	f.add(d)
+	if err := c.SendSysEx(SysEx, subConfig, dout&0x7F, sck&0x7F, byte(gain)); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Raw reads a single raw 24-bit conversion.
+func (d *Device) Raw() (int32, error) {
+	select {
+	case <-d.replies: // drop a stale reply of a timed out read
+	default:
+	}
+	if err := d.c.SendSysEx(SysEx, subRead, d.dout&0x7F); err != nil {
+		return 0, err
+	}
+	select {
+	case v := <-d.replies:
+		return v, nil
+	case <-time.After(readTimeout):
+		return 0, errors.New("hx711: read timed out")
+	}
+}
+
+// Tare takes the median of samples readings as the zero offset.
+func (d *Device) Tare(samples int) error {
+	v, err := d.median(samples)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.offset = v
+	d.mu.Unlock()
+	return nil
+}
+
+// SetScale sets the calibration factor in raw units per unit of weight.
+func (d *Device) SetScale(factor float64) {
+	d.mu.Lock()
+	d.scale = factor
+	d.mu.Unlock()
+}
+
+// Calibrate computes the calibration factor from a known weight placed
+// on the tared scale.
+func (d *Device) Calibrate(known float64, samples int) error {
+	if known == 0 {
+		return errors.New("hx711: known weight must not be zero")
+	}
+	v, err := d.median(samples)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.scale = (v - d.offset) / known
+	d.mu.Unlock()
+	return nil
+}
+
+// Weight returns the weight in calibrated units. It takes the median of
+// samples readings to filter out spikes.
+func (d *Device) Weight(samples int) (float64, error) {
+	v, err := d.median(samples)
+	if err != nil {
+		return 0, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return (v - d.offset) / d.scale, nil
+}
+
+func (d *Device) median(samples int) (float64, error) {
+	if samples < 1 {
+		samples = 1
+	}
+	values := make([]int32, samples)
+	for i := range values {
+		v, err := d.Raw()
+		if err != nil {
+			return 0, err
+		}
+		values[i] = v
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	if samples%2 == 1 {
+		return float64(values[samples/2]), nil
+	}
+	return (float64(values[samples/2-1]) + float64(values[samples/2])) / 2, nil
+}
+
+// feature is the HX711_DATA feature registered with a client, which
+// hands the replies to its amplifiers by DOUT pin. It is torn down with
+// the client.
+type feature struct {
+	mu      sync.Mutex
+	devices map[byte]*Device
+}
+
+// registerMu keeps concurrent calls to New from registering two features
+// with a client.
+var registerMu sync.Mutex
+
+func featureOf(c *firmata.Client) (*feature, error) {
+	registerMu.Lock()
+	defer registerMu.Unlock()
+	if f, ok := c.RegisteredFeature(SysEx).(*feature); ok {
+		return f, nil
+	}
+	f := &feature{devices: make(map[byte]*Device)}
+	if err := c.Register(f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *feature) add(d *Device) {
+	f.mu.Lock()
+	f.devices[d.dout] = d
+	f.mu.Unlock()
+}
+
+func (f *feature) SysExCommands() []firmata.SysExCommand {
+	return []firmata.SysExCommand{SysEx}
+}
+
+func (f *feature) Setup(c *firmata.Client) error { return nil }
+
+func (f *feature) Teardown(c *firmata.Client) error {
+	f.mu.Lock()
+	f.devices = make(map[byte]*Device)
+	f.mu.Unlock()
+	return nil
+}
+
+// Decode hands a read reply to its device: subRead, DOUT pin and the
+// 24-bit two's complement value as four 7-bit bytes, LSB first.
+func (f *feature) Decode(cmd firmata.SysExCommand, data []byte) firmata.Event {
+	if len(data) < 6 || data[0] != subRead {
+		return nil
+	}
+	f.mu.Lock()
+	d := f.devices[data[1]]
+	f.mu.Unlock()
+	if d == nil {
+		return nil
+	}
+	v := uint32(wire.DecodeUint(data[2:6]))
+	raw := int32(v<<8) >> 8 // sign extend 24 bits
+	select {
+	case d.replies <- raw:
+	default:
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hx711_test
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/drivers/hx711"
+	"github.com/rakyll/go-firmata/simulator"
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// board is a simulated board running the HX711_DATA feature, which
+// answers reads with the values set for each DOUT pin.
+type board struct {
+	*simulator.Board
+
+	mu     sync.Mutex
+	values map[byte][]int32 // consumed in order, the last one repeats
+}
+
+func newBoard() *board {
+	return &board{Board: simulator.New(nil), values: make(map[byte][]int32)}
+}
+
+func (b *board) set(dout byte, values ...int32) {
+	b.mu.Lock()
+	b.values[dout] = values
+	b.mu.Unlock()
+}
+
+func (b *board) Write(p []byte) (int, error) {
+	d := wire.NewDecoder(bytes.NewReader(p))
+	for {
+		m, err := d.Decode()
+		if err != nil {
+			break
+		}
+		s, ok := m.(wire.SysEx)
+		if !ok || s.Command != byte(hx711.SysEx) || len(s.Data) < 2 || s.Data[0] != 0x02 {
+			continue
+		}
+		b.mu.Lock()
+		vs := b.values[s.Data[1]]
+		if len(vs) == 0 {
+			b.mu.Unlock()
+			continue
+		}
+		v := vs[0]
+		if len(vs) > 1 {
+			b.values[s.Data[1]] = vs[1:]
+		}
+		b.mu.Unlock()
+		data := append([]byte{0x02, s.Data[1]}, wire.EncodeUint(uint64(uint32(v)&0xFFFFFF), 4)...)
+		b.Inject(wire.SysEx{Command: byte(hx711.SysEx), Data: data}.Bytes())
+	}
+	return b.Board.Write(p)
+}
+
+func newClient(t *testing.T) (*firmata.Client, *board) {
+	b := newBoard()
+	c, err := firmata.NewClientConn(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, b
+}
+
+func TestRaw(t *testing.T) {
+	c, b := newClient(t)
+	d, err := hx711.New(c, 2, 3, hx711.Gain128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []int32{0, 1, 8388607, -1, -8388608} {
+		b.set(2, want)
+		got, err := d.Raw()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Raw() = %d; want %d", got, want)
+		}
+	}
+}
+
+func TestWeight(t *testing.T) {
+	c, b := newClient(t)
+	d, err := hx711.New(c, 2, 3, hx711.Gain128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.set(2, 100, 9000, 110) // the spike is filtered out
+	if err := d.Tare(3); err != nil {
+		t.Fatal(err)
+	}
+	b.set(2, 2120, 2100, 2110)
+	if err := d.Calibrate(100, 3); err != nil {
+		t.Fatal(err)
+	}
+	b.set(2, 1110)
+	w, err := d.Weight(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w != 50 {
+		t.Errorf("Weight(1) = %v; want 50", w)
+	}
+	if err := d.Calibrate(0, 1); err == nil {
+		t.Error("Calibrate with a zero weight succeeded")
+	}
+}
+
+func TestDevicesShareFeature(t *testing.T) {
+	c, b := newClient(t)
+	d1, err := hx711.New(c, 2, 3, hx711.Gain128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := c.RegisteredFeature(hx711.SysEx)
+	if f == nil {
+		t.Fatal("no feature registered for the HX711 SysEx")
+	}
+	d2, err := hx711.New(c, 4, 5, hx711.Gain64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.RegisteredFeature(hx711.SysEx) != f {
+		t.Error("the second device registered another feature")
+	}
+	b.set(2, 12)
+	b.set(4, -34)
+	for _, tt := range []struct {
+		d    *hx711.Device
+		want int32
+	}{{d1, 12}, {d2, -34}} {
+		got, err := tt.d.Raw()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Raw() = %d; want %d", got, tt.want)
+		}
+	}
+}
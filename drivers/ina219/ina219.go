@@ -0,0 +1,119 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ina219 implements a driver for the INA219 I2C current and
+// power monitor. The client must have I2C enabled with I2CConfig.
+package ina219
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/regmap"
+)
+
+// DefaultAddress is the I2C address with A0 and A1 tied to ground.
+const DefaultAddress = 0x40
+
+const (
+	regShuntVoltage = 0x01
+	regBusVoltage   = 0x02
+	regPower        = 0x03
+	regCurrent      = 0x04
+	regCalibration  = 0x05
+)
+
+// Calibration describes the shunt resistor and the expected range of
+// the measured current.
+type Calibration struct {
+	ShuntOhms  float64 // shunt resistance, 0.1 on most breakout boards
+	MaxCurrent float64 // maximum expected current in amps
+}
+
+// Device is an INA219 attached to the board.
+type Device struct {
+	regs       *regmap.Map
+	currentLSB float64 // amps per bit of the current register
+}
+
+// New returns the INA219 at addr. Current and Power are only available
+// after Calibrate.
+func New(c *firmata.Client, addr byte) *Device {
+	return &Device{regs: regmap.New(c, addr)}
+}
+
+// Calibrate programs the calibration register for cal.
+func (d *Device) Calibrate(cal Calibration) error {
+	if cal.ShuntOhms <= 0 || cal.MaxCurrent <= 0 {
+		return errors.New("ina219: invalid calibration")
+	}
+	lsb := cal.MaxCurrent / 32768
+	f := 0.04096 / (lsb * cal.ShuntOhms)
+	if f > 0xFFFE || f < 1 {
+		return fmt.Errorf("ina219: calibration value %.0f out of range", f)
+	}
+	v := uint16(f)
+	if err := d.regs.WriteUint16(regCalibration, v); err != nil {
+		return err
+	}
+	d.currentLSB = lsb
+	return nil
+}
+
+// BusVoltage returns the voltage on the load side of the shunt in volts.
+func (d *Device) BusVoltage() (float64, error) {
+	v, err := d.read(regBusVoltage)
+	if err != nil {
+		return 0, err
+	}
+	return float64(v>>3) * 0.004, nil
+}
+
+// ShuntVoltage returns the voltage across the shunt in volts.
+func (d *Device) ShuntVoltage() (float64, error) {
+	v, err := d.read(regShuntVoltage)
+	if err != nil {
+		return 0, err
+	}
+	return float64(int16(v)) * 0.00001, nil
+}
+
+// Current returns the current through the shunt in amps.
+func (d *Device) Current() (float64, error) {
+	if d.currentLSB == 0 {
+		return 0, errors.New("ina219: not calibrated")
+	}
+	v, err := d.read(regCurrent)
+	if err != nil {
+		return 0, err
+	}
+	return float64(int16(v)) * d.currentLSB, nil
+}
+
+// Power returns the power delivered to the load in watts.
+func (d *Device) Power() (float64, error) {
+	if d.currentLSB == 0 {
+		return 0, errors.New("ina219: not calibrated")
+	}
+	v, err := d.read(regPower)
+	if err != nil {
+		return 0, err
+	}
+	return float64(v) * 20 * d.currentLSB, nil
+}
+
+func (d *Device) read(reg byte) (uint16, error) {
+	return d.regs.ReadUint16(reg)
+}
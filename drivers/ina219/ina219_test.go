@@ -0,0 +1,111 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ina219
+
+import (
+	"math"
+	"testing"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/simulator"
+)
+
+func newDevice(t *testing.T) (*Device, *simulator.Board) {
+	b := simulator.New(nil)
+	c, err := firmata.NewClientConn(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if err := c.I2CConfig(0); err != nil {
+		t.Fatal(err)
+	}
+	return New(c, DefaultAddress), b
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestCalibrate(t *testing.T) {
+	tests := []struct {
+		cal Calibration
+		reg uint16 // the calibration register, 0 if rejected
+	}{
+		{Calibration{ShuntOhms: 0.1, MaxCurrent: 3.2}, 4194},
+		{Calibration{ShuntOhms: 0.1, MaxCurrent: 0.4}, 33554},
+		{Calibration{ShuntOhms: 0.1, MaxCurrent: 0.01}, 0}, // 1310720
+		{Calibration{ShuntOhms: 0.001, MaxCurrent: 0.1}, 0},
+		{Calibration{ShuntOhms: 1000, MaxCurrent: 100}, 0}, // below 1
+		{Calibration{ShuntOhms: 0, MaxCurrent: 1}, 0},
+	}
+	for _, tt := range tests {
+		d, _ := newDevice(t)
+		err := d.Calibrate(tt.cal)
+		if tt.reg == 0 {
+			if err == nil {
+				t.Errorf("Calibrate(%+v) succeeded; want an error", tt.cal)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Calibrate(%+v): %v", tt.cal, err)
+			continue
+		}
+		v, err := d.regs.ReadUint16(regCalibration)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != tt.reg {
+			t.Errorf("Calibrate(%+v) wrote %d; want %d", tt.cal, v, tt.reg)
+		}
+	}
+}
+
+func TestReadings(t *testing.T) {
+	d, b := newDevice(t)
+	if _, err := d.Current(); err == nil {
+		t.Error("Current before Calibrate succeeded")
+	}
+	if err := d.Calibrate(Calibration{ShuntOhms: 0.1, MaxCurrent: 3.2}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The simulator stores registers a byte apart, so each reading sets
+	// its register right before reading it.
+	readings := []struct {
+		name string
+		reg  byte
+		data []byte
+		read func() (float64, error)
+		want float64
+	}{
+		{"ShuntVoltage", regShuntVoltage, []byte{0xFC, 0x18}, d.ShuntVoltage, -0.01}, // -1000
+		{"BusVoltage", regBusVoltage, []byte{0x5D, 0xC0}, d.BusVoltage, 12},          // 3000 << 3
+		{"Current", regCurrent, []byte{0x03, 0xE8}, d.Current, 1000 * 3.2 / 32768},
+		{"Power", regPower, []byte{0x00, 0x64}, d.Power, 100 * 20 * 3.2 / 32768},
+	}
+	for _, r := range readings {
+		b.SetI2C(DefaultAddress, int(r.reg), r.data...)
+		got, err := r.read()
+		if err != nil {
+			t.Errorf("%s: %v", r.name, err)
+			continue
+		}
+		if !near(got, r.want) {
+			t.Errorf("%s = %v; want %v", r.name, got, r.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ir receives and transmits infrared remote control codes
+// through the IR_DATA firmware feature from contrib/IR, which wraps the
+// Arduino IRremote library.
+package ir
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// SysEx is the user-defined SysEx command of the IR_DATA feature.
+const SysEx firmata.SysExCommand = 0x0E
+
+const (
+	subReceiverConfig = 0x01
+	subReceived       = 0x02
+	subSend           = 0x03
+)
+
+// Protocol is an IR remote control protocol.
+type Protocol byte
+
+const (
+	NEC Protocol = 0x01
+	RC5 Protocol = 0x02
+)
+
+func (p Protocol) String() string {
+	switch p {
+	case NEC:
+		return "NEC"
+	case RC5:
+		return "RC5"
+	}
+	return fmt.Sprintf("Protocol(%d)", byte(p))
+}
+
+// Code is a decoded remote control code.
+type Code struct {
+	Protocol Protocol
+	Value    uint32
+	Bits     int
+}
+
+// Remote is the IR receiver and transmitter of a board.
+type Remote struct {
+	c *firmata.Client
+
+	mu    sync.Mutex
+	codes chan Code
+}
+
+// New returns the IR feature of the board.
+func New(c *firmata.Client) *Remote {
+	r := &Remote{c: c}
+	c.HandleSysEx(SysEx, r.handle)
+	return r
+}
+
+// Receive enables the IR receiver on pin and returns a channel of the
+// codes it decodes. Codes are dropped when the channel is full.
+func (r *Remote) Receive(pin byte) (<-chan Code, error) {
+	r.mu.Lock()
+	if r.codes == nil {
+		r.codes = make(chan Code, 16)
+	}
+	codes := r.codes
+	r.mu.Unlock()
+	if err := r.c.SendSysEx(SysEx, subReceiverConfig, pin&0x7F); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Send transmits code repeats+1 times from the board's IR LED, which the
+// IRremote library fixes to a timer pin (pin 3 on an Uno).
+func (r *Remote) Send(code Code, repeats int) error {
+	if code.Protocol != NEC && code.Protocol != RC5 {
+		return errors.New("ir: unsupported protocol")
+	}
+	data := []byte{subSend, byte(code.Protocol), byte(code.Bits) & 0x7F}
+	data = append(data, wire.EncodeUint(uint64(code.Value), 5)...)
+	data = append(data, byte(repeats)&0x7F)
+	return r.c.SendSysEx(SysEx, data...)
+}
+
+// handle decodes a received code: subReceived, protocol, bit count and
+// the value as five 7-bit bytes, LSB first.
+func (r *Remote) handle(data []byte) {
+	if len(data) < 8 || data[0] != subReceived {
+		return
+	}
+	code := Code{
+		Protocol: Protocol(data[1]),
+		Bits:     int(data[2]),
+		Value:    uint32(wire.DecodeUint(data[3:8])),
+	}
+	r.mu.Lock()
+	codes := r.codes
+	r.mu.Unlock()
+	if codes == nil {
+		return
+	}
+	select {
+	case codes <- code:
+	default:
+	}
+}
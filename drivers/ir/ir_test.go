@@ -0,0 +1,61 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ir_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/drivers/ir"
+	"github.com/rakyll/go-firmata/simulator"
+	"github.com/rakyll/go-firmata/wire"
+)
+
+func TestReceive(t *testing.T) {
+	b := simulator.New(nil)
+	c, err := firmata.NewClientConn(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	r := ir.New(c)
+	codes, err := r.Receive(11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ir.Code{Protocol: ir.NEC, Value: 0xFFA25D00, Bits: 32}
+	data := append([]byte{0x02, byte(want.Protocol), byte(want.Bits)}, wire.EncodeUint(uint64(want.Value), 5)...)
+	b.Inject(wire.SysEx{Command: byte(ir.SysEx), Data: data}.Bytes())
+	select {
+	case got := <-codes:
+		if got != want {
+			t.Errorf("received %+v; want %+v", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no code received")
+	}
+}
+
+func TestSendUnsupportedProtocol(t *testing.T) {
+	c, err := firmata.NewClientConn(simulator.New(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := ir.New(c).Send(ir.Code{Protocol: 9, Value: 1, Bits: 8}, 0); err == nil {
+		t.Error("Send of an unsupported protocol succeeded")
+	}
+}
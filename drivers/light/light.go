@@ -0,0 +1,211 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package light provides helpers for light sensors: lux approximation
+// for photoresistors (LDR) read from analog pins, a driver for the
+// BH1750 I2C light sensor and day/night detection on top of either,
+// polled or watched as a stream of transitions.
+package light
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+)
+
+// LDR is a photoresistor wired between VCC and an analog pin with a
+// fixed resistor from the pin to ground, so brighter light reads higher.
+type LDR struct {
+	Pin int
+
+	// FixedOhms is the resistance of the fixed resistor.
+	FixedOhms float64
+
+	// Ohms10Lux is the resistance of the LDR at 10 lux and Gamma the
+	// slope of its log(R)/log(lux) curve, both from the datasheet. A
+	// GL5528 is roughly 10k and 0.7.
+	Ohms10Lux float64
+	Gamma     float64
+
+	// Max is the full scale analog reading, 1023 on 10-bit boards.
+	Max int
+}
+
+// Lux approximates the illuminance for a raw analog reading.
+func (l *LDR) Lux(raw int) float64 {
+	if raw <= 0 {
+		return 0
+	}
+	if raw >= l.Max {
+		raw = l.Max - 1
+	}
+	r := l.FixedOhms * float64(l.Max-raw) / float64(raw)
+	return 10 * math.Pow(l.Ohms10Lux/r, 1/l.Gamma)
+}
+
+// BH1750Address is the I2C address of a BH1750 with ADDR tied low.
+const BH1750Address = 0x23
+
+const (
+	bh1750PowerOn        = 0x01
+	bh1750ContinuousHRes = 0x10
+)
+
+// BH1750 is a BH1750 digital light sensor. The client must have I2C
+// enabled with I2CConfig.
+type BH1750 struct {
+	c    *firmata.Client
+	addr byte
+}
+
+// NewBH1750 powers on the sensor at addr and starts continuous high
+// resolution measurements.
+func NewBH1750(c *firmata.Client, addr byte) (*BH1750, error) {
+	if err := c.I2CWrite(addr, bh1750PowerOn); err != nil {
+		return nil, err
+	}
+	if err := c.I2CWrite(addr, bh1750ContinuousHRes); err != nil {
+		return nil, err
+	}
+	// The first high resolution measurement takes up to 180ms.
+	time.Sleep(180 * time.Millisecond)
+	return &BH1750{c: c, addr: addr}, nil
+}
+
+// Lux returns the latest measurement in lux.
+func (s *BH1750) Lux() (float64, error) {
+	b, err := s.c.I2CRead(s.addr, -1, 2)
+	if err != nil {
+		return 0, err
+	}
+	if len(b) != 2 {
+		return 0, errors.New("light: short read")
+	}
+	return float64(uint16(b[0])<<8|uint16(b[1])) / 1.2, nil
+}
+
+// Phase is either day or night.
+type Phase int
+
+const (
+	Night Phase = iota
+	Day
+)
+
+func (p Phase) String() string {
+	if p == Day {
+		return "DAY"
+	}
+	return "NIGHT"
+}
+
+// DayNight detects transitions between day and night. It switches to
+// day above Threshold+Hysteresis and to night below
+// Threshold-Hysteresis, so flickering around the threshold is ignored.
+type DayNight struct {
+	Threshold  float64 // lux
+	Hysteresis float64 // lux
+
+	phase Phase
+	init  bool
+}
+
+// Update feeds a new lux value and reports the current phase and
+// whether it changed with this value. The first value always reports a
+// change.
+func (d *DayNight) Update(lux float64) (phase Phase, changed bool) {
+	next := d.phase
+	switch {
+	case lux > d.Threshold+d.Hysteresis:
+		next = Day
+	case lux < d.Threshold-d.Hysteresis:
+		next = Night
+	case !d.init:
+		if lux >= d.Threshold {
+			next = Day
+		} else {
+			next = Night
+		}
+	}
+	changed = !d.init || next != d.phase
+	d.phase, d.init = next, true
+	return next, changed
+}
+
+// PhaseChange is a transition between day and night. The first
+// reading of a watch reports the initial phase.
+type PhaseChange struct {
+	Phase Phase
+	Lux   float64
+	Time  time.Time
+}
+
+// phaseBuffer is the channel buffer size of the watches.
+const phaseBuffer = 4
+
+// update feeds lux to d and sends the phase on changes, dropping it if
+// the receiver is too far behind.
+func (d *DayNight) update(changes chan PhaseChange, lux float64, t time.Time) {
+	if phase, changed := d.Update(lux); changed {
+		select {
+		case changes <- PhaseChange{phase, lux, t}:
+		default:
+		}
+	}
+}
+
+// Watch reports the day/night transitions seen by the LDR on the
+// returned channel, which is closed by the returned stop function. The
+// pin must be in analog mode with its reporting enabled. d must not be
+// updated elsewhere until the watch is stopped.
+func (l *LDR) Watch(c *firmata.Client, d *DayNight) (<-chan PhaseChange, func()) {
+	samples, cancel := c.SubscribePin(l.Pin)
+	changes := make(chan PhaseChange, phaseBuffer)
+	go func() {
+		defer close(changes)
+		for ev := range samples {
+			d.update(changes, l.Lux(ev.Value), ev.Time)
+		}
+	}()
+	return changes, cancel
+}
+
+// Watch reads the sensor every interval and reports the day/night
+// transitions on the returned channel, which is closed by the returned
+// stop function. Reads that fail are retried at the next interval. d
+// must not be updated elsewhere until the watch is stopped.
+func (s *BH1750) Watch(d *DayNight, interval time.Duration) (<-chan PhaseChange, func()) {
+	changes := make(chan PhaseChange, phaseBuffer)
+	done := make(chan struct{})
+	go func() {
+		defer close(changes)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			if lux, err := s.Lux(); err == nil {
+				d.update(changes, lux, time.Now())
+			}
+			select {
+			case <-t.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return changes, func() { once.Do(func() { close(done) }) }
+}
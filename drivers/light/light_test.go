@@ -0,0 +1,122 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package light_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/drivers/light"
+	"github.com/rakyll/go-firmata/simulator"
+)
+
+func TestDayNightHysteresis(t *testing.T) {
+	d := &light.DayNight{Threshold: 100, Hysteresis: 10}
+	steps := []struct {
+		lux     float64
+		phase   light.Phase
+		changed bool
+	}{
+		{95, light.Night, true},
+		{105, light.Night, false},
+		{111, light.Day, true},
+		{95, light.Day, false},
+		{89, light.Night, true},
+		{50, light.Night, false},
+	}
+	for _, s := range steps {
+		phase, changed := d.Update(s.lux)
+		if phase != s.phase || changed != s.changed {
+			t.Errorf("Update(%v) = %v, %v; want %v, %v", s.lux, phase, changed, s.phase, s.changed)
+		}
+	}
+}
+
+// next returns the next phase change or fails after a second.
+func next(t *testing.T, changes <-chan light.PhaseChange) light.Phase {
+	t.Helper()
+	select {
+	case ch, ok := <-changes:
+		if !ok {
+			t.Fatal("watch stopped")
+		}
+		return ch.Phase
+	case <-time.After(time.Second):
+		t.Fatal("no phase change")
+	}
+	return 0
+}
+
+func connect(t *testing.T) (*firmata.Client, *simulator.Board) {
+	b := simulator.New(nil)
+	c, err := firmata.NewClientConn(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, b
+}
+
+func TestLDRWatch(t *testing.T) {
+	c, b := connect(t)
+	const a0 = 14
+	if err := c.SetPinMode(a0, firmata.Analog); err != nil {
+		t.Fatal(err)
+	}
+	l := &light.LDR{Pin: a0, FixedOhms: 10000, Ohms10Lux: 10000, Gamma: 0.7, Max: 1023}
+	d := &light.DayNight{Threshold: l.Lux(512), Hysteresis: 1}
+	changes, stop := l.Watch(c, d)
+	defer stop()
+	if err := c.EnableAnalogInput(a0, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := next(t, changes); got != light.Night {
+		t.Fatalf("initial phase = %v; want %v", got, light.Night)
+	}
+	b.SetAnalog(a0, 900)
+	if got := next(t, changes); got != light.Day {
+		t.Fatalf("phase = %v; want %v", got, light.Day)
+	}
+	b.SetAnalog(a0, 100)
+	if got := next(t, changes); got != light.Night {
+		t.Fatalf("phase = %v; want %v", got, light.Night)
+	}
+}
+
+func TestBH1750Watch(t *testing.T) {
+	c, b := connect(t)
+	if err := c.I2CConfig(0); err != nil {
+		t.Fatal(err)
+	}
+	// The sensor has no registers; reads start at register 0.
+	b.SetI2C(light.BH1750Address, 0, 0x00, 0x0C) // 10 lux
+	s, err := light.NewBH1750(c, light.BH1750Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, stop := s.Watch(&light.DayNight{Threshold: 100, Hysteresis: 10}, 10*time.Millisecond)
+	defer stop()
+	if got := next(t, changes); got != light.Night {
+		t.Fatalf("initial phase = %v; want %v", got, light.Night)
+	}
+	b.SetI2C(light.BH1750Address, 0, 0x04, 0xB0) // 1000 lux
+	if got := next(t, changes); got != light.Day {
+		t.Fatalf("phase = %v; want %v", got, light.Day)
+	}
+	stop()
+	for range changes {
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package max7219 implements a driver for cascaded MAX7219 LED drivers
+// connected over SPI, either wired to 8x8 matrices or 7-segment digits.
+package max7219
+
+import (
+	"errors"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+)
+
+const (
+	regNoop        = 0x00
+	regDigit0      = 0x01
+	regDecodeMode  = 0x09
+	regIntensity   = 0x0A
+	regScanLimit   = 0x0B
+	regShutdown    = 0x0C
+	regDisplayTest = 0x0F
+)
+
+// Device is a chain of MAX7219s sharing a chip-select pin. Device 0 is
+// the first in the chain and the leftmost part of the display.
+type Device struct {
+	c     *firmata.Client
+	cs    byte
+	count int
+
+	// fb holds one byte per display column, bit 0 is the top row.
+	fb []byte
+}
+
+// New configures SPI for the chain of count devices selected by csPin
+// and initializes them with a blank display.
+func New(c *firmata.Client, csPin byte, count int) (*Device, error) {
+	if count < 1 {
+		return nil, errors.New("max7219: count must be positive")
+	}
+	d := &Device{c: c, cs: csPin, count: count, fb: make([]byte, 8*count)}
+	if err := c.SPIConfig(csPin, firmata.SPI_MODE0); err != nil {
+		return nil, err
+	}
+	for _, rv := range [][2]byte{
+		{regDisplayTest, 0},
+		{regScanLimit, 7},
+		{regDecodeMode, 0},
+		{regIntensity, 8},
+		{regShutdown, 1},
+	} {
+		if err := d.writeAll(rv[0], rv[1]); err != nil {
+			return nil, err
+		}
+	}
+	return d, d.Flush()
+}
+
+// SetBrightness sets the intensity of all devices, from 0 to 15.
+func (d *Device) SetBrightness(level byte) error {
+	if level > 15 {
+		level = 15
+	}
+	return d.writeAll(regIntensity, level)
+}
+
+// SetDecode switches all devices between raw segment data and BCD
+// Code B decoding, which is convenient for 7-segment displays.
+func (d *Device) SetDecode(on bool) error {
+	var v byte
+	if on {
+		v = 0xFF
+	}
+	return d.writeAll(regDecodeMode, v)
+}
+
+// WriteDigit writes v to digit (0-7) of the given device. With decoding
+// on, v is a Code B character; otherwise it is the segment bits.
+func (d *Device) WriteDigit(device, digit int, v byte) error {
+	if device < 0 || device >= d.count || digit < 0 || digit > 7 {
+		return errors.New("max7219: digit out of range")
+	}
+	packet := make([]byte, 2*d.count)
+	i := 2 * (d.count - 1 - device)
+	packet[i], packet[i+1] = regDigit0+byte(digit), v
+	return d.send(packet)
+}
+
+// Width returns the width of the matrix display in pixels.
+func (d *Device) Width() int {
+	return len(d.fb)
+}
+
+// Clear clears the framebuffer. Call Flush to update the display.
+func (d *Device) Clear() {
+	for i := range d.fb {
+		d.fb[i] = 0
+	}
+}
+
+// SetPixel sets the pixel at column x and row y in the framebuffer.
+func (d *Device) SetPixel(x, y int, on bool) {
+	if x < 0 || x >= len(d.fb) || y < 0 || y > 7 {
+		return
+	}
+	if on {
+		d.fb[x] |= 1 << uint(y)
+	} else {
+		d.fb[x] &^= 1 << uint(y)
+	}
+}
+
+// DrawText renders text into the framebuffer starting at column x,
+// which may be negative to scroll text in from the left. It returns the
+// width of the rendered text.
+func (d *Device) DrawText(text string, x int) int {
+	d.Clear()
+	w := 0
+	for _, r := range text {
+		glyph := glyphFor(r)
+		for _, col := range glyph {
+			if p := x + w; p >= 0 && p < len(d.fb) {
+				d.fb[p] = col
+			}
+			w++
+		}
+		w++ // spacing between characters
+	}
+	return w
+}
+
+// ScrollText scrolls text across the display from right to left,
+// moving one column every step.
+func (d *Device) ScrollText(text string, step time.Duration) error {
+	width := d.DrawText(text, len(d.fb))
+	for x := len(d.fb); x >= -width; x-- {
+		d.DrawText(text, x)
+		if err := d.Flush(); err != nil {
+			return err
+		}
+		time.Sleep(step)
+	}
+	return nil
+}
+
+// Flush writes the framebuffer to the devices.
+func (d *Device) Flush() error {
+	for row := 0; row < 8; row++ {
+		packet := make([]byte, 0, 2*d.count)
+		for dev := d.count - 1; dev >= 0; dev-- {
+			var v byte
+			for col := 0; col < 8; col++ {
+				if d.fb[8*dev+col]&(1<<uint(row)) != 0 {
+					v |= 0x80 >> uint(col)
+				}
+			}
+			packet = append(packet, regDigit0+byte(row), v)
+		}
+		if err := d.send(packet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeAll writes the same register on every device in the chain.
+func (d *Device) writeAll(reg, v byte) error {
+	packet := make([]byte, 0, 2*d.count)
+	for i := 0; i < d.count; i++ {
+		packet = append(packet, reg, v)
+	}
+	return d.send(packet)
+}
+
+func (d *Device) send(packet []byte) error {
+	_, err := d.c.SPIReadWrite(d.cs, packet)
+	return err
+}
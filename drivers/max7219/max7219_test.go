@@ -0,0 +1,55 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package max7219
+
+import "testing"
+
+func TestSetPixel(t *testing.T) {
+	d := &Device{count: 2, fb: make([]byte, 16)}
+	d.SetPixel(9, 3, true)
+	d.SetPixel(9, 5, true)
+	d.SetPixel(9, 3, false)
+	d.SetPixel(16, 0, true) // off the display
+	d.SetPixel(0, 8, true)
+	for x, col := range d.fb {
+		want := byte(0)
+		if x == 9 {
+			want = 1 << 5
+		}
+		if col != want {
+			t.Errorf("column %d = %#x; want %#x", x, col, want)
+		}
+	}
+}
+
+func TestDrawText(t *testing.T) {
+	d := &Device{count: 1, fb: make([]byte, 8)}
+	if w := d.DrawText("10", 0); w != 12 {
+		t.Errorf("DrawText width = %d; want 12", w)
+	}
+	want := []byte{0x00, 0x42, 0x7F, 0x40, 0x00, 0x00, 0x3E, 0x51}
+	if string(d.fb) != string(want) {
+		t.Errorf("framebuffer = %#v; want %#v", d.fb, want)
+	}
+	// Text scrolled off to the left leaves the tail of the last glyph.
+	d.DrawText("1", -2)
+	want = []byte{0x7F, 0x40, 0x00, 0, 0, 0, 0, 0}
+	if string(d.fb) != string(want) {
+		t.Errorf("scrolled framebuffer = %#v; want %#v", d.fb, want)
+	}
+	if glyphFor('\n') != glyphFor('?') {
+		t.Error("unprintable rune not drawn as ?")
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mfrc522 implements a driver for the MFRC522 RFID reader
+// connected over SPI. It detects ISO 14443A cards and reads their
+// single size (4 byte) UID.
+package mfrc522
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+)
+
+const (
+	regCommand     = 0x01
+	regComIrq      = 0x04
+	regError       = 0x06
+	regFIFOData    = 0x09
+	regFIFOLevel   = 0x0A
+	regBitFraming  = 0x0D
+	regMode        = 0x11
+	regTxControl   = 0x14
+	regTxASK       = 0x15
+	regTMode       = 0x2A
+	regTPrescaler  = 0x2B
+	regTReloadH    = 0x2C
+	regTReloadL    = 0x2D
+	regVersion     = 0x37
+	cmdIdle        = 0x00
+	cmdTransceive  = 0x0C
+	cmdSoftReset   = 0x0F
+	piccREQA       = 0x26
+	piccSelectCL1  = 0x93
+	irqRx          = 0x20
+	irqIdle        = 0x10
+	irqTimer       = 0x01
+	transceiveWait = 50 * time.Millisecond
+)
+
+// ErrNoCard is returned when no card answers a request.
+var ErrNoCard = errors.New("mfrc522: no card")
+
+// UID is the unique identifier of a card.
+type UID []byte
+
+// Device is an MFRC522 attached to the board.
+type Device struct {
+	c  *firmata.Client
+	cs byte
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New configures SPI for the reader selected by csPin and initializes it.
+func New(c *firmata.Client, csPin byte) (*Device, error) {
+	d := &Device{c: c, cs: csPin}
+	if err := c.SPIConfig(csPin, firmata.SPI_MODE0); err != nil {
+		return nil, err
+	}
+	if err := d.write(regCommand, cmdSoftReset); err != nil {
+		return nil, err
+	}
+	time.Sleep(50 * time.Millisecond)
+	v, err := d.read(regVersion)
+	if err != nil {
+		return nil, err
+	}
+	if v != 0x91 && v != 0x92 && v != 0x88 {
+		return nil, errors.New("mfrc522: unexpected version")
+	}
+	for _, rv := range [][2]byte{
+		{regTMode, 0x8D}, // timer starts after transmission
+		{regTPrescaler, 0x3E},
+		{regTReloadH, 0x00},
+		{regTReloadL, 0x1E}, // ~25ms timeout
+		{regTxASK, 0x40},    // force 100% ASK modulation
+		{regMode, 0x3D},     // CRC preset 0x6363
+	} {
+		if err := d.write(rv[0], rv[1]); err != nil {
+			return nil, err
+		}
+	}
+	tx, err := d.read(regTxControl)
+	if err != nil {
+		return nil, err
+	}
+	return d, d.write(regTxControl, tx|0x03) // antenna on
+}
+
+// ReadUID detects a card in the field and returns its UID. It returns
+// ErrNoCard when no card is present.
+func (d *Device) ReadUID() (UID, error) {
+	if _, err := d.transceive([]byte{piccREQA}, 7); err != nil {
+		return nil, err
+	}
+	resp, err := d.transceive([]byte{piccSelectCL1, 0x20}, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) != 5 || resp[0]^resp[1]^resp[2]^resp[3] != resp[4] {
+		return nil, errors.New("mfrc522: bad anticollision response")
+	}
+	return UID(resp[:4]), nil
+}
+
+// Watch polls for cards every interval and delivers the UID of each
+// newly presented card. A card held in front of the reader is reported
+// once. The channel is closed after Stop.
+func (d *Device) Watch(interval time.Duration) (<-chan UID, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stop != nil {
+		return nil, errors.New("mfrc522: already watching")
+	}
+	ch := make(chan UID, 1)
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+	go d.loop(interval, ch, d.stop, d.done)
+	return ch, nil
+}
+
+func (d *Device) loop(interval time.Duration, ch chan UID, stop, done chan struct{}) {
+	defer close(done)
+	defer close(ch)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	var last UID
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+		uid, err := d.ReadUID()
+		if err != nil {
+			last = nil
+			continue
+		}
+		if bytes.Equal(uid, last) {
+			continue
+		}
+		last = uid
+		select {
+		case ch <- uid:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Stop stops watching for cards.
+func (d *Device) Stop() {
+	d.mu.Lock()
+	stop, done := d.stop, d.done
+	d.stop, d.done = nil, nil
+	d.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// transceive sends data to the card and returns its response. bits is
+// the number of valid bits in the last byte, 0 meaning all 8.
+func (d *Device) transceive(data []byte, bits byte) ([]byte, error) {
+	for _, rv := range [][2]byte{
+		{regCommand, cmdIdle},
+		{regComIrq, 0x7F},
+		{regFIFOLevel, 0x80},
+	} {
+		if err := d.write(rv[0], rv[1]); err != nil {
+			return nil, err
+		}
+	}
+	for _, b := range data {
+		if err := d.write(regFIFOData, b); err != nil {
+			return nil, err
+		}
+	}
+	if err := d.write(regCommand, cmdTransceive); err != nil {
+		return nil, err
+	}
+	if err := d.write(regBitFraming, 0x80|bits); err != nil { // StartSend
+		return nil, err
+	}
+
+	deadline := time.Now().Add(transceiveWait)
+	for {
+		irq, err := d.read(regComIrq)
+		if err != nil {
+			return nil, err
+		}
+		if irq&(irqRx|irqIdle) != 0 {
+			break
+		}
+		if irq&irqTimer != 0 || time.Now().After(deadline) {
+			return nil, ErrNoCard
+		}
+	}
+	if e, err := d.read(regError); err != nil {
+		return nil, err
+	} else if e&0x13 != 0 { // BufferOvfl, ParityErr, ProtocolErr
+		return nil, errors.New("mfrc522: communication error")
+	}
+	n, err := d.read(regFIFOLevel)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]byte, n)
+	for i := range resp {
+		if resp[i], err = d.read(regFIFOData); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+func (d *Device) read(reg byte) (byte, error) {
+	b, err := d.c.SPIReadWrite(d.cs, []byte{0x80 | (reg<<1)&0x7E, 0x00})
+	if err != nil {
+		return 0, err
+	}
+	if len(b) != 2 {
+		return 0, errors.New("mfrc522: short read")
+	}
+	return b[1], nil
+}
+
+func (d *Device) write(reg, v byte) error {
+	_, err := d.c.SPIReadWrite(d.cs, []byte{(reg << 1) & 0x7E, v})
+	return err
+}
@@ -0,0 +1,135 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pcf8591 implements a driver for the PCF8591 8-bit I2C
+// converter with four analog inputs and one analog output. Its methods
+// mirror the analog pin calls of the client, so it can stand in for
+// boards without enough analog pins or without a true analog output.
+// The client must have I2C enabled with I2CConfig.
+package pcf8591
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/rakyll/go-firmata"
+)
+
+// DefaultAddress is the I2C address with A0 to A2 tied to ground.
+const DefaultAddress = 0x48
+
+// Bits of the control byte.
+const (
+	ctrlOutputEnable  = 0x40
+	ctrlAutoIncrement = 0x04
+)
+
+// Channels is the number of analog inputs.
+const Channels = 4
+
+// ADC is implemented by converters with analog inputs.
+type ADC interface {
+	AnalogRead(channel int) (int, error)
+}
+
+// DAC is implemented by converters with analog outputs.
+type DAC interface {
+	AnalogWrite(channel int, value int) error
+}
+
+// Device is a PCF8591 attached to the board.
+type Device struct {
+	c    *firmata.Client
+	addr byte
+
+	mu     sync.Mutex
+	output bool // whether the analog output is enabled
+}
+
+// New returns the PCF8591 at addr.
+func New(c *firmata.Client, addr byte) *Device {
+	return &Device{c: c, addr: addr}
+}
+
+// AnalogRead returns the 8-bit reading of input channel 0 to 3.
+func (d *Device) AnalogRead(channel int) (int, error) {
+	if channel < 0 || channel >= Channels {
+		return 0, errors.New("pcf8591: invalid channel")
+	}
+	// The first byte read is the result of the previous conversion.
+	b, err := d.c.I2CRead(d.addr, int(d.control()|byte(channel)), 2)
+	if err != nil {
+		return 0, err
+	}
+	if len(b) != 2 {
+		return 0, errors.New("pcf8591: short read")
+	}
+	return int(b[1]), nil
+}
+
+// ReadAll returns the readings of the four inputs.
+func (d *Device) ReadAll() ([Channels]int, error) {
+	var vals [Channels]int
+	b, err := d.c.I2CRead(d.addr, int(d.control()|ctrlAutoIncrement), Channels+1)
+	if err != nil {
+		return vals, err
+	}
+	if len(b) != Channels+1 {
+		return vals, errors.New("pcf8591: short read")
+	}
+	for i := range vals {
+		vals[i] = int(b[i+1])
+	}
+	return vals, nil
+}
+
+// AnalogWrite sets the analog output, channel 0, to value between 0 and
+// 255 and enables it.
+func (d *Device) AnalogWrite(channel int, value int) error {
+	if channel != 0 {
+		return errors.New("pcf8591: invalid channel")
+	}
+	if value < 0 || value > 255 {
+		return errors.New("pcf8591: value out of range")
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.c.I2CWrite(d.addr, ctrlOutputEnable, byte(value)); err != nil {
+		return err
+	}
+	d.output = true
+	return nil
+}
+
+// DisableOutput turns the analog output off, which lowers the power
+// used by the converter.
+func (d *Device) DisableOutput() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.c.I2CWrite(d.addr, 0); err != nil {
+		return err
+	}
+	d.output = false
+	return nil
+}
+
+// control returns the control byte bits that keep the output state.
+func (d *Device) control() byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.output {
+		return ctrlOutputEnable
+	}
+	return 0
+}
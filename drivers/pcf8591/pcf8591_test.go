@@ -0,0 +1,91 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pcf8591_test
+
+import (
+	"testing"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/drivers/pcf8591"
+	"github.com/rakyll/go-firmata/simulator"
+)
+
+var (
+	_ pcf8591.ADC = (*pcf8591.Device)(nil)
+	_ pcf8591.DAC = (*pcf8591.Device)(nil)
+)
+
+func newDevice(t *testing.T) (*pcf8591.Device, *simulator.Board) {
+	b := simulator.New(nil)
+	c, err := firmata.NewClientConn(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if err := c.I2CConfig(0); err != nil {
+		t.Fatal(err)
+	}
+	return pcf8591.New(c, pcf8591.DefaultAddress), b
+}
+
+func TestAnalogRead(t *testing.T) {
+	d, b := newDevice(t)
+	// The simulator answers with the registers from the control byte on,
+	// so the stale conversion comes first.
+	b.SetI2C(pcf8591.DefaultAddress, 0x02, 0xAA, 0x55)
+	v, err := d.AnalogRead(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 0x55 {
+		t.Errorf("AnalogRead(2) = %#x; want 0x55", v)
+	}
+	if _, err := d.AnalogRead(pcf8591.Channels); err == nil {
+		t.Error("AnalogRead of an invalid channel succeeded")
+	}
+}
+
+func TestReadAll(t *testing.T) {
+	d, b := newDevice(t)
+	b.SetI2C(pcf8591.DefaultAddress, 0x04, 0xFF, 1, 2, 3, 4)
+	vals, err := d.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vals != [pcf8591.Channels]int{1, 2, 3, 4} {
+		t.Errorf("ReadAll() = %v; want [1 2 3 4]", vals)
+	}
+}
+
+func TestOutputKeepsEnabled(t *testing.T) {
+	d, b := newDevice(t)
+	if err := d.AnalogWrite(0, 200); err != nil {
+		t.Fatal(err)
+	}
+	// With the output enabled, reads must keep the enable bit set.
+	b.SetI2C(pcf8591.DefaultAddress, 0x41, 0, 77)
+	v, err := d.AnalogRead(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 77 {
+		t.Errorf("AnalogRead(1) with the output enabled = %d; want 77", v)
+	}
+	for _, tt := range []struct{ channel, value int }{{1, 0}, {0, -1}, {0, 256}} {
+		if err := d.AnalogWrite(tt.channel, tt.value); err == nil {
+			t.Errorf("AnalogWrite(%d, %d) succeeded", tt.channel, tt.value)
+		}
+	}
+}
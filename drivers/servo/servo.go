@@ -0,0 +1,182 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package servo drives hobby servos on PWM capable pins. A servo holding
+// a position keeps drawing current and may buzz, so a Servo can be
+// detached, which stops the control pulses, and detach itself after
+// being idle for a while.
+package servo
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+)
+
+// Servo is a servo on a pin of the board.
+type Servo struct {
+	c   *firmata.Client
+	pin uint8
+
+	mu       sync.Mutex
+	attached bool
+	angle    int
+	written  bool
+	idle     time.Duration
+	timer    *time.Timer
+	min, max int // pulse range, zero for the firmware default
+}
+
+// New attaches the servo on pin.
+func New(c *firmata.Client, pin uint8) (*Servo, error) {
+	s := &Servo{c: c, pin: pin}
+	if err := s.Attach(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Attach starts sending control pulses. A servo that was detached goes
+// back to the last angle written.
+func (s *Servo) Attach() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attach()
+}
+
+func (s *Servo) attach() error {
+	if s.attached {
+		return nil
+	}
+	var err error
+	if s.min > 0 {
+		err = s.c.ServoConfig(s.pin, s.min, s.max)
+	} else {
+		err = s.c.SetPinMode(s.pin, firmata.Servo)
+	}
+	if err != nil {
+		return err
+	}
+	s.attached = true
+	if s.written {
+		return s.c.AnalogWrite(uint(s.pin), byte(s.angle))
+	}
+	return nil
+}
+
+// Detach stops the control pulses, so the servo no longer holds its
+// position. The pin is driven low.
+func (s *Servo) Detach() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.detach()
+}
+
+func (s *Servo) detach() error {
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	if !s.attached {
+		return nil
+	}
+	// The firmware detaches the servo when the pin leaves servo mode.
+	if err := s.c.SetPinMode(s.pin, firmata.Output); err != nil {
+		return err
+	}
+	s.attached = false
+	return nil
+}
+
+// Attached reports whether the servo receives control pulses.
+func (s *Servo) Attached() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attached
+}
+
+// Write moves the servo to angle, in degrees from 0 to 180, attaching
+// it if needed.
+func (s *Servo) Write(angle int) error {
+	if angle < 0 || angle > 180 {
+		return errors.New("servo: angle out of range")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.angle, s.written = angle, true
+	if !s.attached {
+		// attach writes the angle.
+		if err := s.attach(); err != nil {
+			return err
+		}
+	} else if err := s.c.AnalogWrite(uint(s.pin), byte(angle)); err != nil {
+		return err
+	}
+	s.resetTimer()
+	return nil
+}
+
+// SetPulseRange sets the pulse widths, in microseconds, the servo is
+// driven with at 0 and 180 degrees. Most servos accept 1000 to 2000;
+// the firmware defaults to firmata.DefaultServoMinPulse and
+// firmata.DefaultServoMaxPulse.
+func (s *Servo) SetPulseRange(min, max int) error {
+	if min <= 0 || max <= min {
+		return errors.New("servo: invalid pulse range")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attached {
+		if err := s.c.ServoConfig(s.pin, min, max); err != nil {
+			return err
+		}
+		if s.written {
+			if err := s.c.AnalogWrite(uint(s.pin), byte(s.angle)); err != nil {
+				return err
+			}
+		}
+	}
+	s.min, s.max = min, max
+	return nil
+}
+
+// SetIdleTimeout makes the servo detach itself once d has passed
+// without a Write. Zero disables it.
+func (s *Servo) SetIdleTimeout(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.idle = d
+	s.resetTimer()
+}
+
+// resetTimer restarts the idle timeout. s.mu must be held.
+func (s *Servo) resetTimer() {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	if s.idle <= 0 || !s.attached {
+		return
+	}
+	var t *time.Timer
+	t = time.AfterFunc(s.idle, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.timer == t {
+			s.detach()
+		}
+	})
+	s.timer = t
+}
@@ -0,0 +1,117 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/drivers/servo"
+	"github.com/rakyll/go-firmata/simulator"
+)
+
+func newClient(t *testing.T) *firmata.Client {
+	c, err := firmata.NewClientConn(simulator.New(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// state queries the pin, which the simulator answers after handling
+// the messages sent before.
+func state(t *testing.T, c *firmata.Client, pin uint8) firmata.PinState {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s, err := c.QueryPinState(ctx, pin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestDetachAndAttach(t *testing.T) {
+	c := newClient(t)
+	s, err := servo.New(c, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(120); err != nil {
+		t.Fatal(err)
+	}
+	if st := state(t, c, 9); st.Mode != firmata.Servo || st.State != 120 {
+		t.Fatalf("pin 9 = %v %d; want Servo 120", st.Mode, st.State)
+	}
+	if err := s.Detach(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Attached() || state(t, c, 9).Mode != firmata.Output {
+		t.Fatal("Detach left the pin in servo mode")
+	}
+	// Writing attaches the servo again.
+	if err := s.Write(30); err != nil {
+		t.Fatal(err)
+	}
+	if st := state(t, c, 9); !s.Attached() || st.Mode != firmata.Servo || st.State != 30 {
+		t.Errorf("pin 9 after Write = %v %d; want Servo 30", st.Mode, st.State)
+	}
+	if err := s.Write(181); err == nil {
+		t.Error("Write(181) succeeded")
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	c := newClient(t)
+	s, err := servo.New(c, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetIdleTimeout(20 * time.Millisecond)
+	if err := s.Write(90); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for s.Attached() {
+		if time.Now().After(deadline) {
+			t.Fatal("servo still attached after the idle timeout")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if m := state(t, c, 9).Mode; m != firmata.Output {
+		t.Errorf("pin 9 mode after the idle timeout = %v; want Output", m)
+	}
+}
+
+func TestSetPulseRange(t *testing.T) {
+	c := newClient(t)
+	s, err := servo.New(c, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range [][2]int{{0, 2000}, {2000, 1000}} {
+		if err := s.SetPulseRange(r[0], r[1]); err == nil {
+			t.Errorf("SetPulseRange(%d, %d) succeeded", r[0], r[1])
+		}
+	}
+	if err := s.SetPulseRange(1000, 2000); err != nil {
+		t.Fatal(err)
+	}
+	if m := state(t, c, 9).Mode; m != firmata.Servo {
+		t.Errorf("pin 9 mode = %v; want Servo", m)
+	}
+}
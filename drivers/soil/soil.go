@@ -0,0 +1,106 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package soil converts raw analog readings of resistive or capacitive
+// soil moisture sensors into calibrated moisture percentages and raises
+// alerts when the soil gets too dry.
+package soil
+
+import "github.com/rakyll/go-firmata"
+
+// hysteresis is the margin in percent the moisture must rise above the
+// threshold before a new low-moisture alert can be raised.
+const hysteresis = 5
+
+// Sensor is a moisture sensor wired to an analog pin.
+type Sensor struct {
+	Pin int
+
+	// Dry and Wet are the raw readings of the sensor in dry air and in
+	// water. Capacitive sensors usually read higher when dry.
+	Dry, Wet int
+
+	// Threshold is the moisture percentage below which the soil is
+	// considered too dry.
+	Threshold float64
+
+	low bool
+}
+
+// Reading is a calibrated moisture sample.
+type Reading struct {
+	Pin     int
+	Raw     int
+	Percent float64
+
+	// Low is set when the moisture is below the sensor threshold.
+	Low bool
+
+	// Alert is set only on the reading that crossed below the threshold.
+	Alert bool
+}
+
+// Percent converts a raw reading into a moisture percentage between 0
+// (dry) and 100 (wet) using the two-point calibration.
+func (s *Sensor) Percent(raw int) float64 {
+	if s.Dry == s.Wet {
+		return 0
+	}
+	p := float64(raw-s.Dry) / float64(s.Wet-s.Dry) * 100
+	switch {
+	case p < 0:
+		return 0
+	case p > 100:
+		return 100
+	}
+	return p
+}
+
+// Update converts raw and updates the alert state of the sensor.
+func (s *Sensor) Update(raw int) Reading {
+	r := Reading{Pin: s.Pin, Raw: raw, Percent: s.Percent(raw)}
+	switch {
+	case !s.low && r.Percent < s.Threshold:
+		s.low = true
+		r.Alert = true
+	case s.low && r.Percent >= s.Threshold+hysteresis:
+		s.low = false
+	}
+	r.Low = s.low
+	return r
+}
+
+// Watch consumes analog values and delivers a Reading for each value of
+// a sensor's pin. Values of other pins are discarded. The returned
+// channel is closed when values is closed.
+func Watch(values <-chan firmata.FirmataValue, sensors ...*Sensor) <-chan Reading {
+	byPin := make(map[int]*Sensor, len(sensors))
+	for _, s := range sensors {
+		byPin[s.Pin] = s
+	}
+	out := make(chan Reading)
+	go func() {
+		defer close(out)
+		for v := range values {
+			pin, raw, err := v.AnalogValue()
+			if err != nil {
+				continue
+			}
+			if s, ok := byPin[pin]; ok {
+				out <- s.Update(raw)
+			}
+		}
+	}()
+	return out
+}
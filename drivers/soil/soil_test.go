@@ -0,0 +1,81 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package soil_test
+
+import (
+	"testing"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/drivers/soil"
+)
+
+func TestPercent(t *testing.T) {
+	s := &soil.Sensor{Dry: 800, Wet: 300} // capacitive, reads higher when dry
+	tests := []struct {
+		raw  int
+		want float64
+	}{
+		{800, 0},
+		{300, 100},
+		{550, 50},
+		{900, 0},
+		{100, 100},
+	}
+	for _, tt := range tests {
+		if got := s.Percent(tt.raw); got != tt.want {
+			t.Errorf("Percent(%d) = %v; want %v", tt.raw, got, tt.want)
+		}
+	}
+	if got := (&soil.Sensor{Dry: 5, Wet: 5}).Percent(5); got != 0 {
+		t.Errorf("Percent without a calibration = %v; want 0", got)
+	}
+}
+
+func TestUpdateHysteresis(t *testing.T) {
+	s := &soil.Sensor{Dry: 0, Wet: 100, Threshold: 30}
+	tests := []struct {
+		raw        int
+		low, alert bool
+	}{
+		{50, false, false},
+		{29, true, true},
+		{20, true, false},
+		{33, true, false}, // within the hysteresis
+		{35, false, false},
+		{10, true, true},
+	}
+	for _, tt := range tests {
+		r := s.Update(tt.raw)
+		if r.Low != tt.low || r.Alert != tt.alert {
+			t.Errorf("Update(%d) = Low %v, Alert %v; want %v, %v", tt.raw, r.Low, r.Alert, tt.low, tt.alert)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	values := make(chan firmata.FirmataValue, 3)
+	values <- firmata.FirmataValue{Kind: firmata.KindAnalog, Pin: 14, Raw: 10}
+	values <- firmata.FirmataValue{Kind: firmata.KindAnalog, Pin: 15, Raw: 90}
+	values <- firmata.FirmataValue{Kind: firmata.KindDigital, Port: 1}
+	close(values)
+	s := &soil.Sensor{Pin: 15, Dry: 0, Wet: 100, Threshold: 30}
+	var readings []soil.Reading
+	for r := range soil.Watch(values, s) {
+		readings = append(readings, r)
+	}
+	if len(readings) != 1 || readings[0].Pin != 15 || readings[0].Percent != 90 {
+		t.Errorf("Watch delivered %+v; want a single reading of pin 15 at 90%%", readings)
+	}
+}
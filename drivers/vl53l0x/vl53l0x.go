@@ -0,0 +1,262 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package vl53l0x implements a driver for the VL53L0X time-of-flight
+// distance sensor. The client must have I2C enabled with I2CConfig.
+//
+// The initialization is a reduced version of ST's reference sequence: it
+// skips SPAD and temperature calibration and relies on the sensor's
+// default tuning, which is accurate enough for ranges up to ~1.2m.
+package vl53l0x
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+)
+
+// Address is the default I2C address of the sensor.
+const Address = 0x29
+
+const (
+	regSysrangeStart           = 0x00
+	regSystemSequenceConfig    = 0x01
+	regSystemInterruptGPIO     = 0x0A
+	regSystemInterruptClear    = 0x0B
+	regResultInterruptStatus   = 0x13
+	regResultRange             = 0x1E
+	regSignalRateLimit         = 0x44
+	regMSRCConfigControl       = 0x60
+	regGPIOHVMuxActiveHigh     = 0x84
+	regI2CStandardMode         = 0x88
+	regVHVConfigPadSCLSDA      = 0x89
+	regModelID                 = 0xC0
+	modelID                    = 0xEE
+	rangeStartContinuous       = 0x02
+	rangeStartSingle           = 0x01
+	outOfRange                 = 8190
+	measurementPollingInterval = 5 * time.Millisecond
+)
+
+// Device is a VL53L0X attached to the board.
+type Device struct {
+	c       *firmata.Client
+	addr    byte
+	stopVar byte
+	mu      sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+	err     error
+}
+
+// New returns the sensor at addr. Init must be called before ranging.
+func New(c *firmata.Client, addr byte) *Device {
+	return &Device{c: c, addr: addr}
+}
+
+// Init verifies the sensor identity and configures it for ranging.
+func (d *Device) Init() error {
+	id, err := d.read(regModelID)
+	if err != nil {
+		return err
+	}
+	if id != modelID {
+		return errors.New("vl53l0x: unexpected model id")
+	}
+	v, err := d.read(regVHVConfigPadSCLSDA)
+	if err != nil {
+		return err
+	}
+	if err := d.write(regVHVConfigPadSCLSDA, v|0x01); err != nil { // 2V8 mode
+		return err
+	}
+	if err := d.writeAll(
+		regI2CStandardMode, 0x00,
+		0x80, 0x01, 0xFF, 0x01, 0x00, 0x00); err != nil {
+		return err
+	}
+	if d.stopVar, err = d.read(0x91); err != nil {
+		return err
+	}
+	if err := d.writeAll(0x00, 0x01, 0xFF, 0x00, 0x80, 0x00); err != nil {
+		return err
+	}
+	// Disable SIGNAL_RATE_MSRC and SIGNAL_RATE_PRE_RANGE limit checks.
+	if v, err = d.read(regMSRCConfigControl); err != nil {
+		return err
+	}
+	if err := d.write(regMSRCConfigControl, v|0x12); err != nil {
+		return err
+	}
+	// Final range signal rate limit of 0.25 MCPS in 9.7 fixed point.
+	if err := d.c.I2CWrite(d.addr, regSignalRateLimit, 0x00, 0x20); err != nil {
+		return err
+	}
+	// Interrupt on new sample ready, active low.
+	if err := d.write(regSystemInterruptGPIO, 0x04); err != nil {
+		return err
+	}
+	if v, err = d.read(regGPIOHVMuxActiveHigh); err != nil {
+		return err
+	}
+	return d.writeAll(
+		regGPIOHVMuxActiveHigh, v&^0x10,
+		regSystemInterruptClear, 0x01,
+		regSystemSequenceConfig, 0xE8)
+}
+
+// Range performs a single measurement and returns the distance in
+// millimeters.
+func (d *Device) Range() (int, error) {
+	if err := d.writeAll(
+		0x80, 0x01, 0xFF, 0x01, 0x00, 0x00,
+		0x91, d.stopVar,
+		0x00, 0x01, 0xFF, 0x00, 0x80, 0x00,
+		regSysrangeStart, rangeStartSingle); err != nil {
+		return 0, err
+	}
+	return d.readRange()
+}
+
+// StartContinuous starts back-to-back ranging and delivers distances in
+// millimeters on the returned channel, polling the sensor every
+// interval. Readings out of range are skipped. The channel is closed
+// after Stop or when a read fails; Err reports the failure.
+func (d *Device) StartContinuous(interval time.Duration) (<-chan int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stop != nil {
+		return nil, errors.New("vl53l0x: already ranging")
+	}
+	if err := d.writeAll(
+		0x80, 0x01, 0xFF, 0x01, 0x00, 0x00,
+		0x91, d.stopVar,
+		0x00, 0x01, 0xFF, 0x00, 0x80, 0x00,
+		regSysrangeStart, rangeStartContinuous); err != nil {
+		return nil, err
+	}
+	ch := make(chan int, 1)
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+	d.err = nil
+	go d.loop(interval, ch, d.stop, d.done)
+	return ch, nil
+}
+
+func (d *Device) loop(interval time.Duration, ch chan<- int, stop, done chan struct{}) {
+	defer close(done)
+	defer close(ch)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+		mm, err := d.readRange()
+		if err != nil {
+			d.mu.Lock()
+			d.err = err
+			d.mu.Unlock()
+			return
+		}
+		if mm >= outOfRange {
+			continue
+		}
+		select {
+		case ch <- mm:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Stop stops continuous ranging.
+func (d *Device) Stop() error {
+	d.mu.Lock()
+	stop, done := d.stop, d.done
+	d.stop, d.done = nil, nil
+	d.mu.Unlock()
+	if stop == nil {
+		return nil
+	}
+	close(stop)
+	<-done
+	return d.writeAll(
+		regSysrangeStart, 0x01,
+		0xFF, 0x01, 0x00, 0x00, 0x91, 0x00, 0x00, 0x01, 0xFF, 0x00)
+}
+
+// Err returns the error that ended continuous ranging, if any.
+func (d *Device) Err() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.err
+}
+
+func (d *Device) readRange() (int, error) {
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for {
+		status, err := d.read(regResultInterruptStatus)
+		if err != nil {
+			return 0, err
+		}
+		if status&0x07 != 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			return 0, errors.New("vl53l0x: measurement timed out")
+		}
+		time.Sleep(measurementPollingInterval)
+	}
+	b, err := d.c.I2CRead(d.addr, regResultRange, 2)
+	if err != nil {
+		return 0, err
+	}
+	if len(b) != 2 {
+		return 0, errors.New("vl53l0x: short read")
+	}
+	if err := d.write(regSystemInterruptClear, 0x01); err != nil {
+		return 0, err
+	}
+	return int(b[0])<<8 | int(b[1]), nil
+}
+
+func (d *Device) read(reg int) (byte, error) {
+	b, err := d.c.I2CRead(d.addr, reg, 1)
+	if err != nil {
+		return 0, err
+	}
+	if len(b) != 1 {
+		return 0, errors.New("vl53l0x: short read")
+	}
+	return b[0], nil
+}
+
+func (d *Device) write(reg, v byte) error {
+	return d.c.I2CWrite(d.addr, reg, v)
+}
+
+// writeAll writes register/value pairs in order.
+func (d *Device) writeAll(pairs ...byte) error {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if err := d.write(pairs[i], pairs[i+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
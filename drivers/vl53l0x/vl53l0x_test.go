@@ -0,0 +1,72 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vl53l0x_test
+
+import (
+	"testing"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/drivers/vl53l0x"
+	"github.com/rakyll/go-firmata/simulator"
+)
+
+func newDevice(t *testing.T) (*vl53l0x.Device, *simulator.Board) {
+	b := simulator.New(nil)
+	c, err := firmata.NewClientConn(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if err := c.I2CConfig(0); err != nil {
+		t.Fatal(err)
+	}
+	return vl53l0x.New(c, vl53l0x.Address), b
+}
+
+func TestInitUnexpectedModel(t *testing.T) {
+	d, b := newDevice(t)
+	b.SetI2C(vl53l0x.Address, 0xC0, 0x12)
+	if err := d.Init(); err == nil {
+		t.Error("Init of an unknown model succeeded")
+	}
+}
+
+func TestRange(t *testing.T) {
+	d, b := newDevice(t)
+	b.SetI2C(vl53l0x.Address, 0xC0, 0xEE)
+	if err := d.Init(); err != nil {
+		t.Fatal(err)
+	}
+	b.SetI2C(vl53l0x.Address, 0x13, 0x01)       // sample ready
+	b.SetI2C(vl53l0x.Address, 0x1E, 0x01, 0x2C) // 300mm
+	mm, err := d.Range()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mm != 300 {
+		t.Errorf("Range() = %d; want 300", mm)
+	}
+}
+
+func TestRangeTimeout(t *testing.T) {
+	d, b := newDevice(t)
+	b.SetI2C(vl53l0x.Address, 0xC0, 0xEE)
+	if err := d.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Range(); err == nil {
+		t.Error("Range without a sample ready succeeded")
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import "fmt"
+
+// UseAsDigital switches a pin that can be read both as an analog and a
+// digital input, such as A0 on an Uno, to a digital input and reports
+// its level. Analog reporting of the pin is stopped.
+func (c *Client) UseAsDigital(pin uint8) error {
+	if err := c.checkDual(pin); err != nil {
+		return err
+	}
+	if err := c.Reconfigure(pin, Input); err != nil {
+		return err
+	}
+	return c.EnableDigitalInput(uint(pin), true)
+}
+
+// UseAsAnalog switches a pin that can be read both as an analog and a
+// digital input to an analog input and reports its readings. Digital
+// reporting of its port is stopped if no other input remains on it.
+func (c *Client) UseAsAnalog(pin uint8) error {
+	if err := c.checkDual(pin); err != nil {
+		return err
+	}
+	if err := c.Reconfigure(pin, Analog); err != nil {
+		return err
+	}
+	return c.EnableAnalogInput(uint(pin), true)
+}
+
+// UsedAsAnalog reports whether pin is currently an analog input, which
+// analog pins are until their mode is set. Pin events of such pins only
+// carry analog readings, and those of other pins only digital levels.
+func (c *Client) UsedAsAnalog(pin int) bool {
+	if pin < 0 || pin > 0xFF {
+		return false
+	}
+	c.stateMu.Lock()
+	mode, ok := c.modes[uint8(pin)]
+	c.stateMu.Unlock()
+	if ok {
+		return mode == Analog
+	}
+	_, analog := c.board().channels[pin]
+	return analog
+}
+
+func (c *Client) checkDual(pin uint8) error {
+	if err := c.checkPin(int(pin)); err != nil {
+		return err
+	}
+	if b := c.board(); !b.supports(int(pin), Analog) || !b.supports(int(pin), Input) {
+		return fmt.Errorf("pin %v cannot be both an analog and a digital input", pin)
+	}
+	return nil
+}
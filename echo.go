@@ -0,0 +1,111 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// Echo is the SysEx command of the echo extension found in
+// contrib/Echo. The board sends every Echo message back unchanged.
+const Echo SysExCommand = 0x09
+
+// EchoStats summarizes an echo test.
+type EchoStats struct {
+	Sent, Received int
+	Lost           int // messages not echoed in time
+	Corrupted      int // echoes that differ from what was sent
+
+	// Round trip times of the received echoes, sorted.
+	RTTs []time.Duration
+}
+
+// Loss returns the fraction of messages lost or corrupted.
+func (s EchoStats) Loss() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+	return float64(s.Lost+s.Corrupted) / float64(s.Sent)
+}
+
+// Percentile returns the p-th percentile, 0 to 100, of the round trip
+// times.
+func (s EchoStats) Percentile(p float64) time.Duration {
+	if len(s.RTTs) == 0 {
+		return 0
+	}
+	i := int(p / 100 * float64(len(s.RTTs)-1))
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(s.RTTs) {
+		i = len(s.RTTs) - 1
+	}
+	return s.RTTs[i]
+}
+
+// EchoTest sends count echo messages of payloadSize bytes, one at a
+// time, and measures how many come back intact and how fast, to qualify
+// a cable or radio link. Each echo waits up to the query timeout, or
+// until ctx is done. It requires the Echo firmware extension.
+func (c *Client) EchoTest(ctx context.Context, payloadSize, count int) (EchoStats, error) {
+	var stats EchoStats
+	if payloadSize < 0 {
+		return stats, errors.New("firmata: negative payload size")
+	}
+	for i := 0; i < count; i++ {
+		seq := i & 0x3FFF
+		msg := make([]byte, 2+payloadSize)
+		copy(msg, wire.EncodeUint(uint64(seq), 2))
+		for j := 2; j < len(msg); j++ {
+			msg[j] = byte(i+j) & 0x7F
+		}
+
+		sent := c.clock.Now()
+		v, err := c.query(ctx, queryKey{cmd: Echo, id: seq}, func() error {
+			return c.sendSysEx(Echo, msg...)
+		})
+		stats.Sent++
+		switch {
+		case err == ErrTimeout:
+			stats.Lost++
+			continue
+		case err != nil:
+			return stats, err
+		}
+		stats.Received++
+		if !bytes.Equal(v.([]byte), msg) {
+			stats.Corrupted++
+			continue
+		}
+		stats.RTTs = append(stats.RTTs, c.clock.Now().Sub(sent))
+	}
+	sort.Slice(stats.RTTs, func(i, j int) bool { return stats.RTTs[i] < stats.RTTs[j] })
+	return stats, nil
+}
+
+func (c *Client) parseEcho(data []byte) {
+	if len(data) < 2 {
+		return
+	}
+	seq := int(wire.From7Bit(data[0], data[1]))
+	c.pending.resolve(queryKey{cmd: Echo, id: seq}, append([]byte(nil), data...))
+}
@@ -0,0 +1,77 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import "sync"
+
+// WithEmulation makes the client emulate features that stock
+// StandardFirmata lacks, such as Tone and Ping, with timed writes and
+// reads from the host. Timing then depends on the link and the host,
+// so fidelity is reduced; a WarningEvent is published the first time a
+// feature is emulated.
+func WithEmulation() Option {
+	return func(c *Client) {
+		c.emulation.enabled = true
+	}
+}
+
+// WarningEvent reports a condition that doesn't prevent the client from
+// working, such as a feature being emulated.
+type WarningEvent struct {
+	Header
+	Message string
+}
+
+// emulation tracks the emulated features of a client.
+type emulation struct {
+	enabled bool
+
+	mu      sync.Mutex
+	warned  map[string]bool
+	missing map[string]bool         // features the firmware lacks
+	tones   map[uint8]chan struct{} // stops the emulated tone of a pin
+}
+
+// lacks reports whether the firmware is known to lack feature.
+func (c *Client) lacks(feature string) bool {
+	c.emulation.mu.Lock()
+	defer c.emulation.mu.Unlock()
+	return c.emulation.missing[feature]
+}
+
+// setLacks records that the firmware lacks feature.
+func (c *Client) setLacks(feature string) {
+	c.emulation.mu.Lock()
+	defer c.emulation.mu.Unlock()
+	if c.emulation.missing == nil {
+		c.emulation.missing = make(map[string]bool)
+	}
+	c.emulation.missing[feature] = true
+}
+
+// warnEmulated publishes a WarningEvent the first time feature is
+// emulated.
+func (c *Client) warnEmulated(feature string) {
+	c.emulation.mu.Lock()
+	if c.emulation.warned == nil {
+		c.emulation.warned = make(map[string]bool)
+	}
+	warned := c.emulation.warned[feature]
+	c.emulation.warned[feature] = true
+	c.emulation.mu.Unlock()
+	if !warned {
+		c.bus.publish(WarningEvent{Header{c.clock.Now()}, feature + " is emulated by the host at reduced fidelity"})
+	}
+}
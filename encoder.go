@@ -0,0 +1,151 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// Subcommands of EncoderData.
+const (
+	encoderAttach    = 0x00
+	encoderReport    = 0x01
+	encoderReportAll = 0x02
+	encoderReset     = 0x03
+	encoderAuto      = 0x04
+	encoderDetach    = 0x05
+)
+
+// MaxEncoders is the number of encoders the Encoder feature reads.
+const MaxEncoders = 5
+
+// EncoderEvent is the position of a rotary encoder reported by the
+// board.
+type EncoderEvent struct {
+	Header
+	Encoder  int
+	Position int
+}
+
+// AttachEncoder starts counting the quadrature encoder on pinA and pinB
+// as encoder n, from 0 to MaxEncoders-1. The pins should be interrupt
+// capable.
+func (c *Client) AttachEncoder(n int, pinA, pinB uint8) error {
+	if err := checkEncoder(n); err != nil {
+		return err
+	}
+	for _, pin := range []uint8{pinA, pinB} {
+		if err := c.checkPin(int(pin)); err != nil {
+			return err
+		}
+	}
+	m := wire.SysEx{Command: byte(EncoderData), Data: []byte{encoderAttach, byte(n), pinA, pinB}}
+	if err := c.sendConfig(fmt.Sprintf("encoder/%d", n), m); err != nil {
+		return err
+	}
+	c.modeSet(pinA, Encoder)
+	c.modeSet(pinB, Encoder)
+	c.stateMu.Lock()
+	if c.encoderPins == nil {
+		c.encoderPins = make(map[uint8]int)
+	}
+	c.encoderPins[pinA] = n
+	c.encoderPins[pinB] = n
+	c.stateMu.Unlock()
+	return nil
+}
+
+// DetachEncoder stops counting encoder n.
+func (c *Client) DetachEncoder(n int) error {
+	if err := checkEncoder(n); err != nil {
+		return err
+	}
+	c.journal.forget(fmt.Sprintf("encoder/%d", n))
+	c.stateMu.Lock()
+	for pin, e := range c.encoderPins {
+		if e == n {
+			delete(c.encoderPins, pin)
+		}
+	}
+	c.stateMu.Unlock()
+	return c.sendSysEx(EncoderData, encoderDetach, byte(n))
+}
+
+// EncoderPosition asks the board for the position of encoder n.
+func (c *Client) EncoderPosition(ctx context.Context, n int) (int, error) {
+	if err := checkEncoder(n); err != nil {
+		return 0, err
+	}
+	v, err := c.query(ctx, queryKey{EncoderData, n}, func() error {
+		return c.sendSysEx(EncoderData, encoderReport, byte(n))
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.(int), nil
+}
+
+// ReportEncoders asks the board for the positions of all the attached
+// encoders, which are published as EncoderEvents.
+func (c *Client) ReportEncoders() error {
+	return c.sendSysEx(EncoderData, encoderReportAll)
+}
+
+// ResetEncoder sets the position of encoder n back to zero.
+func (c *Client) ResetEncoder(n int) error {
+	if err := checkEncoder(n); err != nil {
+		return err
+	}
+	return c.sendSysEx(EncoderData, encoderReset, byte(n))
+}
+
+// EnableEncoderReporting makes the board report the positions of the
+// attached encoders at the sampling interval.
+func (c *Client) EnableEncoderReporting(on bool) error {
+	m := wire.SysEx{Command: byte(EncoderData), Data: []byte{encoderAuto, boolByte(on)}}
+	return c.sendConfig("encoder-report", m)
+}
+
+// Encoders returns the channel of encoder positions. All callers share
+// the same channel. Positions are dropped when it is full.
+func (c *Client) Encoders() <-chan EncoderEvent {
+	c.encoderOnce.Do(func() { c.encoderChan = Subscribe[EncoderEvent](c, Filter{}) })
+	return c.encoderChan
+}
+
+func checkEncoder(n int) error {
+	if n < 0 || n >= MaxEncoders {
+		return fmt.Errorf("invalid encoder %d", n)
+	}
+	return nil
+}
+
+// parseEncoder decodes the positions of one or more encoders, each a
+// byte with the encoder number and the sign in bit 6 followed by a
+// 28-bit magnitude.
+func (c *Client) parseEncoder(data []byte) {
+	for ; len(data) >= 5; data = data[5:] {
+		n := int(data[0] & 0x3F)
+		pos := int(wire.DecodeUint(data[1:5]))
+		if data[0]&0x40 != 0 {
+			pos = -pos
+		}
+		c.pending.resolve(queryKey{EncoderData, n}, pos)
+		c.bus.publish(EncoderEvent{Header{c.eventTime()}, n, pos})
+	}
+}
@@ -0,0 +1,475 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// subscriptionBuffer is the channel buffer size of subscriptions.
+// Events are dropped for subscribers whose buffer is full, so a slow
+// consumer never stalls the reader or other subscribers.
+const subscriptionBuffer = 64
+
+// Event is a report received from the board.
+type Event interface {
+	// EventTime returns when the client received the event.
+	EventTime() time.Time
+}
+
+// Header carries the fields common to all events. Events defined
+// outside this package embed it to implement Event.
+type Header struct {
+	Time time.Time
+}
+
+func (h Header) EventTime() time.Time {
+	return h.Time
+}
+
+// DigitalEvent is a report of the pin levels of a digital port.
+type DigitalEvent struct {
+	Header
+	Port byte
+
+	// Value holds the pin levels, bit n is pin Port*8+n.
+	Value byte
+
+	// Changed holds the bits that changed since the previous report of
+	// the port.
+	Changed byte
+
+	// Initial is set on the first report after reporting was enabled,
+	// whose Changed also holds the input pins of the port, so their
+	// level is known before it first changes.
+	Initial bool
+}
+
+// High reports whether pin, which must belong to the port, is high.
+func (e DigitalEvent) High(pin int) bool {
+	return e.Value&(1<<uint(pin-int(e.Port)*8)) != 0
+}
+
+// AnalogEvent is a report of an analog input.
+type AnalogEvent struct {
+	Header
+	Pin     int
+	Channel byte
+	Value   int
+
+	// Scaled is Value after the pipeline of the pin, in Unit, or Value
+	// itself if the pin has no pipeline.
+	Scaled float64
+	Unit   string
+}
+
+// I2CEvent is a reply to an I2C read request.
+//
+// Data comes from a pool shared by all the subscribers of the event.
+// A subscriber may call Release once it is done with the event so that
+// Data can be reused; it must copy Data first if it keeps it. Events
+// that are never released are simply garbage collected.
+type I2CEvent struct {
+	Header
+	Address byte
+
+	// Register is the register read, or 0xFF for reads that didn't
+	// specify one.
+	Register int
+	Data     []byte
+
+	p *payload
+}
+
+// Release returns Data to the pool once every subscriber released it.
+// It must be called at most once per received event.
+func (e I2CEvent) Release() {
+	e.p.release()
+}
+
+// payload is a pooled byte slice referenced by the receivers of an
+// event.
+type payload struct {
+	buf  []byte
+	refs int32
+}
+
+var payloadPool = sync.Pool{
+	New: func() interface{} { return new(payload) },
+}
+
+// newPayload returns a payload referenced once, by the publisher.
+func newPayload() *payload {
+	p := payloadPool.Get().(*payload)
+	p.buf = p.buf[:0]
+	p.refs = 1
+	return p
+}
+
+func (p *payload) retain() {
+	if p != nil {
+		atomic.AddInt32(&p.refs, 1)
+	}
+}
+
+func (p *payload) release() {
+	if p != nil && atomic.AddInt32(&p.refs, -1) == 0 {
+		payloadPool.Put(p)
+	}
+}
+
+// pooled is implemented by events carrying a pooled payload.
+type pooled interface {
+	payloadRef() *payload
+}
+
+func (e I2CEvent) payloadRef() *payload { return e.p }
+
+// retainEvent takes a reference to the payload of ev for a receiver.
+func retainEvent(ev Event) {
+	if p, ok := ev.(pooled); ok {
+		p.payloadRef().retain()
+	}
+}
+
+// releaseEvent drops a reference taken by retainEvent.
+func releaseEvent(ev Event) {
+	if p, ok := ev.(pooled); ok {
+		p.payloadRef().release()
+	}
+}
+
+// The reader publishes the analog and digital reports as pointers taken
+// from these pools, which are not boxed into an Event, and puts them back
+// once they are delivered. Receivers read them through eventOf.
+var (
+	analogEvents  = sync.Pool{New: func() interface{} { return new(AnalogEvent) }}
+	digitalEvents = sync.Pool{New: func() interface{} { return new(DigitalEvent) }}
+)
+
+// eventOf returns the event a pooled report points to, or ev itself.
+// The pointer is only valid during publish and must not be kept.
+func eventOf(ev Event) Event {
+	switch e := ev.(type) {
+	case *AnalogEvent:
+		return *e
+	case *DigitalEvent:
+		return *e
+	}
+	return ev
+}
+
+// eventType returns the type of the event ev carries.
+func eventType(ev Event) reflect.Type {
+	switch ev.(type) {
+	case *AnalogEvent:
+		return analogEventType
+	case *DigitalEvent:
+		return digitalEventType
+	}
+	return reflect.TypeOf(ev)
+}
+
+var (
+	analogEventType  = reflect.TypeOf(AnalogEvent{})
+	digitalEventType = reflect.TypeOf(DigitalEvent{})
+)
+
+// ErrorEvent reports a failure of the goroutine reading from the board,
+// such as a closed connection or a message that could not be handled.
+type ErrorEvent struct {
+	Header
+	Err error
+}
+
+// Filter selects the events delivered to a subscriber. The zero Filter
+// matches every event.
+type Filter struct {
+	// Pins restricts delivery to analog events of these pins and
+	// digital events of the ports containing them. Events that don't
+	// belong to a pin are not delivered when Pins is set.
+	Pins []int
+
+	// Types restricts delivery to events of the same types as these,
+	// as in Types: []Event{AnalogEvent{}, DigitalEvent{}}.
+	Types []Event
+
+	// MinChange drops analog events of a pin whose value differs by
+	// less than MinChange from the last one delivered.
+	MinChange int
+
+	// MaxRate limits the events delivered per second for each pin,
+	// port or, for other events, event type. Extra events are dropped.
+	MaxRate float64
+}
+
+// filterState is the per subscription state of MinChange and MaxRate.
+type filterState struct {
+	values map[int]int
+	times  map[interface{}]time.Time
+}
+
+// admit reports whether ev passes the MinChange and MaxRate limits and
+// records it if so. It is called with the bus lock held.
+func (f Filter) admit(st *filterState, ev Event) bool {
+	if f.MinChange <= 0 && f.MaxRate <= 0 {
+		return true
+	}
+	if f.MinChange > 0 {
+		if a, ok := eventOf(ev).(AnalogEvent); ok {
+			if last, seen := st.values[a.Pin]; seen {
+				d := a.Value - last
+				if d < 0 {
+					d = -d
+				}
+				if d < f.MinChange {
+					return false
+				}
+			}
+		}
+	}
+	var key interface{} = eventType(ev)
+	switch e := eventOf(ev).(type) {
+	case AnalogEvent:
+		key = e.Pin
+	case DigitalEvent:
+		key = -1 - int(e.Port)
+	}
+	if f.MaxRate > 0 {
+		if last, seen := st.times[key]; seen && ev.EventTime().Sub(last).Seconds() < 1/f.MaxRate {
+			return false
+		}
+	}
+
+	if st.values == nil {
+		st.values = make(map[int]int)
+		st.times = make(map[interface{}]time.Time)
+	}
+	if a, ok := eventOf(ev).(AnalogEvent); ok {
+		st.values[a.Pin] = a.Value
+	}
+	st.times[key] = ev.EventTime()
+	return true
+}
+
+func (f Filter) match(ev Event) bool {
+	if len(f.Types) > 0 {
+		t := eventType(ev)
+		found := false
+		for _, want := range f.Types {
+			if reflect.TypeOf(want) == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.Pins) == 0 {
+		return true
+	}
+	e := eventOf(ev)
+	for _, pin := range f.Pins {
+		switch e := e.(type) {
+		case AnalogEvent:
+			if e.Pin == pin {
+				return true
+			}
+		case DigitalEvent:
+			if pin/8 == int(e.Port) {
+				return true
+			}
+		case SlopeEvent:
+			if e.Pin == pin {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type subscription struct {
+	key     interface{}
+	filter  Filter
+	state   filterState
+	deliver func(Event) bool // reports false if the event was dropped
+	close   func()
+
+	// replay, if set, is called when the subscription is added, before
+	// any event is delivered.
+	replay func(*history)
+}
+
+// bus fans events out to subscriptions.
+type bus struct {
+	mu      sync.Mutex
+	subs    []*subscription
+	closed  bool
+	dropped uint64
+	history *history
+}
+
+func (b *bus) add(s *subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		s.close()
+		return
+	}
+	if s.replay != nil {
+		s.replay(b.history)
+	}
+	b.subs = append(b.subs, s)
+}
+
+func (b *bus) remove(key interface{}) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, s := range b.subs {
+		if s.key == key {
+			b.subs = append(b.subs[:i], b.subs[i+1:]...)
+			s.close()
+			return true
+		}
+	}
+	return false
+}
+
+func (b *bus) publish(ev Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.history != nil {
+		b.history.record(ev)
+	}
+	for _, s := range b.subs {
+		if s.filter.match(ev) && s.filter.admit(&s.state, ev) && !s.deliver(ev) {
+			b.dropped++
+		}
+	}
+}
+
+func (b *bus) closeAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, s := range b.subs {
+		s.close()
+	}
+	b.subs = nil
+	b.closed = true
+}
+
+// Subscribe returns a channel of the events of type T that match
+// filter. Subscribing to Event itself delivers events of every type.
+// The channel is closed by Unsubscribe or when the client is closed.
+//
+//	for ev := range firmata.Subscribe[firmata.AnalogEvent](c, firmata.Filter{Pins: []int{14}}) {
+//		fmt.Println(ev.Value)
+//	}
+func Subscribe[T Event](c *Client, filter Filter) <-chan T {
+	ch := make(chan T, subscriptionBuffer)
+	c.bus.add(&subscription{
+		key:    (<-chan T)(ch),
+		filter: filter,
+		deliver: func(ev Event) bool {
+			t, ok := eventOf(ev).(T)
+			if !ok {
+				return true
+			}
+			retainEvent(ev)
+			select {
+			case ch <- t:
+				return true
+			default:
+				releaseEvent(ev)
+				return false
+			}
+		},
+		close: func() { close(ch) },
+	})
+	return ch
+}
+
+// Unsubscribe stops the delivery of events to ch, which must have been
+// returned by Subscribe, and closes it.
+func Unsubscribe[T Event](c *Client, ch <-chan T) {
+	c.bus.remove(ch)
+}
+
+// PinEvent is a change of a single pin. Digital pins report 0 or 1.
+type PinEvent struct {
+	Header
+	Pin    int
+	Value  int
+	Analog bool
+
+	// Scaled is Value converted with the PinMeta of the pin, in Unit.
+	Scaled float64
+	Unit   string
+}
+
+// SubscribePin returns a channel of the events of pin alone and a
+// function that unsubscribes and closes the channel. Digital pins are
+// reported when their level changes, analog pins on every sample.
+func (c *Client) SubscribePin(pin int) (<-chan PinEvent, func()) {
+	return c.subscribePin(pin, 0)
+}
+
+func (c *Client) subscribePin(pin int, n int) (<-chan PinEvent, func()) {
+	ch := make(chan PinEvent, subscriptionBuffer)
+	c.bus.add(&subscription{
+		key:    (<-chan PinEvent)(ch),
+		filter: Filter{Pins: []int{pin}},
+		deliver: func(ev Event) bool {
+			var pe PinEvent
+			switch e := eventOf(ev).(type) {
+			case AnalogEvent:
+				if !c.UsedAsAnalog(pin) {
+					return true
+				}
+				pe = PinEvent{Header: e.Header, Pin: pin, Value: e.Value, Analog: true, Scaled: e.Scaled, Unit: e.Unit}
+			case DigitalEvent:
+				if c.UsedAsAnalog(pin) {
+					return true
+				}
+				bit := byte(1) << uint(pin%8)
+				if e.Changed&bit == 0 {
+					return true
+				}
+				pe = PinEvent{Header: e.Header, Pin: pin}
+				if e.Value&bit != 0 {
+					pe.Value = 1
+				}
+			default:
+				return true
+			}
+			select {
+			case ch <- c.label(pe):
+				return true
+			default:
+				return false
+			}
+		},
+		close:  func() { close(ch) },
+		replay: func(h *history) { h.replay(pin, n, ch, c.label) },
+	})
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() { c.bus.remove((<-chan PinEvent)(ch)) })
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.23
+
+package firmata
+
+import (
+	"context"
+	"iter"
+)
+
+// Events returns an iterator over all events received from the board.
+// Iteration subscribes on start and unsubscribes when the loop exits,
+// ctx is canceled or the client is closed.
+//
+//	for ev := range c.Events(ctx) {
+//		fmt.Println(ev)
+//	}
+func (c *Client) Events(ctx context.Context) iter.Seq[Event] {
+	return func(yield func(Event) bool) {
+		ch := Subscribe[Event](c, Filter{})
+		defer Unsubscribe(c, ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-ch:
+				if !ok || !yield(ev) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata_test
+
+import (
+	"testing"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/simulator"
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// batch is the number of reports injected at once by the benchmarks,
+// fewer than a subscription buffers so that none is dropped.
+const batch = 32
+
+func benchmarkReports(b *testing.B, report wire.Message, recv func(c *firmata.Client) func()) {
+	sim := simulator.New(nil)
+	c, err := firmata.NewClientConn(sim)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer c.Close()
+	wait := recv(c)
+
+	var reports []byte
+	for i := 0; i < batch; i++ {
+		reports = append(reports, report.Bytes()...)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i += batch {
+		sim.Inject(reports)
+		for j := 0; j < batch; j++ {
+			wait()
+		}
+	}
+}
+
+func BenchmarkAnalogEvents(b *testing.B) {
+	benchmarkReports(b, wire.Analog{Channel: 0, Value: 512}, func(c *firmata.Client) func() {
+		ch := firmata.Subscribe[firmata.AnalogEvent](c, firmata.Filter{})
+		return func() { <-ch }
+	})
+}
+
+func BenchmarkDigitalEvents(b *testing.B) {
+	benchmarkReports(b, wire.Digital{Port: 0, Value: 0x04}, func(c *firmata.Client) func() {
+		ch := firmata.Subscribe[firmata.DigitalEvent](c, firmata.Filter{})
+		return func() { <-ch }
+	})
+}
+
+func BenchmarkI2CEvents(b *testing.B) {
+	reply := wire.SysEx{Command: byte(firmata.I2CReply), Data: wire.EncodeBytes([]byte{0x48, 0, 0, 0, 1, 2})}
+	benchmarkReports(b, reply, func(c *firmata.Client) func() {
+		ch := firmata.Subscribe[firmata.I2CEvent](c, firmata.Filter{})
+		return func() { (<-ch).Release() }
+	})
+}
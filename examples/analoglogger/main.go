@@ -0,0 +1,93 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command analoglogger prints the voltage on A0 as CSV, one line per
+// sample. With -sim, the voltage ramps up and down.
+//
+// Usage:
+//
+//	analoglogger -dev /dev/ttyACM0 > samples.csv
+//	analoglogger -sim -for 3s
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/examples/internal/board"
+)
+
+// a0 is the pin number of A0 on an Uno.
+const a0 = 14
+
+func main() {
+	c, sim, err := board.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+	samples, stop, err := setup(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer stop()
+	ctx, cancel := board.Context()
+	defer cancel()
+
+	if sim != nil {
+		go func() {
+			raw, step := 0, 64
+			board.Every(ctx, 100*time.Millisecond, func() {
+				if raw+step < 0 || raw+step > 1023 {
+					step = -step
+				}
+				raw += step
+				sim.SetAnalog(a0, raw)
+			})
+		}()
+	}
+	logSamples(ctx, samples, os.Stdout)
+}
+
+// setup starts the sampling of A0, scaled to volts, and returns its
+// samples.
+func setup(c *firmata.Client) (<-chan firmata.PinEvent, func(), error) {
+	c.SetPinMeta(a0, firmata.PinMeta{
+		Unit:      "V",
+		Transform: func(raw int) float64 { return float64(raw) * 5 / 1023 },
+	})
+	if err := c.EnableAnalogInput(a0, true); err != nil {
+		return nil, nil, err
+	}
+	samples, stop := c.SubscribePin(a0)
+	return samples, stop, nil
+}
+
+// logSamples writes the samples to w as CSV until ctx is done.
+func logSamples(ctx context.Context, samples <-chan firmata.PinEvent, w io.Writer) {
+	fmt.Fprintln(w, "time,raw,volts")
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-samples:
+			fmt.Fprintf(w, "%s,%d,%.3f\n", ev.Time.Format(time.RFC3339Nano), ev.Value, ev.Scaled)
+		}
+	}
+}
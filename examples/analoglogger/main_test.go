@@ -0,0 +1,78 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rakyll/go-firmata/examples/internal/board"
+)
+
+func TestLogSamples(t *testing.T) {
+	c, sim, err := board.Simulate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	samples, stop, err := setup(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	r, w := io.Pipe()
+	go func() {
+		logSamples(ctx, samples, w)
+		w.Close()
+	}()
+
+	lines := bufio.NewScanner(r)
+	if !lines.Scan() || lines.Text() != "time,raw,volts" {
+		t.Fatalf("header = %q; want %q", lines.Text(), "time,raw,volts")
+	}
+	go func() {
+		// Keep sampling until the board has enabled reporting.
+		for ctx.Err() == nil {
+			sim.SetAnalog(a0, 1023)
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	// The board reports the value of A0 as soon as reporting starts,
+	// before the simulated voltage changes.
+	var fields []string
+	for len(fields) < 2 || fields[1] != "1023" {
+		if !lines.Scan() {
+			t.Fatal("no sample of 1023 logged")
+		}
+		fields = strings.Split(lines.Text(), ",")
+		if len(fields) != 3 {
+			t.Fatalf("sample = %q; want 3 fields", lines.Text())
+		}
+		if _, err := time.Parse(time.RFC3339Nano, fields[0]); err != nil {
+			t.Errorf("time of the sample: %v", err)
+		}
+	}
+	if fields[2] != "5.000" {
+		t.Errorf("volts = %s; want 5.000", fields[2])
+	}
+	cancel()
+	io.Copy(io.Discard, r)
+}
@@ -0,0 +1,53 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command blink blinks the LED on pin 13.
+//
+// Usage:
+//
+//	blink -dev /dev/ttyACM0
+//	blink -sim -for 3s
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/examples/internal/board"
+)
+
+const led = 13
+
+func main() {
+	c, _, err := board.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.SetPinMode(led, firmata.Output); err != nil {
+		log.Fatal(err)
+	}
+	ctx, cancel := board.Context()
+	defer cancel()
+
+	on := false
+	board.Every(ctx, 250*time.Millisecond, func() {
+		on = !on
+		if err := c.DigitalWrite(led, on); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("led on: %v", on)
+	})
+}
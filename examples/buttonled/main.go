@@ -0,0 +1,93 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command buttonled lights the LED on pin 13 while the button on pin 2
+// is pressed. The button pulls the pin high when pressed. With -sim,
+// the button is pressed and released every second.
+//
+// Usage:
+//
+//	buttonled -dev /dev/ttyACM0
+//	buttonled -sim -for 5s
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/examples/internal/board"
+)
+
+const (
+	button = 2
+	led    = 13
+)
+
+func main() {
+	c, sim, err := board.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+	events, stop, err := setup(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer stop()
+	ctx, cancel := board.Context()
+	defer cancel()
+
+	if sim != nil {
+		go func() {
+			pressed := false
+			board.Every(ctx, 500*time.Millisecond, func() {
+				pressed = !pressed
+				sim.SetDigital(button, pressed)
+			})
+		}()
+	}
+	if err := follow(ctx, c, events); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// setup configures the pins and returns the events of the button.
+func setup(c *firmata.Client) (<-chan firmata.PinEvent, func(), error) {
+	if err := c.SetPinModes(map[uint8]firmata.PinMode{button: firmata.Input, led: firmata.Output}); err != nil {
+		return nil, nil, err
+	}
+	if err := c.EnableDigitalInput(button, true); err != nil {
+		return nil, nil, err
+	}
+	events, stop := c.SubscribePin(button)
+	return events, stop, nil
+}
+
+// follow lights the LED while the button is pressed, until ctx is done.
+func follow(ctx context.Context, c *firmata.Client, events <-chan firmata.PinEvent) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev := <-events:
+			pressed := ev.Value == 1
+			if err := c.DigitalWrite(led, pressed); err != nil {
+				return err
+			}
+			log.Printf("button pressed: %v", pressed)
+		}
+	}
+}
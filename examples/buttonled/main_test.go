@@ -0,0 +1,61 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rakyll/go-firmata/examples/internal/board"
+)
+
+func TestFollow(t *testing.T) {
+	c, sim, err := board.Simulate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	events, stop, err := setup(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- follow(ctx, c, events) }()
+
+	for _, pressed := range []bool{true, false, true} {
+		want := 0
+		if pressed {
+			want = 1
+		}
+		// The board may not have enabled reporting yet; press the
+		// button again until the LED follows.
+		for sim.Output(led) != want {
+			if ctx.Err() != nil {
+				t.Fatalf("LED = %d with the button pressed: %v; want %d", sim.Output(led), pressed, want)
+			}
+			sim.SetDigital(button, !pressed)
+			sim.SetDigital(button, pressed)
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("follow: %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !js
+
+// Command dashboard serves a web page showing the live state of a board
+// and lets it toggle digital outputs. It only uses the public API of
+// the firmata package.
+//
+// Usage:
+//
+//	dashboard -dev /dev/ttyACM0 -outputs 13,12 -inputs 2,3 -analog 14,15
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+)
+
+var (
+	dev     = flag.String("dev", "/dev/ttyACM0", "serial device of the board")
+	baud    = flag.Int("baud", 57600, "baud rate of the board")
+	addr    = flag.String("addr", "localhost:8080", "HTTP address to listen on")
+	outputs = flag.String("outputs", "13", "comma separated digital output pins")
+	inputs  = flag.String("inputs", "", "comma separated digital input pins")
+	analog  = flag.String("analog", "", "comma separated analog input pins")
+	units   = flag.String("units", "", "comma separated pin units, as in 14=lux")
+)
+
+//go:embed index.html
+var index []byte
+
+// pinUpdate is sent to the page for every pin change.
+type pinUpdate struct {
+	Pin    int    `json:"pin"`
+	Mode   string `json:"mode"`
+	Value  int    `json:"value"`
+	Analog bool   `json:"analog"`
+	Unit   string `json:"unit,omitempty"`
+}
+
+type server struct {
+	c       *firmata.Client
+	outputs []int
+	inputs  []int
+	analog  []int
+}
+
+func main() {
+	flag.Parse()
+	c, err := firmata.NewClient(*dev, *baud)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+
+	s := &server{c: c, outputs: pins(*outputs), inputs: pins(*inputs), analog: pins(*analog)}
+	setUnits(c, *units)
+	if err := s.setup(); err != nil {
+		log.Fatal(err)
+	}
+	http.HandleFunc("/", s.serveIndex)
+	http.HandleFunc("/state", s.serveState)
+	http.HandleFunc("/events", s.serveEvents)
+	http.HandleFunc("/write", s.serveWrite)
+	log.Printf("serving the dashboard of %v on http://%v", *dev, *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+func pins(list string) []int {
+	var pins []int
+	for _, f := range strings.Split(list, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		p, err := strconv.Atoi(f)
+		if err != nil {
+			log.Fatalf("invalid pin %q", f)
+		}
+		pins = append(pins, p)
+	}
+	return pins
+}
+
+// setUnits attaches the units listed in list to their pins.
+func setUnits(c *firmata.Client, list string) {
+	for _, f := range strings.Split(list, ",") {
+		pin, unit, ok := strings.Cut(strings.TrimSpace(f), "=")
+		if !ok {
+			continue
+		}
+		p, err := strconv.Atoi(pin)
+		if err != nil {
+			log.Fatalf("invalid pin %q", pin)
+		}
+		c.SetPinMeta(p, firmata.PinMeta{Unit: unit})
+	}
+}
+
+func (s *server) setup() error {
+	desired := firmata.BoardState{
+		Modes:     make(map[uint8]firmata.PinMode),
+		Reporting: make(map[uint8]bool),
+	}
+	for _, p := range s.outputs {
+		desired.Modes[uint8(p)] = firmata.Output
+	}
+	for _, p := range s.inputs {
+		desired.Modes[uint8(p)] = firmata.Input
+		desired.Reporting[uint8(p)] = true
+	}
+	for _, p := range s.analog {
+		desired.Modes[uint8(p)] = firmata.Analog
+		desired.Reporting[uint8(p)] = true
+	}
+	return s.c.Apply(desired)
+}
+
+func (s *server) serveIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(index)
+}
+
+// serveState replies with a snapshot of all the pins on the page.
+func (s *server) serveState(w http.ResponseWriter, r *http.Request) {
+	var all []uint8
+	for _, list := range [][]int{s.outputs, s.inputs, s.analog} {
+		for _, p := range list {
+			all = append(all, uint8(p))
+		}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	states, err := s.c.QueryPinStates(ctx, all...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	updates := make([]pinUpdate, 0, len(states))
+	for _, st := range states {
+		m, _ := s.c.PinMeta(st.Pin)
+		updates = append(updates, pinUpdate{st.Pin, st.Mode.String(), st.State, st.Mode == firmata.Analog, m.Unit})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updates)
+}
+
+// serveEvents streams pin changes as server-sent events.
+func (s *server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	events := make(chan firmata.PinEvent, 64)
+	for _, list := range [][]int{s.inputs, s.analog} {
+		for _, p := range list {
+			ch, stop := s.c.SubscribePin(p)
+			defer stop()
+			go func() {
+				for ev := range ch {
+					select {
+					case events <- ev:
+					default:
+					}
+				}
+			}()
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+	for {
+		select {
+		case ev := <-events:
+			b, _ := json.Marshal(pinUpdate{Pin: ev.Pin, Value: ev.Value, Analog: ev.Analog, Unit: ev.Unit})
+			fmt.Fprintf(w, "data: %s\n\n", b)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// serveWrite sets a digital output, as in POST /write?pin=13&value=1.
+func (s *server) serveWrite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	pin, err := strconv.Atoi(r.FormValue("pin"))
+	if err != nil {
+		http.Error(w, "invalid pin", http.StatusBadRequest)
+		return
+	}
+	err = s.c.Apply(firmata.BoardState{
+		Outputs: map[uint8]int{uint8(pin): boolValue(r.FormValue("value") == "1")},
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func boolValue(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,85 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command i2csensor reads a TMP102 temperature sensor over I2C every
+// second. With -sim, the simulator plays the part of the sensor and
+// warms up slowly.
+//
+// Usage:
+//
+//	i2csensor -dev /dev/ttyACM0
+//	i2csensor -sim -for 5s
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/rakyll/go-firmata/examples/internal/board"
+	"github.com/rakyll/go-firmata/regmap"
+	"github.com/rakyll/go-firmata/simulator"
+)
+
+const (
+	addr    = 0x48 // TMP102 with ADD0 to ground
+	regTemp = 0x00
+)
+
+func main() {
+	c, sim, err := board.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.I2CConfig(0); err != nil {
+		log.Fatal(err)
+	}
+	ctx, cancel := board.Context()
+	defer cancel()
+
+	celsius := 20.0
+	warm := func() {
+		if sim != nil {
+			simulate(sim, celsius)
+			celsius += 0.25
+		}
+	}
+	warm()
+	regs := regmap.New(c, addr)
+	board.Every(ctx, time.Second, func() {
+		t, err := temperature(regs)
+		if err != nil {
+			log.Printf("reading the sensor: %v", err)
+			return
+		}
+		log.Printf("temperature: %.2f °C", t)
+		warm()
+	})
+}
+
+// temperature reads the temperature in °C. It is a 12-bit count of
+// 1/16 °C in the upper bits of the register.
+func temperature(regs *regmap.Map) (float64, error) {
+	v, err := regs.ReadUint16(regTemp)
+	if err != nil {
+		return 0, err
+	}
+	return float64(int16(v)>>4) / 16, nil
+}
+
+// simulate makes the simulated sensor report celsius.
+func simulate(sim *simulator.Board, celsius float64) {
+	v := uint16(int16(celsius*16)) << 4
+	sim.SetI2C(addr, regTemp, byte(v>>8), byte(v))
+}
@@ -0,0 +1,44 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/rakyll/go-firmata/examples/internal/board"
+	"github.com/rakyll/go-firmata/regmap"
+)
+
+func TestTemperature(t *testing.T) {
+	c, sim, err := board.Simulate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.I2CConfig(0); err != nil {
+		t.Fatal(err)
+	}
+	regs := regmap.New(c, addr)
+	for _, celsius := range []float64{20, 21.5, 0, -10.25, 127.9375} {
+		simulate(sim, celsius)
+		got, err := temperature(regs)
+		if err != nil {
+			t.Fatalf("temperature at %v °C: %v", celsius, err)
+		}
+		if got != celsius {
+			t.Errorf("temperature = %v °C; want %v °C", got, celsius)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package board connects the examples to a board given on the command
+// line, or to the simulator so that they run without hardware.
+package board
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/simulator"
+)
+
+var (
+	dev      = flag.String("dev", "/dev/ttyACM0", "serial device of the board")
+	baud     = flag.Int("baud", 57600, "baud rate of the board")
+	sim      = flag.Bool("sim", false, "run against the simulator instead of a board")
+	duration = flag.Duration("for", 0, "stop after this long; zero runs until interrupted")
+)
+
+// Open parses the flags and connects to the board. With -sim it also
+// returns the simulated board, whose inputs the example then plays with
+// in place of the hardware; it returns a nil board otherwise.
+func Open() (*firmata.Client, *simulator.Board, error) {
+	flag.Parse()
+	if !*sim {
+		c, err := openSerial(*dev, *baud)
+		return c, nil, err
+	}
+	return Simulate()
+}
+
+// Simulate connects to a new simulated board, as Open does with -sim.
+func Simulate() (*firmata.Client, *simulator.Board, error) {
+	b := simulator.New(nil)
+	c, err := firmata.NewClientConn(b)
+	if err != nil {
+		b.Close()
+		return nil, nil, err
+	}
+	return c, b, nil
+}
+
+// Context returns a context canceled on interrupt or after the time
+// given with -for.
+func Context() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	if *duration <= 0 {
+		return ctx, stop
+	}
+	ctx, cancel := context.WithTimeout(ctx, *duration)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// Every calls fn every d until ctx is done.
+func Every(ctx context.Context, d time.Duration, fn func()) {
+	t := time.NewTicker(d)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			fn()
+		}
+	}
+}
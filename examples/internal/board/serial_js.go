@@ -0,0 +1,27 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package board
+
+import (
+	"errors"
+
+	"github.com/rakyll/go-firmata"
+)
+
+// openSerial fails in the browser, where serial ports are opened by the
+// page through the Web Serial API, see transport.WebSerial.
+func openSerial(dev string, baud int) (*firmata.Client, error) {
+	return nil, errors.New("board: serial devices are not available in the browser, use -sim")
+}
@@ -0,0 +1,69 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command servosweep sweeps the servo on pin 9 back and forth.
+//
+// Usage:
+//
+//	servosweep -dev /dev/ttyACM0
+//	servosweep -sim -for 3s
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/examples/internal/board"
+)
+
+const servo = 9
+
+func main() {
+	c, _, err := board.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.ServoConfig(servo, firmata.DefaultServoMinPulse, firmata.DefaultServoMaxPulse); err != nil {
+		log.Fatal(err)
+	}
+	ctx, cancel := board.Context()
+	defer cancel()
+	if err := sweep(ctx, c, 50*time.Millisecond); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// sweep moves the servo by 10 degrees every interval, back and forth
+// between 0 and 180, until ctx is done.
+func sweep(ctx context.Context, c *firmata.Client, interval time.Duration) error {
+	var err error
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	angle, step := 0, 10
+	board.Every(ctx, interval, func() {
+		if angle+step < 0 || angle+step > 180 {
+			step = -step
+		}
+		angle += step
+		if err = c.ServoWrite(servo, angle); err != nil {
+			cancel()
+			return
+		}
+		log.Printf("angle: %d", angle)
+	})
+	return err
+}
@@ -0,0 +1,60 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/examples/internal/board"
+)
+
+func TestSweep(t *testing.T) {
+	c, sim, err := board.Simulate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.ServoConfig(servo, firmata.DefaultServoMinPulse, firmata.DefaultServoMaxPulse); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- sweep(ctx, c, time.Millisecond) }()
+
+	// The servo must reach both ends and stay between them.
+	seen := make(map[int]bool)
+	for !seen[0] || !seen[180] {
+		if ctx.Err() != nil {
+			t.Fatalf("servo didn't sweep from 0 to 180, angles seen: %v", seen)
+		}
+		angle := sim.Output(servo)
+		if angle < 0 || angle > 180 || angle%10 != 0 {
+			t.Fatalf("servo angle = %d; want a multiple of 10 in [0, 180]", angle)
+		}
+		seen[angle] = true
+		time.Sleep(time.Millisecond)
+	}
+	if mode := sim.Mode(servo); mode != firmata.Servo {
+		t.Errorf("mode of pin %d = %v; want %v", servo, mode, firmata.Servo)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("sweep: %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import "io"
+
+// FailoverEvent is published when the client lost its primary
+// connection and tried to switch to the standby one.
+type FailoverEvent struct {
+	Header
+
+	// Cause is the error the primary connection failed with.
+	Cause error
+
+	// Err is nil if the client switched to the standby connection.
+	Err error
+}
+
+// WithStandby gives the client a second way to reach the board, or a
+// mirrored board, such as TCP for a board also on USB. When the
+// primary connection is lost after the handshake, the client dials the
+// standby one, moves the command stream over to it and replays its
+// configuration and outputs there. If the standby connection is lost in turn, or
+// can't be opened, the client reconnects to the primary one as
+// configured by WithReconnect.
+func WithStandby(dial func() (io.ReadWriteCloser, error)) Option {
+	return func(c *Client) {
+		c.standby = dial
+	}
+}
+
+// OnStandby reports whether the client is using its standby connection.
+func (c *Client) OnStandby() bool {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	return c.onStandby
+}
+
+// failover switches to the standby connection after the primary one,
+// identified by gen, failed with cause. It returns the standby
+// connection or nil if there is none or the client already uses it.
+func (c *Client) failover(gen *int, cause error) io.ReadWriteCloser {
+	if c.standby == nil || !c.isInited() {
+		return nil
+	}
+	c.connMu.Lock()
+	active := c.onStandby || c.closing
+	c.connMu.Unlock()
+	if active {
+		return nil
+	}
+	conn, err := c.standby()
+	if err != nil {
+		c.bus.publish(FailoverEvent{Header{c.clock.Now()}, cause, err})
+		return nil
+	}
+
+	c.connMu.Lock()
+	if c.closing {
+		c.connMu.Unlock()
+		conn.Close()
+		return nil
+	}
+	if c.connGen != *gen {
+		// Swapped while dialing.
+		conn.Close()
+		conn, *gen = c.conn, c.connGen
+		c.connMu.Unlock()
+		return conn
+	}
+	c.conn.Close()
+	c.conn = conn
+	c.connGen++
+	*gen = c.connGen
+	c.onStandby = true
+	c.stuckWrite = nil
+	c.connMu.Unlock()
+
+	c.counters.failovers.Add(1)
+	c.bus.publish(FailoverEvent{Header{c.clock.Now()}, cause, nil})
+	go c.reapply()
+	return conn
+}
+
+// reapply replays the configuration on the standby connection and
+// writes the outputs again, since a mirrored board never received them.
+func (c *Client) reapply() error {
+	if err := c.Replay(); err != nil {
+		return err
+	}
+	c.stateMu.Lock()
+	outputs := make(map[uint8]int, len(c.outputs))
+	modes := make(map[uint8]PinMode, len(c.outputs))
+	for pin, v := range c.outputs {
+		outputs[pin], modes[pin] = v, c.modes[pin]
+	}
+	c.stateMu.Unlock()
+	for pin, v := range outputs {
+		var err error
+		switch modes[pin] {
+		case Output:
+			err = c.digitalWrite(pin, v != 0)
+		case PWM, Servo, DAC:
+			err = c.analogWrite(pin, v, modes[pin] == DAC)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Feature is a driver for a firmware extension that uses its own SysEx
+// commands. Registering it with a client routes those commands to it,
+// so extensions can be supported outside of this package.
+type Feature interface {
+	// SysExCommands returns the commands the feature claims.
+	SysExCommands() []SysExCommand
+
+	// Setup is called when the feature is registered, and may
+	// configure the firmware.
+	Setup(c *Client) error
+
+	// Teardown is called when the feature is unregistered or the
+	// client is closed.
+	Teardown(c *Client) error
+
+	// Decode turns an incoming message of a claimed command into an
+	// event that is published to subscribers. It returns nil for
+	// messages that produce no event. It runs on the goroutine reading
+	// from the board and must not block.
+	Decode(cmd SysExCommand, data []byte) Event
+}
+
+// builtinSysEx are the commands the client decodes itself.
+var builtinSysEx = map[SysExCommand]bool{
+	StringData:            true,
+	CapabilityResponse:    true,
+	AnalogMappingResponse: true,
+	ReportFirmware:        true,
+	PinStateResponse:      true,
+	I2CReply:              true,
+	Serial:                true,
+	SysExSPI:              true,
+	Uptime:                true,
+	Echo:                  true,
+	PulseIn:               true,
+	Timestamp:             true,
+	StepperData:           true,
+	AccelStepperData:      true,
+	EncoderData:           true,
+	Scheduler:             true,
+}
+
+// Register claims the SysEx commands of f and sets it up. It fails if a
+// command is decoded by the client or claimed by another feature.
+// Features are compared by identity, so f is usually a pointer, and f
+// must be of a comparable type.
+func (c *Client) Register(f Feature) error {
+	if !reflect.TypeOf(f).Comparable() {
+		return fmt.Errorf("feature of type %T is not comparable", f)
+	}
+	cmds := f.SysExCommands()
+	c.sysExMu.Lock()
+	for _, cmd := range cmds {
+		if builtinSysEx[cmd] {
+			c.sysExMu.Unlock()
+			return fmt.Errorf("sysex command %v is handled by the client", cmd)
+		}
+		if c.features[cmd] != nil {
+			c.sysExMu.Unlock()
+			return fmt.Errorf("sysex command %v is already claimed", cmd)
+		}
+	}
+	if c.features == nil {
+		c.features = make(map[SysExCommand]Feature)
+	}
+	for _, cmd := range cmds {
+		c.features[cmd] = f
+	}
+	c.sysExMu.Unlock()
+
+	if err := f.Setup(c); err != nil {
+		c.release(f)
+		return err
+	}
+	return nil
+}
+
+// RegisteredFeature returns the feature that claimed cmd, or nil.
+func (c *Client) RegisteredFeature(cmd SysExCommand) Feature {
+	c.sysExMu.Lock()
+	defer c.sysExMu.Unlock()
+	return c.features[cmd]
+}
+
+// Unregister releases the commands of f and tears it down.
+func (c *Client) Unregister(f Feature) error {
+	if !c.release(f) {
+		return fmt.Errorf("feature is not registered")
+	}
+	return f.Teardown(c)
+}
+
+// release removes the commands claimed by f and reports whether it
+// claimed any.
+func (c *Client) release(f Feature) bool {
+	c.sysExMu.Lock()
+	defer c.sysExMu.Unlock()
+	found := false
+	for cmd, g := range c.features {
+		if g == f {
+			delete(c.features, cmd)
+			found = true
+		}
+	}
+	return found
+}
+
+// teardownFeatures unregisters all features.
+func (c *Client) teardownFeatures() {
+	c.sysExMu.Lock()
+	var all []Feature
+	for _, f := range c.features {
+		seen := false
+		for _, g := range all {
+			if g == f {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			all = append(all, f)
+		}
+	}
+	c.features = nil
+	c.sysExMu.Unlock()
+	for _, f := range all {
+		f.Teardown(c)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/simulator"
+	"github.com/rakyll/go-firmata/wire"
+)
+
+const counterSysEx firmata.SysExCommand = 0x0C
+
+// countEvent is the event of the counter feature.
+type countEvent struct {
+	firmata.Header
+	Count int
+}
+
+// counter is a feature reporting a counter kept by the firmware.
+type counter struct {
+	setup, teardown int
+}
+
+func (f *counter) SysExCommands() []firmata.SysExCommand {
+	return []firmata.SysExCommand{counterSysEx}
+}
+
+func (f *counter) Setup(c *firmata.Client) error {
+	f.setup++
+	return nil
+}
+
+func (f *counter) Teardown(c *firmata.Client) error {
+	f.teardown++
+	return nil
+}
+
+func (f *counter) Decode(cmd firmata.SysExCommand, data []byte) firmata.Event {
+	if len(data) < 1 {
+		return nil
+	}
+	return countEvent{Header: firmata.Header{Time: time.Now()}, Count: int(data[0])}
+}
+
+// sliceFeature is a feature of an uncomparable type.
+type sliceFeature []firmata.SysExCommand
+
+func (f sliceFeature) SysExCommands() []firmata.SysExCommand             { return f }
+func (f sliceFeature) Setup(c *firmata.Client) error                     { return nil }
+func (f sliceFeature) Teardown(c *firmata.Client) error                  { return nil }
+func (f sliceFeature) Decode(firmata.SysExCommand, []byte) firmata.Event { return nil }
+
+func TestRegister(t *testing.T) {
+	b := simulator.New(nil)
+	c, err := firmata.NewClientConn(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &counter{}
+	if err := c.Register(f); err != nil {
+		t.Fatal(err)
+	}
+	if f.setup != 1 || c.RegisteredFeature(counterSysEx) != f {
+		t.Fatal("feature not set up")
+	}
+	if err := c.Register(&counter{}); err == nil {
+		t.Error("a claimed command was registered twice")
+	}
+	if err := c.Register(sliceFeature{0x0B}); err == nil {
+		t.Error("a feature of an uncomparable type was registered")
+	}
+	if err := c.Register(sliceFeature{firmata.I2CReply}); err == nil {
+		t.Error("a command decoded by the client was registered")
+	}
+
+	events := firmata.Subscribe[countEvent](c, firmata.Filter{})
+	b.Inject(wire.SysEx{Command: byte(counterSysEx), Data: []byte{42}}.Bytes())
+	select {
+	case ev := <-events:
+		if ev.Count != 42 {
+			t.Errorf("Count = %d; want 42", ev.Count)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event decoded by the feature")
+	}
+
+	c.Close()
+	if f.teardown != 1 || c.RegisteredFeature(counterSysEx) != nil {
+		t.Error("feature not torn down by Close")
+	}
+}
+
+func TestUnregister(t *testing.T) {
+	c, err := firmata.NewClientConn(simulator.New(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	f := &counter{}
+	if err := c.Register(f); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Unregister(f); err != nil {
+		t.Fatal(err)
+	}
+	if f.teardown != 1 || c.RegisteredFeature(counterSysEx) != nil {
+		t.Error("feature not torn down by Unregister")
+	}
+	if err := c.Unregister(f); err == nil {
+		t.Error("Unregister of an unregistered feature succeeded")
+	}
+	if err := c.Register(&counter{}); err != nil {
+		t.Errorf("command not released: %v", err)
+	}
+}
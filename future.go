@@ -0,0 +1,105 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import "context"
+
+// Future is the result of a query issued without waiting for the reply.
+type Future[T any] struct {
+	done chan struct{}
+	v    T
+	err  error
+}
+
+// Done returns a channel that is closed once the result is available.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the result is available and returns it.
+func (f *Future[T]) Wait() (T, error) {
+	<-f.done
+	return f.v, f.err
+}
+
+// startAsync sends a query right away and resolves the returned future
+// when the reply arrives, the query times out or ctx is done. Sending
+// synchronously keeps queries with the same key in issue order.
+func startAsync[T any](c *Client, ctx context.Context, key queryKey, send func() error) *Future[T] {
+	f := &Future[T]{done: make(chan struct{})}
+	reply, err := c.startQuery(key, send)
+	if err != nil {
+		f.err = err
+		close(f.done)
+		return f
+	}
+	go func() {
+		defer close(f.done)
+		v, err := c.waitQuery(ctx, key, reply)
+		if err != nil {
+			f.err = err
+			return
+		}
+		f.v = v.(T)
+	}()
+	return f
+}
+
+// QueryPinStateAsync asks the board for the state of pin without
+// waiting for the reply.
+func (c *Client) QueryPinStateAsync(pin uint8) *Future[PinState] {
+	return startAsync[PinState](c, context.Background(), queryKey{cmd: PinStateResponse, id: int(pin)}, func() error {
+		return c.sendSysEx(PinStateQuery, pin&0x7F)
+	})
+}
+
+// QueryFirmwareAsync asks the board for its firmware without waiting for
+// the reply.
+func (c *Client) QueryFirmwareAsync() *Future[Firmware] {
+	return startAsync[Firmware](c, context.Background(), queryKey{cmd: ReportFirmware}, func() error {
+		return c.sendFirmwareQuery()
+	})
+}
+
+// I2CReadAsync issues an I2C read like I2CRead without waiting for the
+// reply.
+func (c *Client) I2CReadAsync(addr byte, reg int, n int) *Future[[]byte] {
+	payload, key := i2cReadRequest(addr, reg, n)
+	return startAsync[[]byte](c, context.Background(), key, func() error {
+		return c.sendSysEx(I2CRequest, payload...)
+	})
+}
+
+// QueryPinStates queries the states of pins, pipelining all queries
+// before waiting for the first reply.
+func (c *Client) QueryPinStates(ctx context.Context, pins ...uint8) ([]PinState, error) {
+	futures := make([]*Future[PinState], len(pins))
+	for i, pin := range pins {
+		pin := pin
+		futures[i] = startAsync[PinState](c, ctx, queryKey{cmd: PinStateResponse, id: int(pin)}, func() error {
+			return c.sendSysEx(PinStateQuery, pin&0x7F)
+		})
+	}
+	states := make([]PinState, len(pins))
+	var firstErr error
+	for i, f := range futures {
+		s, err := f.Wait()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		states[i] = s
+	}
+	return states, firstErr
+}
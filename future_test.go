@@ -0,0 +1,87 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/simulator"
+)
+
+func TestQueryPinStates(t *testing.T) {
+	b := simulator.New(nil)
+	c, err := firmata.NewClientConn(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.SetPinMode(13, firmata.Output); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DigitalWrite(13, true); err != nil {
+		t.Fatal(err)
+	}
+	b.SetReplyDelay(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	states, err := c.QueryPinStates(ctx, 13, 2, 13)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []firmata.PinState{
+		{Pin: 13, Mode: firmata.Output, State: 1},
+		{Pin: 2, Mode: b.Mode(2)},
+		{Pin: 13, Mode: firmata.Output, State: 1},
+	} {
+		if states[i] != want {
+			t.Errorf("state %d = %+v; want %+v", i, states[i], want)
+		}
+	}
+}
+
+func TestAsyncQueries(t *testing.T) {
+	b := simulator.New(nil)
+	c, err := firmata.NewClientConn(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.I2CConfig(0); err != nil {
+		t.Fatal(err)
+	}
+	b.SetI2C(0x20, 4, 0xAB, 0xCD)
+
+	read := c.I2CReadAsync(0x20, 4, 2)
+	fw := c.QueryFirmwareAsync()
+	select {
+	case <-read.Done():
+	case <-time.After(time.Second):
+		t.Fatal("I2C read not resolved")
+	}
+	data, err := read.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 2 || data[0] != 0xAB || data[1] != 0xCD {
+		t.Errorf("I2CReadAsync = %x; want abcd", data)
+	}
+	if f, err := fw.Wait(); err != nil || f.Name == "" {
+		t.Errorf("QueryFirmwareAsync = %+v, %v", f, err)
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package grove sets up Grove modules by the connector of the base
+// shield they are plugged into, as in grove.NewButton(c, grove.D4), so
+// that kits can be used without looking up pin numbers. The modules are
+// backed by the generic client calls and drivers.
+package grove
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/drivers/ds3231"
+	"github.com/rakyll/go-firmata/drivers/hx711"
+	"github.com/rakyll/go-firmata/drivers/ir"
+	"github.com/rakyll/go-firmata/drivers/light"
+	"github.com/rakyll/go-firmata/drivers/soil"
+	"github.com/rakyll/go-firmata/drivers/vl53l0x"
+)
+
+// Port is a connector of a Grove base shield. Digital and analog
+// connectors carry two pins, the one they are named after and the next
+// one.
+type Port string
+
+const (
+	D2 Port = "D2"
+	D3 Port = "D3"
+	D4 Port = "D4"
+	D5 Port = "D5"
+	D6 Port = "D6"
+	D7 Port = "D7"
+	D8 Port = "D8"
+
+	A0 Port = "A0"
+	A1 Port = "A1"
+	A2 Port = "A2"
+	A3 Port = "A3"
+
+	I2C Port = "I2C"
+)
+
+// pins returns the two pins of a digital or analog port.
+func (p Port) pins(c *firmata.Client) (sig, next uint8, err error) {
+	if p == I2C {
+		return 0, 0, errors.New("grove: I2C port has no pins")
+	}
+	sig, err = c.PinByName(string(p))
+	if err != nil {
+		return 0, 0, fmt.Errorf("grove: port %v: %v", p, err)
+	}
+	// The second pin of A3 is A4, the next analog input.
+	n, _ := strconv.Atoi(string(p[1:]))
+	next, err = c.PinByName(fmt.Sprintf("%c%d", p[0], n+1))
+	if err != nil {
+		return 0, 0, fmt.Errorf("grove: port %v: %v", p, err)
+	}
+	return sig, next, nil
+}
+
+func (p Port) analog() bool {
+	return strings.HasPrefix(string(p), "A")
+}
+
+// Output is a module driven by a digital level, such as an LED, a relay
+// or a buzzer.
+type Output struct {
+	c   *firmata.Client
+	Pin uint8
+}
+
+// NewLED sets up an LED.
+func NewLED(c *firmata.Client, p Port) (*Output, error) {
+	return newOutput(c, p)
+}
+
+// NewRelay sets up a relay.
+func NewRelay(c *firmata.Client, p Port) (*Output, error) {
+	return newOutput(c, p)
+}
+
+// NewBuzzer sets up an active buzzer.
+func NewBuzzer(c *firmata.Client, p Port) (*Output, error) {
+	return newOutput(c, p)
+}
+
+func newOutput(c *firmata.Client, p Port) (*Output, error) {
+	pin, _, err := p.pins(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.SetPinMode(pin, firmata.Output); err != nil {
+		return nil, err
+	}
+	return &Output{c, pin}, nil
+}
+
+// Set turns the module on or off.
+func (o *Output) Set(on bool) error {
+	return o.c.DigitalWrite(o.Pin, on)
+}
+
+func (o *Output) On() error  { return o.Set(true) }
+func (o *Output) Off() error { return o.Set(false) }
+
+// Input is a module read as a digital level, such as a button, a touch
+// sensor or a PIR motion sensor.
+type Input struct {
+	c   *firmata.Client
+	Pin uint8
+}
+
+// NewButton sets up a button.
+func NewButton(c *firmata.Client, p Port) (*Input, error) {
+	return newInput(c, p)
+}
+
+// NewTouch sets up a touch sensor.
+func NewTouch(c *firmata.Client, p Port) (*Input, error) {
+	return newInput(c, p)
+}
+
+// NewMotion sets up a PIR motion sensor.
+func NewMotion(c *firmata.Client, p Port) (*Input, error) {
+	return newInput(c, p)
+}
+
+func newInput(c *firmata.Client, p Port) (*Input, error) {
+	pin, _, err := p.pins(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.SetPinMode(pin, firmata.Input); err != nil {
+		return nil, err
+	}
+	if err := c.EnableDigitalInput(uint(pin), true); err != nil {
+		return nil, err
+	}
+	return &Input{c, pin}, nil
+}
+
+// High reports the level last reported by the module.
+func (in *Input) High() bool {
+	return in.c.PortSnapshot().High(int(in.Pin))
+}
+
+// Changes returns a channel of the level changes of the module and a
+// function that stops them.
+func (in *Input) Changes() (<-chan firmata.PinEvent, func()) {
+	return in.c.SubscribePin(int(in.Pin))
+}
+
+// Analog is a module read as an analog value, such as a rotary angle,
+// sound or light sensor.
+type Analog struct {
+	c   *firmata.Client
+	Pin uint8
+}
+
+// NewRotaryAngle sets up a rotary angle sensor.
+func NewRotaryAngle(c *firmata.Client, p Port) (*Analog, error) {
+	return newAnalog(c, p)
+}
+
+// NewSound sets up a sound sensor.
+func NewSound(c *firmata.Client, p Port) (*Analog, error) {
+	return newAnalog(c, p)
+}
+
+func newAnalog(c *firmata.Client, p Port) (*Analog, error) {
+	if !p.analog() {
+		return nil, fmt.Errorf("grove: port %v is not an analog port", p)
+	}
+	pin, _, err := p.pins(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.SetPinMode(pin, firmata.Analog); err != nil {
+		return nil, err
+	}
+	if err := c.EnableAnalogInput(uint(pin), true); err != nil {
+		return nil, err
+	}
+	return &Analog{c, pin}, nil
+}
+
+// Values returns a channel of the readings of the module and a function
+// that stops them.
+func (a *Analog) Values() (<-chan firmata.PinEvent, func()) {
+	return a.c.SubscribePin(int(a.Pin))
+}
+
+// NewLight sets up a light sensor, whose readings Lux converts. The
+// sensor is a GL5528 with a 10k resistor.
+func NewLight(c *firmata.Client, p Port) (*Analog, *light.LDR, error) {
+	a, err := newAnalog(c, p)
+	if err != nil {
+		return nil, nil, err
+	}
+	return a, &light.LDR{Pin: int(a.Pin), FixedOhms: 10000, Ohms10Lux: 10000, Gamma: 0.7, Max: 1023}, nil
+}
+
+// NewMoisture sets up a moisture sensor, whose readings the returned
+// soil.Sensor converts. It reads about 0 in dry air and 950 in water.
+func NewMoisture(c *firmata.Client, p Port, threshold float64) (*Analog, *soil.Sensor, error) {
+	a, err := newAnalog(c, p)
+	if err != nil {
+		return nil, nil, err
+	}
+	return a, &soil.Sensor{Pin: int(a.Pin), Dry: 0, Wet: 950, Threshold: threshold}, nil
+}
+
+// NewLoadCell sets up an HX711 load cell amplifier, which needs the
+// HX711_DATA firmware feature.
+func NewLoadCell(c *firmata.Client, p Port) (*hx711.Device, error) {
+	dout, sck, err := p.pins(c)
+	if err != nil {
+		return nil, err
+	}
+	return hx711.New(c, dout, sck, hx711.Gain128)
+}
+
+// NewIRReceiver sets up an infrared receiver, which needs the IR
+// firmware feature.
+func NewIRReceiver(c *firmata.Client, p Port) (<-chan ir.Code, error) {
+	pin, _, err := p.pins(c)
+	if err != nil {
+		return nil, err
+	}
+	return ir.New(c).Receive(pin)
+}
+
+// NewRTC sets up the DS1307 real time clock on the I2C port.
+func NewRTC(c *firmata.Client, p Port) (*ds3231.Device, error) {
+	if err := i2c(c, p); err != nil {
+		return nil, err
+	}
+	return ds3231.NewDS1307(c), nil
+}
+
+// NewTimeOfFlight sets up and initializes the VL53L0X distance sensor on
+// the I2C port.
+func NewTimeOfFlight(c *firmata.Client, p Port) (*vl53l0x.Device, error) {
+	if err := i2c(c, p); err != nil {
+		return nil, err
+	}
+	d := vl53l0x.New(c, vl53l0x.Address)
+	if err := d.Init(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// i2c enables I2C for a module plugged into p.
+func i2c(c *firmata.Client, p Port) error {
+	if p != I2C {
+		return fmt.Errorf("grove: port %v is not an I2C port", p)
+	}
+	return c.I2CConfig(0)
+}
@@ -0,0 +1,143 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+// WithHistory keeps the last n values of every pin so that late
+// subscribers can catch up with SubscribePinHistory and LastValues
+// instead of waiting for the next change of slow inputs.
+func WithHistory(n int) Option {
+	return func(c *Client) {
+		if n > 0 {
+			c.bus.history = &history{size: n, pins: make(map[int]*ring), analog: c.UsedAsAnalog}
+		}
+	}
+}
+
+// history holds the recent values of each pin. It is guarded by the
+// bus lock.
+type history struct {
+	size   int
+	pins   map[int]*ring
+	analog func(pin int) bool // whether a pin is read as analog
+}
+
+// ring is a fixed size buffer of the last values of a pin.
+type ring struct {
+	events []PinEvent
+	next   int
+}
+
+func (r *ring) add(ev PinEvent, size int) {
+	if len(r.events) < size {
+		r.events = append(r.events, ev)
+		return
+	}
+	r.events[r.next] = ev
+	r.next = (r.next + 1) % size
+}
+
+// last returns up to n values, oldest first.
+func (r *ring) last(n int) []PinEvent {
+	ordered := append(append([]PinEvent(nil), r.events[r.next:]...), r.events[:r.next]...)
+	if n < len(ordered) {
+		ordered = ordered[len(ordered)-n:]
+	}
+	return ordered
+}
+
+// record adds the pin values carried by ev. Digital pins are recorded
+// when their level changes or was never seen.
+func (h *history) record(ev Event) {
+	switch e := eventOf(ev).(type) {
+	case AnalogEvent:
+		if e.Pin >= 0 && h.analog(e.Pin) {
+			h.add(PinEvent{Header: e.Header, Pin: e.Pin, Value: e.Value, Analog: true, Scaled: e.Scaled, Unit: e.Unit})
+		}
+	case DigitalEvent:
+		for i := 0; i < 8; i++ {
+			pin := int(e.Port)*8 + i
+			bit := byte(1) << uint(i)
+			if h.analog(pin) || e.Changed&bit == 0 && h.pins[pin] != nil {
+				continue
+			}
+			pe := PinEvent{Header: e.Header, Pin: pin}
+			if e.Value&bit != 0 {
+				pe.Value = 1
+			}
+			h.add(pe)
+		}
+	}
+}
+
+func (h *history) add(ev PinEvent) {
+	r := h.pins[ev.Pin]
+	if r == nil {
+		r = new(ring)
+		h.pins[ev.Pin] = r
+	}
+	r.add(ev, h.size)
+}
+
+// History returns up to n recent values of pin, oldest first. It
+// returns nil unless the client was created with WithHistory.
+func (c *Client) History(pin int, n int) []PinEvent {
+	c.bus.mu.Lock()
+	defer c.bus.mu.Unlock()
+	if c.bus.history == nil || c.bus.history.pins[pin] == nil {
+		return nil
+	}
+	events := c.bus.history.pins[pin].last(n)
+	for i := range events {
+		events[i] = c.label(events[i])
+	}
+	return events
+}
+
+// LastValues returns the last value received for each pin. It returns
+// nil unless the client was created with WithHistory.
+func (c *Client) LastValues() map[int]PinEvent {
+	c.bus.mu.Lock()
+	defer c.bus.mu.Unlock()
+	if c.bus.history == nil {
+		return nil
+	}
+	last := make(map[int]PinEvent, len(c.bus.history.pins))
+	for pin, r := range c.bus.history.pins {
+		last[pin] = c.label(r.last(1)[0])
+	}
+	return last
+}
+
+// SubscribePinHistory is like SubscribePin but first delivers up to n
+// recent values of pin kept by WithHistory. No value is missed or
+// repeated between the history and the live events.
+func (c *Client) SubscribePinHistory(pin int, n int) (<-chan PinEvent, func()) {
+	return c.subscribePin(pin, n)
+}
+
+// replay queues the recent values of pin, passed through label, on ch.
+// It is called with the bus lock held, before ch receives live events.
+func (h *history) replay(pin int, n int, ch chan PinEvent, label func(PinEvent) PinEvent) {
+	if h == nil || h.pins[pin] == nil || n <= 0 {
+		return
+	}
+	for _, ev := range h.pins[pin].last(n) {
+		select {
+		case ch <- label(ev):
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/simulator"
+)
+
+// nextPin returns the next event on ch, failing the test after a second.
+func nextPin(t *testing.T, ch <-chan firmata.PinEvent) firmata.PinEvent {
+	t.Helper()
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("no pin event")
+		return firmata.PinEvent{}
+	}
+}
+
+func values(events []firmata.PinEvent) []int {
+	var v []int
+	for _, ev := range events {
+		v = append(v, ev.Value)
+	}
+	return v
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestHistory(t *testing.T) {
+	b := simulator.New(nil)
+	c, err := firmata.NewClientConn(b, firmata.WithHistory(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	live, stop := c.SubscribePin(2)
+	defer stop()
+	if err := c.SetPinMode(2, firmata.Input); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.EnableDigitalInput(2, true); err != nil {
+		t.Fatal(err)
+	}
+	nextPin(t, live) // the initial level
+	for _, high := range []bool{true, false, true, false} {
+		b.SetDigital(2, high)
+		nextPin(t, live)
+	}
+	if got, want := values(c.History(2, 10)), []int{0, 1, 0}; !equalInts(got, want) {
+		t.Errorf("History(2, 10) = %v; want %v", got, want)
+	}
+	if got := values(c.History(2, 1)); !equalInts(got, []int{0}) {
+		t.Errorf("History(2, 1) = %v; want [0]", got)
+	}
+	if c.History(9, 10) != nil {
+		t.Error("History of a port never reported is not nil")
+	}
+	if last := c.LastValues(); last[2].Value != 0 || last[2].Pin != 2 {
+		t.Errorf("LastValues()[2] = %+v; want pin 2 low", last[2])
+	}
+
+	// A late subscriber first catches up, then gets the live events.
+	ch, stop2 := c.SubscribePinHistory(2, 2)
+	defer stop2()
+	b.SetDigital(2, true)
+	var got []int
+	for i := 0; i < 3; i++ {
+		got = append(got, nextPin(t, ch).Value)
+	}
+	if want := []int{1, 0, 1}; !equalInts(got, want) {
+		t.Errorf("SubscribePinHistory delivered %v; want %v", got, want)
+	}
+}
+
+func TestNoHistory(t *testing.T) {
+	c, err := firmata.NewClientConn(simulator.New(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.History(2, 1) != nil || c.LastValues() != nil {
+		t.Error("history kept without WithHistory")
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// I2C read/write modes of an I2C_REQUEST.
+const (
+	i2cWrite            = 0x00
+	i2cRead             = 0x08
+	i2cReadContinuously = 0x10
+	i2cStopReading      = 0x18
+)
+
+// i2cNoRegister is the register StandardFirmata reports in the replies
+// to reads that didn't specify one.
+const i2cNoRegister = 0xFF
+
+// I2CConfig enables I2C on the board. delay is the time in microseconds
+// between writing the register address and reading the data back, which
+// some devices need; zero keeps the firmware default.
+func (c *Client) I2CConfig(delay int) error {
+	m := wire.SysEx{Command: byte(I2CConfig), Data: wire.IntTo7Bit(delay)[:2]}
+	return c.sendConfig("i2c", m)
+}
+
+// I2C bus clock frequencies in Hz.
+const (
+	I2CStandardMode = 100000
+	I2CFastMode     = 400000
+)
+
+// I2CSettings are the timing parameters of the I2C bus.
+type I2CSettings struct {
+	// Delay is the time between writing the register address and
+	// reading the data back, up to 16ms. Zero keeps the firmware
+	// default.
+	Delay time.Duration
+
+	// Clock is the bus frequency in Hz, as I2CFastMode. Zero keeps the
+	// firmware default, usually I2CStandardMode. Firmware that doesn't
+	// know the clock bytes of I2C_CONFIG, such as StandardFirmata,
+	// ignores it; ExtendedFirmata applies it.
+	Clock int
+}
+
+// I2CConfigure enables I2C on the board with explicit timing.
+func (c *Client) I2CConfigure(s I2CSettings) error {
+	delay := int(s.Delay / time.Microsecond)
+	if delay < 0 || delay > 0x3FFF {
+		return errors.New("i2c delay out of range")
+	}
+	khz := s.Clock / 1000
+	if s.Clock < 0 || khz > 0x3FFF || (s.Clock > 0 && khz == 0) {
+		return errors.New("i2c clock out of range")
+	}
+	data := wire.IntTo7Bit(delay)[:2]
+	if khz > 0 {
+		data = append(data, wire.IntTo7Bit(khz)[:2]...)
+	}
+	return c.sendConfig("i2c", wire.SysEx{Command: byte(I2CConfig), Data: data})
+}
+
+// I2CWrite writes data to the device at the 7-bit address addr.
+func (c *Client) I2CWrite(addr byte, data ...byte) error {
+	payload := append([]byte{addr & 0x7F, i2cWrite}, wire.EncodeBytes(data)...)
+	return c.sendSysEx(I2CRequest, payload...)
+}
+
+// I2CRead reads n bytes starting at register reg of the device at addr.
+// A negative reg reads without writing a register address first. It
+// blocks until the board replies or the read times out.
+func (c *Client) I2CRead(addr byte, reg int, n int) ([]byte, error) {
+	return c.I2CReadContext(context.Background(), addr, reg, n)
+}
+
+// I2CReadContext is like I2CRead but fails when ctx is done.
+func (c *Client) I2CReadContext(ctx context.Context, addr byte, reg int, n int) ([]byte, error) {
+	payload, key := i2cReadRequest(addr, reg, n)
+	v, err := c.query(ctx, key, func() error {
+		return c.sendSysEx(I2CRequest, payload...)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("i2c read from %#x: %v", addr, err)
+	}
+	return v.([]byte), nil
+}
+
+// i2cReadRequest returns the I2C_REQUEST payload of a read and the key
+// of its reply.
+func i2cReadRequest(addr byte, reg int, n int) ([]byte, queryKey) {
+	payload := []byte{addr & 0x7F, i2cRead}
+	if reg >= 0 {
+		payload = append(payload, wire.IntTo7Bit(reg)[:2]...)
+	}
+	payload = append(payload, wire.IntTo7Bit(n)[:2]...)
+	if reg < 0 {
+		reg = i2cNoRegister
+	}
+	return payload, i2cQueryKey(addr, reg)
+}
+
+func i2cQueryKey(addr byte, reg int) queryKey {
+	return queryKey{cmd: I2CReply, id: int(addr)<<16 | reg}
+}
+
+func (c *Client) parseI2CReply(data7bit []byte) {
+	if len(data7bit) < 4 {
+		return
+	}
+	addr := byte(wire.From7Bit(data7bit[0], data7bit[1]))
+	reg := int(wire.From7Bit(data7bit[2], data7bit[3]))
+	p := newPayload()
+	for i := 4; i+1 < len(data7bit); i += 2 {
+		p.buf = append(p.buf, byte(wire.From7Bit(data7bit[i], data7bit[i+1])))
+	}
+
+	c.bus.publish(I2CEvent{Header{c.eventTime()}, addr, reg, p.buf, p})
+	if key := i2cQueryKey(addr, reg); c.pending.waiting(key) {
+		c.pending.resolve(key, append([]byte(nil), p.buf...))
+	}
+	p.release()
+}
+
+// I2CReadContinuously makes the board read n bytes starting at register
+// reg of the device at addr on every sampling interval, see
+// SetAnalogSamplingInterval, and returns a channel receiving the data of
+// each read. A negative reg reads without writing a register address
+// first. Reads are dropped while the channel is full. The channel is
+// closed by StopI2CRead or Close.
+func (c *Client) I2CReadContinuously(addr byte, reg int, n int) (<-chan []byte, error) {
+	payload, _ := i2cReadRequest(addr, reg, n)
+	payload[1] = i2cReadContinuously
+	if reg < 0 {
+		reg = i2cNoRegister
+	}
+	ch := make(chan []byte, subscriptionBuffer)
+	c.bus.add(&subscription{
+		key:    (<-chan []byte)(ch),
+		filter: Filter{Types: []Event{I2CEvent{}}},
+		deliver: func(ev Event) bool {
+			e := ev.(I2CEvent)
+			if e.Address != addr || e.Register != reg {
+				return true
+			}
+			select {
+			case ch <- append([]byte(nil), e.Data...):
+				return true
+			default:
+				return false
+			}
+		},
+		close: func() { close(ch) },
+	})
+	m := wire.SysEx{Command: byte(I2CRequest), Data: payload}
+	if err := c.sendConfig(fmt.Sprintf("i2c-read/%d/%d", addr, reg), m); err != nil {
+		c.bus.remove((<-chan []byte)(ch))
+		return nil, err
+	}
+	c.stateMu.Lock()
+	if c.i2cStreams == nil {
+		c.i2cStreams = make(map[byte][]chan []byte)
+	}
+	c.i2cStreams[addr] = append(c.i2cStreams[addr], ch)
+	c.stateMu.Unlock()
+	return ch, nil
+}
+
+// StopI2CRead stops the continuous reads of the device at addr and
+// closes their channels.
+func (c *Client) StopI2CRead(addr byte) error {
+	c.journal.forget(fmt.Sprintf("i2c-read/%d/", addr))
+	c.stateMu.Lock()
+	streams := c.i2cStreams[addr]
+	delete(c.i2cStreams, addr)
+	c.stateMu.Unlock()
+	for _, ch := range streams {
+		c.bus.remove((<-chan []byte)(ch))
+	}
+	return c.sendSysEx(I2CRequest, addr&0x7F, i2cStopReading)
+}
@@ -0,0 +1,74 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import "fmt"
+
+// Identity is the board a client expects to talk to. Empty fields and
+// a zero version are not checked.
+type Identity struct {
+	// Firmware is the firmware name, as in "StandardFirmata.ino".
+	Firmware     string
+	Major, Minor int
+
+	// Serial is the USB serial number of the board. It is only checked
+	// by NewClient, on systems where it can be read.
+	Serial string
+}
+
+// WithIdentity makes the client fail to connect to a board that is not
+// id, so that a program never drives hardware plugged into the wrong
+// port.
+func WithIdentity(id Identity) Option {
+	return func(c *Client) {
+		c.identity = &id
+	}
+}
+
+// checkIdentity compares the firmware reported during the handshake
+// with the expected identity.
+func (c *Client) checkIdentity() error {
+	id := c.identity
+	if id == nil {
+		return nil
+	}
+	var major, minor int
+	if len(c.firmwareVersion) == 2 {
+		major, minor = c.firmwareVersion[0], c.firmwareVersion[1]
+	}
+	if id.Firmware != "" && id.Firmware != c.firmwareName {
+		return fmt.Errorf("wrong board: firmware is %q, want %q", c.firmwareName, id.Firmware)
+	}
+	if (id.Major != 0 || id.Minor != 0) && (id.Major != major || id.Minor != minor) {
+		return fmt.Errorf("wrong board: firmware version is %d.%d, want %d.%d", major, minor, id.Major, id.Minor)
+	}
+	return nil
+}
+
+// checkSerial compares the USB serial number of the device dev with the
+// expected identity.
+func (c *Client) checkSerial(dev string) error {
+	if c.identity == nil || c.identity.Serial == "" {
+		return nil
+	}
+	serial, err := usbSerial(dev)
+	if err != nil {
+		return fmt.Errorf("cannot verify the serial number of %v: %v", dev, err)
+	}
+	if serial != c.identity.Serial {
+		return fmt.Errorf("wrong board on %v: serial number is %q, want %q", dev, serial, c.identity.Serial)
+	}
+	return nil
+}
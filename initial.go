@@ -0,0 +1,64 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"time"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// initialReportWait is how long EnableDigitalInput waits for the first
+// report of a port before asking for it again.
+const initialReportWait = 250 * time.Millisecond
+
+// awaitInitial flags the next report of port as its initial state. The
+// firmware reports a port when its reporting is enabled; if that report
+// is lost, reporting is enabled again once to get another.
+func (c *Client) awaitInitial(port byte) {
+	c.stateMu.Lock()
+	c.initialPorts[port] = true
+	c.stateMu.Unlock()
+	go func() {
+		select {
+		case <-c.clock.After(initialReportWait):
+		case <-c.done:
+			return
+		}
+		c.stateMu.Lock()
+		pending := c.initialPorts[port] && c.digitalReporting[port]
+		c.stateMu.Unlock()
+		if pending {
+			c.send(wire.DigitalReport{Port: port, Enable: true})
+		}
+	}()
+}
+
+// initialMask returns the pins of port that are inputs or whose mode is
+// unknown, and clears the initial flag of port. It returns 0 if the
+// port doesn't await its initial report. c.stateMu must be held.
+func (c *Client) initialMask(port byte) byte {
+	if !c.initialPorts[port] {
+		return 0
+	}
+	c.initialPorts[port] = false
+	var mask byte
+	for i := 0; i < 8; i++ {
+		if mode, ok := c.modes[port*8+uint8(i)]; !ok || mode == Input {
+			mask |= 1 << uint(i)
+		}
+	}
+	return mask
+}
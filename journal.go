@@ -0,0 +1,116 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// journal keeps the last command of each kind of configuration sent to
+// the board, in the order they were sent, so it can be replayed after
+// the board resets.
+type journal struct {
+	mu      sync.Mutex
+	keys    []string
+	entries map[string]wire.Message
+}
+
+// record stores m under key, replacing and moving after the others any
+// earlier command with the same key.
+func (j *journal) record(key string, m wire.Message) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.entries == nil {
+		j.entries = make(map[string]wire.Message)
+	}
+	if _, ok := j.entries[key]; ok {
+		for i, k := range j.keys {
+			if k == key {
+				j.keys = append(j.keys[:i], j.keys[i+1:]...)
+				break
+			}
+		}
+	}
+	j.keys = append(j.keys, key)
+	j.entries[key] = m
+}
+
+// forget removes the commands whose key starts with prefix.
+func (j *journal) forget(prefix string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	keys := j.keys[:0]
+	for _, k := range j.keys {
+		if strings.HasPrefix(k, prefix) {
+			delete(j.entries, k)
+			continue
+		}
+		keys = append(keys, k)
+	}
+	j.keys = keys
+}
+
+func (j *journal) messages() []wire.Message {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	msgs := make([]wire.Message, 0, len(j.keys))
+	for _, k := range j.keys {
+		msgs = append(msgs, j.entries[k])
+	}
+	return msgs
+}
+
+// sendConfig sends m and records it in the journal under key.
+func (c *Client) sendConfig(key string, m wire.Message) error {
+	if err := c.send(m); err != nil {
+		return err
+	}
+	c.journal.record(key, m)
+	return nil
+}
+
+// Replay sends again the configuration commands issued so far: pin
+// modes, input reporting, sampling interval, I2C, serial and SPI
+// configuration. The client replays them by itself when the board
+// reports that it has been reset.
+func (c *Client) Replay() error {
+	for _, m := range c.journal.messages() {
+		if err := c.send(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayAfterReset replays the configuration after the board was reset
+// or reconnected, unless such a replay is already running.
+func (c *Client) replayAfterReset() {
+	if !c.replaying.CompareAndSwap(false, true) {
+		return
+	}
+	defer c.replaying.Store(false)
+	c.Replay()
+}
+
+// sendFirmwareQuery asks the board for its firmware. Some firmwares also
+// report their version in reply, which must not be taken for a reset.
+func (c *Client) sendFirmwareQuery() error {
+	c.versionQueried.Store(true)
+	c.firmwareQueried.Store(true)
+	return c.sendSysEx(ReportFirmware)
+}
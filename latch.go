@@ -0,0 +1,134 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"sync"
+	"time"
+)
+
+// Pulse is a high pulse of a latched input.
+type Pulse struct {
+	Pin      int
+	Start    time.Time
+	Duration time.Duration
+}
+
+// Latch latches the pulses of a digital input, so pulses shorter than
+// the interval at which an application samples the pin are not missed.
+// It is updated as reports are read from the board, not from a
+// subscription that may drop events.
+type Latch struct {
+	c      *Client
+	pin    int
+	hold   time.Duration
+	pulses chan Pulse
+
+	mu      sync.Mutex
+	high    bool
+	rise    time.Time
+	latched bool
+	count   int // pulses since the last Ack
+}
+
+// LatchInput sets pin to input mode, enables its reporting and latches
+// its pulses. A pulse stays latched, even after the pin went low again,
+// until it is acknowledged with Ack or, if hold is non-zero, until hold
+// has passed since it began.
+func (c *Client) LatchInput(pin uint8, hold time.Duration) (*Latch, error) {
+	if err := c.SetPinMode(pin, Input); err != nil {
+		return nil, err
+	}
+	l := &Latch{
+		c:      c,
+		pin:    int(pin),
+		hold:   hold,
+		pulses: make(chan Pulse, subscriptionBuffer),
+		high:   c.PortSnapshot().High(int(pin)),
+	}
+	c.bus.add(&subscription{
+		key:     l,
+		filter:  Filter{Pins: []int{int(pin)}},
+		deliver: l.deliver,
+		close:   func() { close(l.pulses) },
+	})
+	if err := c.EnableDigitalInput(uint(pin), true); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Latch) deliver(ev Event) bool {
+	e, ok := eventOf(ev).(DigitalEvent)
+	if !ok {
+		return true
+	}
+	bit := byte(1) << uint(l.pin%8)
+	if e.Changed&bit == 0 {
+		return true
+	}
+	high := e.Value&bit != 0
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case e.Initial:
+		// The initial level is not an edge.
+	case high && !l.high:
+		l.rise = e.Time
+		l.latched = true
+		l.count++
+	case !high && l.high && !l.rise.IsZero():
+		select {
+		case l.pulses <- Pulse{Pin: l.pin, Start: l.rise, Duration: e.Time.Sub(l.rise)}:
+		default:
+			return false
+		}
+	}
+	l.high = high
+	return true
+}
+
+// Active reports whether the pin is high or holds a latched pulse.
+func (l *Latch) Active() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.latched && l.hold > 0 && l.c.clock.Now().Sub(l.rise) >= l.hold {
+		l.latched = false
+	}
+	return l.high || l.latched
+}
+
+// Ack clears the latched pulse and returns the number of pulses that
+// began since the previous Ack.
+func (l *Latch) Ack() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := l.count
+	l.latched, l.count = false, 0
+	return n
+}
+
+// Pulses returns a channel of the completed pulses of the pin. Pulses
+// are dropped while the channel is full; Ack still counts them.
+func (l *Latch) Pulses() <-chan Pulse {
+	return l.pulses
+}
+
+// Close stops latching and closes the channel returned by Pulses.
+func (l *Latch) Close() {
+	l.c.bus.remove(l)
+}
@@ -0,0 +1,95 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/simulator"
+)
+
+func TestLatch(t *testing.T) {
+	b := simulator.New(nil)
+	c, err := firmata.NewClientConn(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ch, stop := c.SubscribePin(4)
+	defer stop()
+	l, err := c.LatchInput(4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextPin(t, ch) // reporting is on
+	if l.Active() {
+		t.Fatal("latch active before any pulse")
+	}
+	// A short pulse, over before the application looks at the pin.
+	b.SetDigital(4, true)
+	b.SetDigital(4, false)
+	select {
+	case p := <-l.Pulses():
+		if p.Pin != 4 || p.Duration < 0 {
+			t.Errorf("pulse = %+v", p)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no pulse")
+	}
+	if !l.Active() {
+		t.Error("pulse not latched")
+	}
+	if n := l.Ack(); n != 1 {
+		t.Errorf("Ack() = %d; want 1", n)
+	}
+	if l.Active() {
+		t.Error("latch active after Ack")
+	}
+	l.Close()
+	if _, ok := <-l.Pulses(); ok {
+		t.Error("Pulses not closed by Close")
+	}
+}
+
+func TestLatchHold(t *testing.T) {
+	b := simulator.New(nil)
+	c, err := firmata.NewClientConn(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ch, stop := c.SubscribePin(4)
+	defer stop()
+	l, err := c.LatchInput(4, 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	nextPin(t, ch)
+	b.SetDigital(4, true)
+	b.SetDigital(4, false)
+	<-l.Pulses()
+	time.Sleep(30 * time.Millisecond)
+	if l.Active() {
+		t.Error("pulse still latched after hold")
+	}
+	if n := l.Ack(); n != 1 {
+		t.Errorf("Ack() = %d; want 1", n)
+	}
+}
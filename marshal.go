@@ -0,0 +1,366 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The JSON encodings of events carry a "type" field naming the event,
+// so a stream of mixed events can be decoded by its consumer.
+
+// MarshalText returns the name of the mode, as in "OUTPUT".
+func (m PinMode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText parses a mode name as returned by String.
+func (m *PinMode) UnmarshalText(text []byte) error {
+	name := strings.ToUpper(string(text))
+	for _, mode := range []PinMode{Input, Output, Analog, PWM, Servo, Shift, I2C, SPI, Stepper, Encoder, DAC} {
+		if mode.String() == name {
+			*m = mode
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown pin mode %q", text)
+}
+
+func (k ValueKind) MarshalText() ([]byte, error) {
+	if k == KindAnalog {
+		return []byte("analog"), nil
+	}
+	return []byte("digital"), nil
+}
+
+func (k *ValueKind) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "analog":
+		*k = KindAnalog
+	case "digital":
+		*k = KindDigital
+	default:
+		return fmt.Errorf("unknown value kind %q", text)
+	}
+	return nil
+}
+
+func (v FirmataValue) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+func (v FirmataValue) MarshalJSON() ([]byte, error) {
+	if v.IsAnalog() {
+		return json.Marshal(struct {
+			Kind    ValueKind `json:"kind"`
+			Pin     int       `json:"pin"`
+			Channel byte      `json:"channel"`
+			Value   int       `json:"value"`
+		}{v.Kind, v.Pin, v.Channel, v.Raw})
+	}
+	return json.Marshal(struct {
+		Kind   ValueKind `json:"kind"`
+		Port   byte      `json:"port"`
+		Value  int       `json:"value"`
+		Levels [8]bool   `json:"levels"`
+	}{v.Kind, v.Port, v.Raw, v.Levels})
+}
+
+func (s PinState) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Pin   int     `json:"pin"`
+		Mode  PinMode `json:"mode"`
+		State int     `json:"state"`
+	}{s.Pin, s.Mode, s.State})
+}
+
+func (p PinCapability) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Pin           int             `json:"pin"`
+		Modes         map[PinMode]int `json:"modes"`
+		AnalogChannel int             `json:"analog_channel"`
+	}{p.Pin, p.Modes, p.AnalogChannel})
+}
+
+func (s BoardState) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Modes     map[uint8]PinMode  `json:"modes,omitempty"`
+		Outputs   map[uint8]int      `json:"outputs,omitempty"`
+		Reporting map[uint8]bool     `json:"reporting,omitempty"`
+		Pipelines map[uint8]Pipeline `json:"pipelines,omitempty"`
+	}{s.Modes, s.Outputs, s.Reporting, s.Pipelines})
+}
+
+func (s *BoardState) UnmarshalJSON(b []byte) error {
+	var v struct {
+		Modes     map[uint8]PinMode  `json:"modes"`
+		Outputs   map[uint8]int      `json:"outputs"`
+		Reporting map[uint8]bool     `json:"reporting"`
+		Pipelines map[uint8]Pipeline `json:"pipelines"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*s = BoardState{v.Modes, v.Outputs, v.Reporting, v.Pipelines}
+	return nil
+}
+
+// stageJSON is the encoding of a pipeline stage, as in
+// {"type": "linear", "scale": 0.1, "offset": -50}.
+type stageJSON struct {
+	Type     string  `json:"type"`
+	Offset   float64 `json:"offset,omitempty"`
+	Gain     float64 `json:"gain,omitempty"`
+	Scale    float64 `json:"scale,omitempty"`
+	Window   int     `json:"window,omitempty"`
+	Low      float64 `json:"low,omitempty"`
+	High     float64 `json:"high,omitempty"`
+	OnChange bool    `json:"on_change,omitempty"`
+}
+
+func (p Pipeline) MarshalJSON() ([]byte, error) {
+	stages := make([]stageJSON, len(p.Stages))
+	for i, t := range p.Stages {
+		switch t := t.(type) {
+		case Calibration:
+			stages[i] = stageJSON{Type: "calibration", Offset: t.Offset, Gain: t.Gain}
+		case Linear:
+			stages[i] = stageJSON{Type: "linear", Scale: t.Scale, Offset: t.Offset}
+		case *MovingAverage:
+			stages[i] = stageJSON{Type: "average", Window: t.Window}
+		case *Threshold:
+			stages[i] = stageJSON{Type: "threshold", Low: t.Low, High: t.High, OnChange: t.OnChange}
+		default:
+			return nil, fmt.Errorf("pipeline stage %T has no JSON encoding", t)
+		}
+	}
+	return json.Marshal(struct {
+		Unit   string      `json:"unit,omitempty"`
+		Stages []stageJSON `json:"stages"`
+	}{p.Unit, stages})
+}
+
+func (p *Pipeline) UnmarshalJSON(b []byte) error {
+	var v struct {
+		Unit   string      `json:"unit"`
+		Stages []stageJSON `json:"stages"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	out := Pipeline{Unit: v.Unit}
+	for _, st := range v.Stages {
+		var t Transform
+		switch st.Type {
+		case "calibration":
+			t = Calibration{Offset: st.Offset, Gain: st.Gain}
+		case "linear":
+			t = Linear{Scale: st.Scale, Offset: st.Offset}
+		case "average":
+			t = NewMovingAverage(st.Window)
+		case "threshold":
+			t = NewThreshold(st.Low, st.High, st.OnChange)
+		default:
+			return fmt.Errorf("unknown pipeline stage %q", st.Type)
+		}
+		out.Stages = append(out.Stages, t)
+	}
+	*p = out
+	return nil
+}
+
+func (e DigitalEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type    string    `json:"type"`
+		Time    time.Time `json:"time"`
+		Port    byte      `json:"port"`
+		Value   byte      `json:"value"`
+		Changed byte      `json:"changed"`
+		Initial bool      `json:"initial,omitempty"`
+	}{"digital", e.Time, e.Port, e.Value, e.Changed, e.Initial})
+}
+
+func (e AnalogEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type    string    `json:"type"`
+		Time    time.Time `json:"time"`
+		Pin     int       `json:"pin"`
+		Channel byte      `json:"channel"`
+		Value   int       `json:"value"`
+		Scaled  float64   `json:"scaled"`
+		Unit    string    `json:"unit,omitempty"`
+	}{"analog", e.Time, e.Pin, e.Channel, e.Value, e.Scaled, e.Unit})
+}
+
+func (e I2CEvent) MarshalJSON() ([]byte, error) {
+	// Bytes are written as numbers rather than base64 to stay readable.
+	data := make([]int, len(e.Data))
+	for i, b := range e.Data {
+		data[i] = int(b)
+	}
+	return json.Marshal(struct {
+		Type     string    `json:"type"`
+		Time     time.Time `json:"time"`
+		Address  byte      `json:"address"`
+		Register int       `json:"register"`
+		Data     []int     `json:"data"`
+	}{"i2c", e.Time, e.Address, e.Register, data})
+}
+
+func (e SerialEvent) MarshalJSON() ([]byte, error) {
+	data := make([]int, len(e.Data))
+	for i, b := range e.Data {
+		data[i] = int(b)
+	}
+	return json.Marshal(struct {
+		Type string     `json:"type"`
+		Time time.Time  `json:"time"`
+		Port SerialPort `json:"port"`
+		Data []int      `json:"data"`
+	}{"serial", e.Time, e.Port, data})
+}
+
+func (e ErrorEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type  string    `json:"type"`
+		Time  time.Time `json:"time"`
+		Error string    `json:"error"`
+	}{"error", e.Time, errString(e.Err)})
+}
+
+func (e PinEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type   string    `json:"type"`
+		Time   time.Time `json:"time"`
+		Pin    int       `json:"pin"`
+		Value  int       `json:"value"`
+		Analog bool      `json:"analog"`
+		Scaled float64   `json:"scaled"`
+		Unit   string    `json:"unit,omitempty"`
+	}{"pin", e.Time, e.Pin, e.Value, e.Analog, e.Scaled, e.Unit})
+}
+
+func (e SlopeEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type  string    `json:"type"`
+		Time  time.Time `json:"time"`
+		Pin   int       `json:"pin"`
+		Value int       `json:"value"`
+		Slope float64   `json:"slope"`
+	}{"slope", e.Time, e.Pin, e.Value, e.Slope})
+}
+
+func (e ReconnectEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type    string        `json:"type"`
+		Time    time.Time     `json:"time"`
+		Attempt int           `json:"attempt"`
+		Delay   time.Duration `json:"delay"`
+		Error   string        `json:"error,omitempty"`
+		GaveUp  bool          `json:"gave_up,omitempty"`
+	}{"reconnect", e.Time, e.Attempt, e.Delay, errString(e.Err), e.GaveUp})
+}
+
+func (e FailoverEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type  string    `json:"type"`
+		Time  time.Time `json:"time"`
+		Cause string    `json:"cause,omitempty"`
+		Error string    `json:"error,omitempty"`
+	}{"failover", e.Time, errString(e.Cause), errString(e.Err)})
+}
+
+func (e StepperEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type   string    `json:"type"`
+		Time   time.Time `json:"time"`
+		Device int       `json:"device"`
+	}{"stepper", e.Time, e.Device})
+}
+
+func (e AccelStepperEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type     string    `json:"type"`
+		Time     time.Time `json:"time"`
+		Device   int       `json:"device"`
+		Position int       `json:"position"`
+	}{"accelstepper", e.Time, e.Device, e.Position})
+}
+
+func (e MultiStepperEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type  string    `json:"type"`
+		Time  time.Time `json:"time"`
+		Group int       `json:"group"`
+	}{"multistepper", e.Time, e.Group})
+}
+
+func (k DiagnosticKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (e DiagnosticEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type   string         `json:"type"`
+		Time   time.Time      `json:"time"`
+		Kind   DiagnosticKind `json:"kind"`
+		Detail string         `json:"detail"`
+	}{"diagnostic", e.Time, e.Kind, e.Detail})
+}
+
+func (e EncoderEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type     string    `json:"type"`
+		Time     time.Time `json:"time"`
+		Encoder  int       `json:"encoder"`
+		Position int       `json:"position"`
+	}{"encoder", e.Time, e.Encoder, e.Position})
+}
+
+func (e TaskErrorEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type     string        `json:"type"`
+		Time     time.Time     `json:"time"`
+		Task     byte          `json:"task"`
+		At       time.Duration `json:"at"`
+		Position int           `json:"position"`
+	}{"task_error", e.Time, e.Task.ID, e.Task.Time, e.Task.Position})
+}
+
+func (e StringEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type string    `json:"type"`
+		Time time.Time `json:"time"`
+		Text string    `json:"text"`
+	}{"string", e.Time, e.Text})
+}
+
+func (e WarningEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type    string    `json:"type"`
+		Time    time.Time `json:"time"`
+		Message string    `json:"message"`
+	}{"warning", e.Time, e.Message})
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
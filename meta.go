@@ -0,0 +1,80 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+// PinMeta describes what a pin measures or drives so that its values
+// can be reported in engineering units.
+type PinMeta struct {
+	Unit        string
+	Description string
+
+	// Min and Max are the range of the converted value. Both zero means
+	// the range is unknown.
+	Min, Max float64
+
+	// Transform converts a raw value, such as an analog reading, into
+	// Unit. A nil Transform keeps the raw value.
+	Transform func(raw int) float64
+}
+
+// Convert returns raw in the unit of the pin.
+func (m PinMeta) Convert(raw int) float64 {
+	if m.Transform == nil {
+		return float64(raw)
+	}
+	return m.Transform(raw)
+}
+
+// SetPinMeta attaches metadata to pin. PinEvents of the pin then carry
+// the converted value and unit.
+func (c *Client) SetPinMeta(pin int, m PinMeta) {
+	c.metaMu.Lock()
+	defer c.metaMu.Unlock()
+	if c.meta == nil {
+		c.meta = make(map[int]PinMeta)
+	}
+	c.meta[pin] = m
+}
+
+// PinMeta returns the metadata attached to pin.
+func (c *Client) PinMeta(pin int) (PinMeta, bool) {
+	c.metaMu.RLock()
+	defer c.metaMu.RUnlock()
+	m, ok := c.meta[pin]
+	return m, ok
+}
+
+// Metadata returns the metadata of all the pins that have some.
+func (c *Client) Metadata() map[int]PinMeta {
+	c.metaMu.RLock()
+	defer c.metaMu.RUnlock()
+	all := make(map[int]PinMeta, len(c.meta))
+	for pin, m := range c.meta {
+		all[pin] = m
+	}
+	return all
+}
+
+// label fills the converted value and unit of ev from the metadata of
+// its pin. Analog pins with a pipeline keep the result of the pipeline.
+func (c *Client) label(ev PinEvent) PinEvent {
+	if _, ok := c.Pipeline(ev.Pin); ok && ev.Analog {
+		return ev
+	}
+	m, _ := c.PinMeta(ev.Pin)
+	ev.Scaled = m.Convert(ev.Value)
+	ev.Unit = m.Unit
+	return ev
+}
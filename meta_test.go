@@ -0,0 +1,56 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata_test
+
+import (
+	"testing"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/simulator"
+)
+
+func TestPinMeta(t *testing.T) {
+	b := simulator.New(nil)
+	c, err := firmata.NewClientConn(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetPinMeta(14, firmata.PinMeta{
+		Unit:      "°C",
+		Transform: func(raw int) float64 { return float64(raw) / 10 },
+	})
+	if m, ok := c.PinMeta(14); !ok || m.Unit != "°C" {
+		t.Fatalf("PinMeta(14) = %+v, %v", m, ok)
+	}
+	if _, ok := c.PinMeta(15); ok {
+		t.Error("PinMeta of a pin without metadata")
+	}
+	if n := len(c.Metadata()); n != 1 {
+		t.Errorf("Metadata() has %d pins; want 1", n)
+	}
+
+	events, stop := c.SubscribePin(14)
+	defer stop()
+	if err := c.EnableAnalogInput(14, true); err != nil {
+		t.Fatal(err)
+	}
+	nextPin(t, events)
+	b.SetAnalog(14, 215)
+	if ev := nextPin(t, events); ev.Scaled != 21.5 || ev.Unit != "°C" {
+		t.Errorf("event = %+v; want 21.5 °C", ev)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics exports the counters of a client, as returned by
+// Client.Stats, to expvar and in the Prometheus text format.
+package metrics
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/rakyll/go-firmata"
+)
+
+// PublishExpvar publishes the stats of c as the expvar name. Like
+// expvar.Publish, it panics if name is already in use.
+func PublishExpvar(name string, c *firmata.Client) {
+	expvar.Publish(name, expvar.Func(func() interface{} { return c.Stats() }))
+}
+
+// Handler returns a handler serving the stats of c in the Prometheus
+// text exposition format, to be scraped by a Prometheus server.
+func Handler(c *firmata.Client) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		Write(w, c.Stats())
+	})
+}
+
+// Write writes s in the Prometheus text exposition format.
+func Write(w io.Writer, s firmata.Stats) error {
+	ew := &errWriter{w: w}
+	ew.counter("firmata_messages_received_total", "Messages received from the board.", s.Messages)
+	ew.counter("firmata_bytes_sent_total", "Bytes written to the board.", s.BytesSent)
+	ew.counter("firmata_bytes_received_total", "Bytes read from the board.", s.BytesReceived)
+	ew.counter("firmata_bytes_skipped_total", "Received bytes that were not part of a valid message.", s.Skipped)
+	ew.counter("firmata_sysex_overflows_total", "SysEx messages discarded for being too long.", s.SysExOverflows)
+	ew.counter("firmata_sysex_truncated_total", "SysEx messages that lost their end.", s.SysExTruncated)
+	ew.counter("firmata_events_dropped_total", "Events not delivered to slow subscribers.", s.Dropped)
+	ew.counter("firmata_reconnects_total", "Successful reconnections to the board.", s.Reconnects)
+	ew.counter("firmata_failovers_total", "Switches to the standby connection.", s.Failovers)
+	ew.byType("firmata_frames_sent_total", "Messages sent to the board by type.", s.FramesSent)
+	ew.byType("firmata_frames_received_total", "Messages received from the board by type.", s.FramesReceived)
+	ew.gauge("firmata_pending_queries", "Queries waiting for a reply.", s.PendingQueries)
+	ew.gauge("firmata_callback_backlog", "Event callbacks waiting for a worker.", s.CallbackBacklog)
+	return ew.err
+}
+
+// errWriter keeps the first write error.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (w *errWriter) printf(format string, args ...interface{}) {
+	if w.err == nil {
+		_, w.err = fmt.Fprintf(w.w, format, args...)
+	}
+}
+
+func (w *errWriter) counter(name, help string, v uint64) {
+	w.printf("# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+}
+
+func (w *errWriter) gauge(name, help string, v int) {
+	w.printf("# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, v)
+}
+
+func (w *errWriter) byType(name, help string, counts map[string]uint64) {
+	w.printf("# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	types := make([]string, 0, len(counts))
+	for t := range counts {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		w.printf("%s{type=%q} %d\n", name, t, counts[t])
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mobile is a simplified interface to a Firmata board that can
+// be bound with gomobile for use from Android and iOS apps, typically
+// with boards attached over Bluetooth. Its exported signatures only use
+// types gomobile supports: no channels, maps or unsigned integers.
+package mobile
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/rakyll/go-firmata"
+)
+
+// Pin modes for SetPinMode.
+const (
+	ModeInput  = int(firmata.Input)
+	ModeOutput = int(firmata.Output)
+	ModeAnalog = int(firmata.Analog)
+	ModePWM    = int(firmata.PWM)
+	ModeServo  = int(firmata.Servo)
+)
+
+// Conn is the link to the board, implemented by the app, for instance
+// over a Bluetooth serial socket.
+type Conn interface {
+	// Read blocks until data is available and returns at most n bytes.
+	Read(n int) ([]byte, error)
+	Write(data []byte) error
+	Close() error
+}
+
+// Listener receives the reports of the board. Its methods are called
+// from a single goroutine.
+type Listener interface {
+	OnDigital(pin int, high bool)
+	OnAnalog(pin int, value int)
+	OnError(message string)
+}
+
+// conn adapts a Conn to an io.ReadWriteCloser.
+type conn struct {
+	Conn
+}
+
+func (c conn) Read(p []byte) (int, error) {
+	data, err := c.Conn.Read(len(p))
+	n := copy(p, data)
+	if err == nil && n == 0 {
+		// Readers must not return zero bytes without an error.
+		return 0, io.ErrNoProgress
+	}
+	return n, err
+}
+
+func (c conn) Write(p []byte) (int, error) {
+	if err := c.Conn.Write(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Board is a connected board.
+type Board struct {
+	c *firmata.Client
+}
+
+// Connect performs the Firmata handshake over conn and returns the
+// connected board.
+func Connect(c Conn) (*Board, error) {
+	client, err := firmata.NewClientConn(conn{c})
+	if err != nil {
+		return nil, err
+	}
+	return &Board{c: client}, nil
+}
+
+// checkPin reports an error if pin cannot be addressed, instead of
+// letting the conversion to the unsigned types of the client wrap it.
+func checkPin(pin int) error {
+	if pin < 0 || pin > 127 {
+		return fmt.Errorf("mobile: invalid pin number: %d", pin)
+	}
+	return nil
+}
+
+// SetPinMode sets the mode of pin to one of the Mode constants.
+func (b *Board) SetPinMode(pin, mode int) error {
+	if err := checkPin(pin); err != nil {
+		return err
+	}
+	if mode < 0 || mode > 0x7F {
+		return fmt.Errorf("mobile: invalid pin mode: %d", mode)
+	}
+	return b.c.SetPinMode(uint8(pin), firmata.PinMode(mode))
+}
+
+// DigitalWrite sets the level of a digital output.
+func (b *Board) DigitalWrite(pin int, high bool) error {
+	if err := checkPin(pin); err != nil {
+		return err
+	}
+	return b.c.DigitalWrite(uint8(pin), high)
+}
+
+// AnalogWrite writes value to a PWM or servo pin. Values that are
+// negative or exceed the resolution of the pin are rejected.
+func (b *Board) AnalogWrite(pin, value int) error {
+	if err := checkPin(pin); err != nil {
+		return err
+	}
+	return b.c.AnalogWriteValue(uint8(pin), value)
+}
+
+// EnableDigitalInput turns the reporting of the port of pin on or off.
+func (b *Board) EnableDigitalInput(pin int, enable bool) error {
+	if err := checkPin(pin); err != nil {
+		return err
+	}
+	return b.c.EnableDigitalInput(uint(pin), enable)
+}
+
+// EnableAnalogInput turns the reporting of an analog pin on or off.
+func (b *Board) EnableAnalogInput(pin int, enable bool) error {
+	if err := checkPin(pin); err != nil {
+		return err
+	}
+	return b.c.EnableAnalogInput(uint(pin), enable)
+}
+
+// SetListener starts delivering the reports of the board to l until the
+// board is closed. Digital pins are reported when they change.
+func (b *Board) SetListener(l Listener) {
+	events := firmata.Subscribe[firmata.Event](b.c, firmata.Filter{})
+	go func() {
+		for ev := range events {
+			switch e := ev.(type) {
+			case firmata.DigitalEvent:
+				for _, pin := range e.ChangedPins() {
+					l.OnDigital(pin, e.High(pin))
+				}
+			case firmata.AnalogEvent:
+				l.OnAnalog(e.Pin, e.Value)
+			case firmata.ErrorEvent:
+				l.OnError(e.Err.Error())
+			}
+		}
+	}()
+}
+
+// Close closes the connection to the board.
+func (b *Board) Close() error {
+	return b.c.Close()
+}
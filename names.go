@@ -0,0 +1,56 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PinByName returns the pin number of the pin labeled name on the
+// board: "A3" for the pin of analog input 3, or "D13" and "13" for
+// digital pin 13. Analog pins are resolved with the analog mapping the
+// board reports, so the same name works on boards with different
+// numbers of digital pins.
+func (c *Client) PinByName(name string) (uint8, error) {
+	s := strings.ToUpper(strings.TrimSpace(name))
+	analog := strings.HasPrefix(s, "A")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "A"), "D")
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid pin name %q", name)
+	}
+	if analog {
+		pin, ok := c.board().pins[byte(n)]
+		if !ok || n > 127 {
+			return 0, fmt.Errorf("no analog pin %q", name)
+		}
+		return uint8(pin), nil
+	}
+	if err := c.checkPin(n); err != nil {
+		return 0, err
+	}
+	return uint8(n), nil
+}
+
+// PinName returns the label of pin on the board, "A3" for the pin of
+// analog input 3 and "D13" for other pins.
+func (c *Client) PinName(pin uint8) string {
+	if ch, ok := c.board().channels[int(pin)]; ok {
+		return fmt.Sprintf("A%d", ch)
+	}
+	return fmt.Sprintf("D%d", pin)
+}
@@ -0,0 +1,140 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// pingTimeout bounds the echo of a Ping.
+const pingTimeout = 50 * time.Millisecond
+
+// SpeedOfSound is the speed of sound in air at 20°C in meters per second.
+const SpeedOfSound = 343.0
+
+// EchoDistance converts the round trip time of an ultrasonic echo to a
+// distance in meters.
+func EchoDistance(d time.Duration) float64 {
+	return d.Seconds() * SpeedOfSound / 2
+}
+
+// Ping triggers an ultrasonic sensor such as a HC-SR04 or a Ping))) and
+// returns the length of its echo pulse. Single pin sensors use the same
+// trigger and echo pin. The pulse is timed by firmware with PulseIn
+// support; otherwise, or for sensors with separate pins, it fails
+// unless the client emulates it, see WithEmulation. Emulated pings are
+// timed from the reports of the echo pin, so they are only accurate to
+// a few milliseconds.
+func (c *Client) Ping(ctx context.Context, trigger, echo uint8) (time.Duration, error) {
+	if err := c.checkPin(int(trigger)); err != nil {
+		return 0, err
+	}
+	if err := c.checkPin(int(echo)); err != nil {
+		return 0, err
+	}
+	if trigger == echo && !c.lacks("ping") {
+		d, err := c.pulseIn(ctx, trigger)
+		if err == nil || !errors.Is(err, ErrTimeout) || !c.emulation.enabled {
+			return d, err
+		}
+		c.setLacks("ping")
+	}
+	if !c.emulation.enabled {
+		return 0, errors.New("firmata: ping needs PulseIn firmware or emulation")
+	}
+	c.warnEmulated("ping")
+	return c.emulatePing(ctx, trigger, echo)
+}
+
+// pulseIn asks the firmware to send a 10µs high pulse on pin and time
+// the high pulse that follows. Durations are sent as 32-bit big-endian
+// values, each byte split into 7-bit halves.
+func (c *Client) pulseIn(ctx context.Context, pin uint8) (time.Duration, error) {
+	var b [8]byte
+	binary.BigEndian.PutUint32(b[:4], 10)
+	binary.BigEndian.PutUint32(b[4:], uint32(pingTimeout/time.Microsecond))
+	data := append([]byte{pin & 0x7F, 1}, wire.EncodeBytes(b[:])...)
+	v, err := c.query(ctx, queryKey{cmd: PulseIn, id: int(pin)}, func() error {
+		return c.sendSysEx(PulseIn, data...)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.(time.Duration), nil
+}
+
+// parsePulseIn decodes a pin and a pulse length in microseconds.
+func (c *Client) parsePulseIn(data []byte) {
+	if len(data) < 9 {
+		return
+	}
+	us := binary.BigEndian.Uint32(wire.DecodeBytes(data[1:9]))
+	c.pending.resolve(queryKey{cmd: PulseIn, id: int(data[0])}, time.Duration(us)*time.Microsecond)
+}
+
+// emulatePing pulses trigger and times the echo from the digital
+// reports of echo.
+func (c *Client) emulatePing(ctx context.Context, trigger, echo uint8) (time.Duration, error) {
+	if err := c.SetPinMode(trigger, Output); err != nil {
+		return 0, err
+	}
+	if trigger != echo {
+		if err := c.SetPinMode(echo, Input); err != nil {
+			return 0, err
+		}
+	}
+	if err := c.EnableDigitalInput(uint(echo), true); err != nil {
+		return 0, err
+	}
+	events, stop := c.SubscribePin(int(echo))
+	defer stop()
+
+	if err := c.digitalWrite(trigger, true); err != nil {
+		return 0, err
+	}
+	if err := c.digitalWrite(trigger, false); err != nil {
+		return 0, err
+	}
+	if trigger == echo {
+		if err := c.SetPinMode(echo, Input); err != nil {
+			return 0, err
+		}
+	}
+
+	timeout := c.clock.After(pingTimeout + defaultQueryTimeout)
+	var rise time.Time
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return 0, ErrTimeout
+			}
+			if ev.Value == 1 {
+				rise = ev.Time
+			} else if !rise.IsZero() {
+				return ev.Time.Sub(rise), nil
+			}
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-timeout:
+			return 0, ErrTimeout
+		}
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Transform is a stage of the pipeline of a pin. It returns the value
+// to pass to the next stage, or false to drop the sample.
+type Transform interface {
+	Transform(v float64) (float64, bool)
+}
+
+// TransformFunc adapts a function to a Transform.
+type TransformFunc func(v float64) (float64, bool)
+
+func (f TransformFunc) Transform(v float64) (float64, bool) { return f(v) }
+
+// Pipeline is an ordered list of transforms applied to the samples of
+// an analog pin, typically calibration, filtering, unit conversion and
+// thresholding in that order.
+type Pipeline struct {
+	Stages []Transform
+
+	// Unit is the unit of the value the last stage returns.
+	Unit string
+}
+
+// run passes v through the stages of p.
+func (p Pipeline) run(v float64) (float64, bool) {
+	for _, s := range p.Stages {
+		var ok bool
+		if v, ok = s.Transform(v); !ok {
+			return 0, false
+		}
+	}
+	return v, true
+}
+
+// equal reports whether p and q have the same stages, so reapplying a
+// pipeline keeps the state of its filters.
+func (p Pipeline) equal(q Pipeline) bool {
+	if p.Unit != q.Unit || len(p.Stages) != len(q.Stages) {
+		return false
+	}
+	for i := range p.Stages {
+		a, b := reflect.ValueOf(p.Stages[i]), reflect.ValueOf(q.Stages[i])
+		if a.Type() != b.Type() {
+			return false
+		}
+		if a.Kind() == reflect.Func {
+			if a.Pointer() != b.Pointer() {
+				return false
+			}
+		} else if !a.Comparable() || !a.Equal(b) {
+			return false
+		}
+	}
+	return true
+}
+
+// pipelines holds the pipelines of the pins. Stages run one sample at
+// a time, so stateful stages need no locking of their own.
+type pipelines struct {
+	mu    sync.Mutex
+	byPin map[int]Pipeline
+}
+
+// SetPipeline makes the samples of the analog pin go through p before
+// the AnalogEvents are delivered to any subscriber. The result is in
+// the Scaled and Unit fields of the events; samples dropped by a stage
+// are not delivered at all. A Pipeline without stages removes it.
+func (c *Client) SetPipeline(pin int, p Pipeline) {
+	c.pipes.mu.Lock()
+	defer c.pipes.mu.Unlock()
+	if len(p.Stages) == 0 {
+		delete(c.pipes.byPin, pin)
+		return
+	}
+	if c.pipes.byPin == nil {
+		c.pipes.byPin = make(map[int]Pipeline)
+	}
+	c.pipes.byPin[pin] = p
+}
+
+// Pipeline returns the pipeline of pin.
+func (c *Client) Pipeline(pin int) (Pipeline, bool) {
+	c.pipes.mu.Lock()
+	defer c.pipes.mu.Unlock()
+	p, ok := c.pipes.byPin[pin]
+	return p, ok
+}
+
+// transform runs the pipeline of the pin of ev. It reports false if
+// the sample was dropped.
+func (c *Client) transform(ev *AnalogEvent) bool {
+	ev.Scaled = float64(ev.Value)
+	c.pipes.mu.Lock()
+	defer c.pipes.mu.Unlock()
+	p, ok := c.pipes.byPin[ev.Pin]
+	if !ok {
+		return true
+	}
+	v, keep := p.run(ev.Scaled)
+	ev.Scaled, ev.Unit = v, p.Unit
+	return keep
+}
+
+// Calibration corrects a raw value as (v + Offset) * Gain. A zero Gain
+// is taken as 1.
+type Calibration struct {
+	Offset, Gain float64
+}
+
+func (t Calibration) Transform(v float64) (float64, bool) {
+	g := t.Gain
+	if g == 0 {
+		g = 1
+	}
+	return (v + t.Offset) * g, true
+}
+
+// Linear converts a value to another unit as v*Scale + Offset.
+type Linear struct {
+	Scale, Offset float64
+}
+
+func (t Linear) Transform(v float64) (float64, bool) {
+	return v*t.Scale + t.Offset, true
+}
+
+// MovingAverage smooths values over the last Window samples.
+type MovingAverage struct {
+	Window int
+
+	buf []float64
+	sum float64
+	i   int
+}
+
+// NewMovingAverage returns a moving average over n samples.
+func NewMovingAverage(n int) *MovingAverage {
+	return &MovingAverage{Window: n}
+}
+
+func (t *MovingAverage) Transform(v float64) (float64, bool) {
+	if t.Window <= 1 {
+		return v, true
+	}
+	if len(t.buf) < t.Window {
+		t.buf = append(t.buf, v)
+	} else {
+		t.sum -= t.buf[t.i]
+		t.buf[t.i] = v
+		t.i = (t.i + 1) % t.Window
+	}
+	t.sum += v
+	return t.sum / float64(len(t.buf)), true
+}
+
+// Threshold turns a value into 1 once it rises above High and back into
+// 0 once it falls below Low. With OnChange set, only the samples that
+// flip the output are passed on.
+type Threshold struct {
+	Low, High float64
+	OnChange  bool
+
+	on, seen bool
+}
+
+// NewThreshold returns a threshold with hysteresis between low and high.
+func NewThreshold(low, high float64, onChange bool) *Threshold {
+	return &Threshold{Low: low, High: high, OnChange: onChange}
+}
+
+func (t *Threshold) Transform(v float64) (float64, bool) {
+	prev, first := t.on, !t.seen
+	t.seen = true
+	switch {
+	case v > t.High:
+		t.on = true
+	case v < t.Low:
+		t.on = false
+	}
+	if t.OnChange && !first && t.on == prev {
+		return 0, false
+	}
+	if t.on {
+		return 1, true
+	}
+	return 0, true
+}
@@ -0,0 +1,121 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/simulator"
+)
+
+// nextAnalog returns the next event on ch, failing the test after a
+// second.
+func nextAnalog(t *testing.T, ch <-chan firmata.AnalogEvent) firmata.AnalogEvent {
+	t.Helper()
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("no analog event")
+		return firmata.AnalogEvent{}
+	}
+}
+
+func TestTransforms(t *testing.T) {
+	tests := []struct {
+		name string
+		tr   firmata.Transform
+		in   []float64
+		want []float64 // -1 for a dropped sample
+	}{
+		{"calibration", firmata.Calibration{Offset: -10, Gain: 2}, []float64{10, 20}, []float64{0, 20}},
+		{"zero gain", firmata.Calibration{Offset: 1}, []float64{1}, []float64{2}},
+		{"linear", firmata.Linear{Scale: 0.5, Offset: -1}, []float64{4}, []float64{1}},
+		{"moving average", firmata.NewMovingAverage(2), []float64{2, 4, 8}, []float64{2, 3, 6}},
+		{"threshold", firmata.NewThreshold(10, 20, false), []float64{15, 25, 15, 5}, []float64{0, 1, 1, 0}},
+		{"threshold on change", firmata.NewThreshold(10, 20, true), []float64{15, 25, 15, 5, 4}, []float64{0, 1, -1, 0, -1}},
+	}
+	for _, tt := range tests {
+		for i, v := range tt.in {
+			got, ok := tt.tr.Transform(v)
+			if !ok {
+				got = -1
+			}
+			if got != tt.want[i] {
+				t.Errorf("%s: sample %d = %v; want %v", tt.name, i, got, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	b := simulator.New(nil)
+	c, err := firmata.NewClientConn(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	dropOdd := firmata.TransformFunc(func(v float64) (float64, bool) { return v, int(v)%2 == 0 })
+	c.SetPipeline(14, firmata.Pipeline{
+		Stages: []firmata.Transform{dropOdd, firmata.Linear{Scale: 0.1}},
+		Unit:   "V",
+	})
+	events := firmata.Subscribe[firmata.AnalogEvent](c, firmata.Filter{Pins: []int{14}})
+	if err := c.EnableAnalogInput(14, true); err != nil {
+		t.Fatal(err)
+	}
+	nextAnalog(t, events) // the first sample
+	b.SetAnalog(14, 101)
+	b.SetAnalog(14, 500)
+	ev := nextAnalog(t, events)
+	if ev.Value != 500 || ev.Scaled != 50 || ev.Unit != "V" {
+		t.Errorf("event = %+v; want 500 scaled to 50 V", ev)
+	}
+
+	c.SetPipeline(14, firmata.Pipeline{})
+	if _, ok := c.Pipeline(14); ok {
+		t.Error("empty pipeline not removed")
+	}
+	b.SetAnalog(14, 7)
+	if ev := nextAnalog(t, events); ev.Scaled != 7 || ev.Unit != "" {
+		t.Errorf("event without a pipeline = %+v; want 7", ev)
+	}
+}
+
+func TestFilterMinChange(t *testing.T) {
+	b := simulator.New(nil)
+	c, err := firmata.NewClientConn(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	events := firmata.Subscribe[firmata.AnalogEvent](c, firmata.Filter{Pins: []int{15}, MinChange: 5})
+	if err := c.EnableAnalogInput(15, true); err != nil {
+		t.Fatal(err)
+	}
+	nextAnalog(t, events)
+	for _, v := range []int{100, 102, 98, 110} {
+		b.SetAnalog(15, v)
+	}
+	for _, want := range []int{100, 110} {
+		if ev := nextAnalog(t, events); ev.Value != want {
+			t.Errorf("value = %d; want %d", ev.Value, want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+// Ports holds the pin levels of the digital ports of a board. Bit n of
+// Ports[p] is pin p*8+n.
+type Ports [16]byte
+
+// High reports whether pin is high.
+func (p Ports) High(pin int) bool {
+	if pin < 0 || pin >= len(p)*8 {
+		return false
+	}
+	return p[pin/8]&(1<<uint(pin%8)) != 0
+}
+
+// PinChange is a pin whose level changed.
+type PinChange struct {
+	Pin  int
+	High bool // the new level
+}
+
+// DiffPorts returns the pins whose level differs between a and b, in pin
+// order, with their level in b.
+func DiffPorts(a, b Ports) []PinChange {
+	var changes []PinChange
+	for port := range a {
+		diff := a[port] ^ b[port]
+		for i := 0; diff != 0; i++ {
+			if diff&1 != 0 {
+				pin := port*8 + i
+				changes = append(changes, PinChange{pin, b.High(pin)})
+			}
+			diff >>= 1
+		}
+	}
+	return changes
+}
+
+// PortSnapshot returns the pin levels last reported by the board.
+func (c *Client) PortSnapshot() Ports {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	return c.digitalInputState
+}
+
+// ChangedPins returns the pins of the port whose level changed since
+// the previous report.
+func (e DigitalEvent) ChangedPins() []int {
+	var pins []int
+	for i := 0; i < 8; i++ {
+		if e.Changed&(1<<uint(i)) != 0 {
+			pins = append(pins, int(e.Port)*8+i)
+		}
+	}
+	return pins
+}
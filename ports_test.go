@@ -0,0 +1,46 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rakyll/go-firmata"
+)
+
+func TestDiffPorts(t *testing.T) {
+	var a, b firmata.Ports
+	a[0] = 0x05 // pins 0 and 2
+	b[0] = 0x06 // pins 1 and 2
+	b[2] = 0x80 // pin 23
+	want := []firmata.PinChange{{0, false}, {1, true}, {23, true}}
+	if got := firmata.DiffPorts(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffPorts = %v; want %v", got, want)
+	}
+	if got := firmata.DiffPorts(a, a); got != nil {
+		t.Errorf("DiffPorts of equal ports = %v; want nil", got)
+	}
+	if !b.High(23) || b.High(22) || b.High(-1) || b.High(128) {
+		t.Error("High reports the wrong levels")
+	}
+}
+
+func TestChangedPins(t *testing.T) {
+	e := firmata.DigitalEvent{Port: 1, Changed: 0x81}
+	if got := e.ChangedPins(); !reflect.DeepEqual(got, []int{8, 15}) {
+		t.Errorf("ChangedPins() = %v; want [8 15]", got)
+	}
+}
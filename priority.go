@@ -0,0 +1,97 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"sync"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// Priority is the class of a write to the board. When writes contend
+// for the connection, higher classes go first.
+type Priority int
+
+const (
+	// PriorityBulk is for large, delay tolerant traffic such as LED
+	// strip frames.
+	PriorityBulk Priority = iota
+
+	// PriorityNormal is the class of most commands.
+	PriorityNormal
+
+	// PriorityCritical is for safety related writes: those to pins
+	// marked critical or given a safe state.
+	PriorityCritical
+)
+
+// maxBulkDeferrals is the number of writes of higher classes that may
+// go ahead of a waiting bulk write before it gets a turn.
+const maxBulkDeferrals = 8
+
+// SendSysExPriority is like SendSysEx but sends the message in class p.
+func (c *Client) SendSysExPriority(p Priority, cmd SysExCommand, data ...byte) error {
+	return c.sendPriority(wire.SysEx{Command: byte(cmd), Data: data}.Bytes(), p)
+}
+
+// writeLanes orders the writers waiting for the connection by priority.
+type writeLanes struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	busy     bool
+	waiting  [PriorityCritical + 1]int
+	deferred int // writes that went ahead of waiting bulk writes
+}
+
+func (l *writeLanes) acquire(p Priority) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cond == nil {
+		l.cond = sync.NewCond(&l.mu)
+	}
+	l.waiting[p]++
+	for l.busy || !l.turn(p) {
+		l.cond.Wait()
+	}
+	l.waiting[p]--
+	l.busy = true
+	switch {
+	case p == PriorityBulk:
+		l.deferred = 0
+	case l.waiting[PriorityBulk] > 0:
+		l.deferred++
+	}
+}
+
+// turn reports whether a writer of class p may go next. l.mu must be
+// held.
+func (l *writeLanes) turn(p Priority) bool {
+	if l.waiting[PriorityBulk] > 0 && l.deferred >= maxBulkDeferrals {
+		return p == PriorityBulk
+	}
+	for q := PriorityCritical; q > p; q-- {
+		if l.waiting[q] > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *writeLanes) release() {
+	l.mu.Lock()
+	l.busy = false
+	l.mu.Unlock()
+	l.cond.Broadcast()
+}
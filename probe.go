@@ -0,0 +1,54 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import "github.com/rakyll/go-firmata/wire"
+
+// Probe selects how a client starts talking to the board.
+type Probe int
+
+const (
+	// ProbeReset resets the board, which wipes the pin modes and
+	// outputs set by earlier sessions. It is the default.
+	ProbeReset Probe = iota
+
+	// ProbeQuery asks the board for its version and firmware and
+	// leaves its state alone.
+	ProbeQuery
+
+	// ProbePassive sends nothing and waits for the board to announce
+	// itself, as boards that reboot when the port is opened do.
+	ProbePassive
+)
+
+// WithProbe sets how the client starts the handshake.
+func WithProbe(p Probe) Option {
+	return func(c *Client) {
+		c.probe = p
+	}
+}
+
+// sendProbe starts or retries the handshake.
+func (c *Client) sendProbe() {
+	switch c.probe {
+	case ProbeReset:
+		c.send(wire.Reset{})
+	case ProbeQuery:
+		// The firmware ignores the data bytes of a version query.
+		c.versionQueried.Store(true)
+		c.send(wire.Version{})
+		c.sendFirmwareQuery()
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import "fmt"
+
+// WithAutoPWM makes AnalogWrite set pins that support PWM but are in
+// another mode to PWM mode instead of failing.
+func WithAutoPWM() Option {
+	return func(c *Client) {
+		c.autoPWM = true
+	}
+}
+
+// PWMError is returned by AnalogWrite for pins that don't support PWM,
+// or that are not in PWM or servo mode.
+type PWMError struct {
+	Pin uint8
+
+	// Mode is the mode of the pin, if known.
+	Mode  PinMode
+	Known bool
+
+	// Unsupported is set if the board reports no PWM support for Pin.
+	Unsupported bool
+}
+
+func (e *PWMError) Error() string {
+	switch {
+	case e.Unsupported:
+		return fmt.Sprintf("pin %d doesn't support PWM", e.Pin)
+	case e.Known:
+		return fmt.Sprintf("pin %d is in %v mode, not PWM", e.Pin, e.Mode)
+	}
+	return fmt.Sprintf("pin %d is not set to PWM mode", e.Pin)
+}
+
+// checkPWM reports a *PWMError unless pin is in PWM or servo mode. With
+// WithAutoPWM, pins supporting PWM are set to PWM mode first.
+func (c *Client) checkPWM(pin uint8) error {
+	c.stateMu.Lock()
+	mode, known := c.modes[pin]
+	c.stateMu.Unlock()
+	if known && (mode == PWM || mode == Servo) {
+		return nil
+	}
+	if !c.board().supports(int(pin), PWM) {
+		return &PWMError{Pin: pin, Mode: mode, Known: known, Unsupported: true}
+	}
+	if !c.autoPWM {
+		return &PWMError{Pin: pin, Mode: mode, Known: known}
+	}
+	return c.SetPinMode(pin, PWM)
+}
@@ -0,0 +1,210 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// defaultQueryTimeout bounds queries whose context has no deadline.
+const defaultQueryTimeout = time.Second
+
+// ErrTimeout is returned when the board doesn't reply to a query in time.
+var ErrTimeout = errors.New("firmata: reply timed out")
+
+// queryKey identifies the reply a query waits for: the reply command
+// and a command specific id such as a pin number.
+type queryKey struct {
+	cmd SysExCommand
+	id  int
+}
+
+// pendingQueries matches replies to outstanding queries. Queries with
+// the same key are resolved in the order they were issued.
+type pendingQueries struct {
+	mu      sync.Mutex
+	waiters map[queryKey][]chan interface{}
+}
+
+func (p *pendingQueries) add(key queryKey) chan interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.waiters == nil {
+		p.waiters = make(map[queryKey][]chan interface{})
+	}
+	ch := make(chan interface{}, 1)
+	p.waiters[key] = append(p.waiters[key], ch)
+	return ch
+}
+
+// resolve delivers v to the oldest query waiting for key. It reports
+// whether a query was waiting.
+func (p *pendingQueries) resolve(key queryKey, v interface{}) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	waiting := p.waiters[key]
+	if len(waiting) == 0 {
+		return false
+	}
+	waiting[0] <- v
+	if len(waiting) == 1 {
+		delete(p.waiters, key)
+	} else {
+		p.waiters[key] = waiting[1:]
+	}
+	return true
+}
+
+// waiting reports whether a query waits for key.
+func (p *pendingQueries) waiting(key queryKey) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.waiters[key]) > 0
+}
+
+// len returns the number of queries waiting for a reply.
+func (p *pendingQueries) len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, waiting := range p.waiters {
+		n += len(waiting)
+	}
+	return n
+}
+
+func (p *pendingQueries) cancel(key queryKey, ch chan interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	waiting := p.waiters[key]
+	for i, w := range waiting {
+		if w == ch {
+			p.waiters[key] = append(waiting[:i], waiting[i+1:]...)
+			return
+		}
+	}
+}
+
+// query sends a request with send and waits for the reply matching key.
+// It fails when ctx is done or, if ctx has no deadline, after
+// defaultQueryTimeout.
+func (c *Client) query(ctx context.Context, key queryKey, send func() error) (interface{}, error) {
+	reply, err := c.startQuery(key, send)
+	if err != nil {
+		return nil, err
+	}
+	return c.waitQuery(ctx, key, reply)
+}
+
+func (c *Client) startQuery(key queryKey, send func() error) (chan interface{}, error) {
+	reply := c.pending.add(key)
+	if err := send(); err != nil {
+		c.pending.cancel(key, reply)
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (c *Client) waitQuery(ctx context.Context, key queryKey, reply chan interface{}) (interface{}, error) {
+	var timeout <-chan time.Time
+	if _, ok := ctx.Deadline(); !ok {
+		timeout = c.clock.After(defaultQueryTimeout)
+	}
+	select {
+	case v := <-reply:
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+		return v, nil
+	case <-ctx.Done():
+		c.pending.cancel(key, reply)
+		return nil, ctx.Err()
+	case <-timeout:
+		c.pending.cancel(key, reply)
+		return nil, ErrTimeout
+	}
+}
+
+// Firmware identifies the firmware running on the board.
+type Firmware struct {
+	Name         string
+	Major, Minor int
+}
+
+// QueryFirmware asks the board for its firmware name and version.
+func (c *Client) QueryFirmware(ctx context.Context) (Firmware, error) {
+	v, err := c.query(ctx, queryKey{cmd: ReportFirmware}, func() error {
+		return c.sendFirmwareQuery()
+	})
+	if err != nil {
+		return Firmware{}, err
+	}
+	return v.(Firmware), nil
+}
+
+// QueryCapabilities asks the board for the modes supported by its pins
+// and refreshes the capabilities known to the client.
+func (c *Client) QueryCapabilities(ctx context.Context) error {
+	_, err := c.query(ctx, queryKey{cmd: CapabilityResponse}, func() error {
+		return c.sendSysEx(CapabilityQuery)
+	})
+	return err
+}
+
+// PinState is the mode and state of a pin as tracked by the firmware.
+type PinState struct {
+	Pin  int
+	Mode PinMode
+
+	// State is the output level or value written to output pins, and
+	// whether the pull-up is enabled for input pins.
+	State int
+}
+
+// QueryPinState asks the board for the current mode and state of pin.
+func (c *Client) QueryPinState(ctx context.Context, pin uint8) (PinState, error) {
+	v, err := c.query(ctx, queryKey{cmd: PinStateResponse, id: int(pin)}, func() error {
+		return c.sendSysEx(PinStateQuery, pin&0x7F)
+	})
+	if err != nil {
+		return PinState{}, err
+	}
+	return v.(PinState), nil
+}
+
+// parsePinStateResponse decodes the pin number, mode and the state
+// sent as a variable number of 7-bit bytes, LSB first. The board sends
+// the pin number alone for pins it doesn't have.
+func (c *Client) parsePinStateResponse(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	if len(data) < 3 {
+		c.diagnose(PinOutOfRange, "state of pin %d", data[0])
+		c.pending.resolve(queryKey{cmd: PinStateResponse, id: int(data[0])}, fmt.Errorf("board has no pin %d", data[0]))
+		return
+	}
+	s := PinState{Pin: int(data[0]), Mode: PinMode(data[1]), State: int(wire.DecodeUint(data[2:]))}
+	if c.capabilityDone && s.Pin >= len(c.board().modes) {
+		c.diagnose(PinOutOfRange, "state of pin %d", s.Pin)
+	}
+	c.pending.resolve(queryKey{cmd: PinStateResponse, id: s.Pin}, s)
+}
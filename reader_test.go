@@ -0,0 +1,145 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/simulator"
+)
+
+// noise is a set of malformed frames mixed with the random bytes sent to
+// the client.
+var noise = [][]byte{
+	{0xF0},                   // sysex that never ends
+	{0xF0, 0x79, 0xF7},       // firmware report without a version
+	{0xF0, 0x6E, 0xF7},       // empty pin state response
+	{0xF0, 0x6E, 0x7E, 0xF7}, // state of a pin the board doesn't have
+	{0xF0, 0x6F, 0xF7},       // analog mapping query sent by the board
+	{0xF0, 0x01, 0x02, 0xF7}, // unknown sysex
+	{0x9F},                   // digital report without data
+	{0xE0, 0x7F},             // truncated analog report
+	{0xF9, 0x02},             // truncated version report
+	{0x80, 0x80, 0x80},       // data bytes above 0x7F
+}
+
+func TestGarbage(t *testing.T) {
+	b := simulator.New(nil)
+	c, err := firmata.NewClientConn(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		if i%2 == 0 {
+			b.Inject(noise[rnd.Intn(len(noise))])
+			continue
+		}
+		p := make([]byte, rnd.Intn(64))
+		rnd.Read(p)
+		b.Inject(p)
+	}
+	// End any sysex left open by the noise.
+	b.Inject([]byte{0xF7})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.SetPinMode(13, firmata.Output); err != nil {
+		t.Fatal(err)
+	}
+	s, err := c.QueryPinState(ctx, 13)
+	if err != nil {
+		t.Fatalf("QueryPinState after noise: %v", err)
+	}
+	if s.Mode != firmata.Output {
+		t.Errorf("mode of pin 13 = %v; want %v", s.Mode, firmata.Output)
+	}
+}
+
+func TestUnplugMidStream(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		b := simulator.New(nil)
+		c, err := firmata.NewClientConn(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		errs := firmata.Subscribe[firmata.ErrorEvent](c, firmata.Filter{})
+		if err := c.SetPinMode(2, firmata.Input); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.EnableDigitalInput(2, true); err != nil {
+			t.Fatal(err)
+		}
+
+		stop := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for high := false; ; high = !high {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				b.SetDigital(2, high)
+				b.Inject([]byte{0xF0, 0x71, 'h', 0x00})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				c.DigitalWrite(13, true)
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+				c.QueryPinState(ctx, 13)
+				cancel()
+			}
+		}()
+
+		time.Sleep(time.Duration(i) * time.Millisecond)
+		b.Fail(io.ErrUnexpectedEOF)
+		select {
+		case e := <-errs:
+			if !errors.Is(e.Err, io.ErrUnexpectedEOF) {
+				t.Errorf("ErrorEvent.Err = %v; want %v", e.Err, io.ErrUnexpectedEOF)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no ErrorEvent after the board was unplugged")
+		}
+		close(stop)
+		wg.Wait()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		if _, err := c.QueryPinState(ctx, 13); err == nil {
+			t.Error("QueryPinState succeeded on an unplugged board")
+		}
+		cancel()
+		c.Close()
+	}
+}
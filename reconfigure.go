@@ -0,0 +1,72 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import "fmt"
+
+// Reconfigure switches pin to mode after tearing down its current use,
+// so long-running programs can repurpose pins without reconnecting.
+// Analog reporting of the pin is stopped, digital reporting of its port
+// is stopped if no other input remains on it, PWM outputs are written
+// to zero, digital outputs are driven low, the pull-up of inputs is
+// disabled and encoders using the pin are detached. Servos are left
+// where they are: the mode change detaches them in the firmware.
+func (c *Client) Reconfigure(pin uint8, mode PinMode) error {
+	if !c.board().supports(int(pin), mode) {
+		return fmt.Errorf("pin mode = %v not supported by pin %v", mode, pin)
+	}
+
+	c.stateMu.Lock()
+	prev, known := c.modes[pin]
+	otherInputs := false
+	for p, m := range c.modes {
+		if p != pin && p/8 == pin/8 && m == Input {
+			otherInputs = true
+		}
+	}
+	reporting := c.digitalReporting[pin/8]
+	pullup := c.digitalPinState[pin/8]&(1<<(pin%8)) != 0
+	encoder, attached := c.encoderPins[pin]
+	c.stateMu.Unlock()
+
+	if known {
+		var err error
+		switch prev {
+		case Analog:
+			err = c.EnableAnalogInput(uint(pin), false)
+		case PWM:
+			err = c.AnalogWrite(uint(pin), 0)
+		case Output:
+			err = c.DigitalWrite(pin, false)
+		case Input:
+			if pullup {
+				// Writing high to an input enables its pull-up, which
+				// would drive the pin high if it became an output.
+				err = c.digitalWrite(pin, false)
+			}
+			if err == nil && reporting && !otherInputs {
+				err = c.EnableDigitalInput(uint(pin), false)
+			}
+		case Encoder:
+			if attached {
+				err = c.DetachEncoder(encoder)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return c.SetPinMode(pin, mode)
+}
@@ -0,0 +1,179 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"io"
+	"math/rand"
+	"time"
+)
+
+// Backoff configures how a client reconnects after losing the
+// connection to the board. The delay before attempt n is
+// Initial*Multiplier^(n-1), capped at Max and randomized by Jitter.
+// A Multiplier below 1 is taken as 1 and no delay is shorter than
+// minBackoff, so that a zero Backoff does not spin.
+type Backoff struct {
+	Initial    time.Duration
+	Multiplier float64
+	Max        time.Duration
+
+	// Jitter is the fraction of the delay, between 0 and 1, that is
+	// randomly added or removed so that many gateways don't retry in
+	// lockstep.
+	Jitter float64
+
+	// MaxAttempts is the number of attempts after which the client
+	// gives up. Zero means no limit.
+	MaxAttempts int
+}
+
+// DefaultBackoff is a reasonable reconnect strategy for USB boards.
+var DefaultBackoff = Backoff{
+	Initial:    500 * time.Millisecond,
+	Multiplier: 2,
+	Max:        time.Minute,
+	Jitter:     0.2,
+}
+
+// minBackoff is the shortest delay between reconnect attempts.
+const minBackoff = 10 * time.Millisecond
+
+func (b Backoff) delay(attempt int) time.Duration {
+	m := b.Multiplier
+	if m < 1 {
+		m = 1
+	}
+	d := float64(b.Initial)
+	for i := 1; i < attempt; i++ {
+		d *= m
+		if b.Max > 0 && d > float64(b.Max) {
+			break
+		}
+	}
+	if b.Max > 0 && d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+	if b.Jitter > 0 {
+		d += d * b.Jitter * (2*rand.Float64() - 1)
+	}
+	if d < float64(minBackoff) {
+		d = float64(minBackoff)
+	}
+	return time.Duration(d)
+}
+
+// ReconnectEvent is published after each reconnect attempt.
+type ReconnectEvent struct {
+	Header
+	Attempt int
+	Delay   time.Duration
+
+	// Err is nil if the attempt succeeded.
+	Err error
+
+	// GaveUp is set on the last failed attempt.
+	GaveUp bool
+}
+
+// WithDialer sets the function the client opens a new connection with
+// when reconnecting. NewClient sets it to reopen the serial port.
+func WithDialer(dial func() (io.ReadWriteCloser, error)) Option {
+	return func(c *Client) {
+		c.dial = dial
+	}
+}
+
+// WithReconnect makes the client reconnect with b when the connection
+// to the board is lost after the handshake, and replay its
+// configuration on the new connection. It needs a dialer, see
+// WithDialer.
+func WithReconnect(b Backoff) Option {
+	return func(c *Client) {
+		c.backoff = &b
+	}
+}
+
+// reconnect dials until it succeeds, the client is closed or the
+// backoff gives up. It returns the new connection or nil. The connection
+// the reader uses is identified by gen, which reconnect updates; a
+// connection given to SwapTransport meanwhile is returned instead.
+func (c *Client) reconnect(gen *int) io.ReadWriteCloser {
+	if c.backoff == nil || c.dial == nil || !c.isInited() {
+		return nil
+	}
+	for attempt := 1; c.backoff.MaxAttempts == 0 || attempt <= c.backoff.MaxAttempts; attempt++ {
+		if c.isClosing() {
+			return nil
+		}
+		d := c.backoff.delay(attempt)
+		select {
+		case <-c.clock.After(d):
+		case <-c.closed:
+			return nil
+		}
+		if conn, ok := c.swapped(gen); ok {
+			return conn
+		}
+		conn, err := c.dial()
+		if err != nil {
+			gaveUp := attempt == c.backoff.MaxAttempts
+			c.bus.publish(ReconnectEvent{Header{c.clock.Now()}, attempt, d, err, gaveUp})
+			continue
+		}
+
+		c.connMu.Lock()
+		if c.closing {
+			c.connMu.Unlock()
+			conn.Close()
+			return nil
+		}
+		if c.connGen != *gen {
+			// Swapped while dialing.
+			conn.Close()
+			conn, *gen = c.conn, c.connGen
+			c.connMu.Unlock()
+			return conn
+		}
+		c.conn.Close()
+		c.conn = conn
+		c.connGen++
+		*gen = c.connGen
+		c.onStandby = false
+		c.stuckWrite = nil
+		c.connMu.Unlock()
+
+		c.counters.reconnects.Add(1)
+		c.bus.publish(ReconnectEvent{Header{c.clock.Now()}, attempt, d, nil, false})
+		go c.replayAfterReset()
+		return conn
+	}
+	return nil
+}
+
+func (c *Client) isInited() bool {
+	select {
+	case <-c.inited:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Client) isClosing() bool {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	return c.closing
+}
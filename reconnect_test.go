@@ -0,0 +1,113 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/fakeclock"
+	"github.com/rakyll/go-firmata/simulator"
+)
+
+// waitBlocked waits until more than n timers are pending on clk.
+func waitBlocked(t *testing.T, clk *fakeclock.Clock, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for clk.Waiters() <= n {
+		if time.Now().After(deadline) {
+			t.Fatal("nothing waits on the clock")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	clk := fakeclock.New(time.Unix(0, 0))
+	b := simulator.New(nil)
+	var dials int32
+	dial := func() (io.ReadWriteCloser, error) {
+		if atomic.AddInt32(&dials, 1) == 1 {
+			return nil, errors.New("board not plugged in")
+		}
+		return simulator.New(nil), nil
+	}
+	c, err := firmata.NewClientConn(b,
+		firmata.WithClock(clk),
+		firmata.WithDialer(dial),
+		firmata.WithReconnect(firmata.Backoff{Initial: time.Second, Multiplier: 2, Max: time.Minute}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	events := firmata.Subscribe[firmata.ReconnectEvent](c, firmata.Filter{})
+
+	n := clk.Waiters()
+	b.Fail(io.ErrUnexpectedEOF)
+	steps := []struct {
+		delay time.Duration
+		ok    bool
+	}{
+		{time.Second, false},
+		{2 * time.Second, true},
+	}
+	for i, step := range steps {
+		waitBlocked(t, clk, n)
+		clk.Advance(step.delay - time.Millisecond)
+		if got := atomic.LoadInt32(&dials); got != int32(i) {
+			t.Fatalf("dialed %d times before the delay of attempt %d passed", got, i+1)
+		}
+		clk.Advance(time.Millisecond)
+		select {
+		case ev := <-events:
+			if ev.Attempt != i+1 || ev.Delay != step.delay || (ev.Err == nil) != step.ok {
+				t.Fatalf("attempt %d: got %+v, want delay %v and success %v", i+1, ev, step.delay, step.ok)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("attempt %d not reported", i+1)
+		}
+	}
+}
+
+func TestCloseInterruptsBackoff(t *testing.T) {
+	clk := fakeclock.New(time.Unix(0, 0))
+	b := simulator.New(nil)
+	dial := func() (io.ReadWriteCloser, error) { return simulator.New(nil), nil }
+	c, err := firmata.NewClientConn(b,
+		firmata.WithClock(clk),
+		firmata.WithDialer(dial),
+		firmata.WithReconnect(firmata.Backoff{Initial: time.Hour}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := clk.Waiters()
+	b.Fail(io.ErrUnexpectedEOF)
+	waitBlocked(t, clk, n)
+	c.Close()
+
+	// The reader stops instead of sleeping through the backoff, which
+	// the fake clock would never end.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := c.DelayCtx(ctx, time.Hour); err == nil || err == ctx.Err() {
+		t.Fatalf("DelayCtx = %v, want the connection closed", err)
+	}
+}
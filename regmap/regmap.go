@@ -0,0 +1,150 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package regmap reads and writes the registers of I2C devices as typed
+// values, so drivers don't each decode multi-byte registers. The client
+// must have I2C enabled with I2CConfig.
+package regmap
+
+import (
+	"fmt"
+
+	"github.com/rakyll/go-firmata"
+)
+
+// Order is the byte order of multi-byte registers.
+type Order int
+
+const (
+	BigEndian Order = iota
+	LittleEndian
+)
+
+// Map is the register map of the I2C device at an address.
+type Map struct {
+	c    *firmata.Client
+	addr byte
+
+	// Order is the byte order of multi-byte registers, BigEndian
+	// unless set otherwise.
+	Order Order
+}
+
+// New returns the register map of the device at addr.
+func New(c *firmata.Client, addr byte) *Map {
+	return &Map{c: c, addr: addr}
+}
+
+// Read reads n bytes starting at reg.
+func (m *Map) Read(reg byte, n int) ([]byte, error) {
+	b, err := m.c.I2CRead(m.addr, int(reg), n)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != n {
+		return nil, fmt.Errorf("regmap: read %d bytes from register %#x of %#x, want %d", len(b), reg, m.addr, n)
+	}
+	return b, nil
+}
+
+// Write writes data starting at reg.
+func (m *Map) Write(reg byte, data ...byte) error {
+	return m.c.I2CWrite(m.addr, append([]byte{reg}, data...)...)
+}
+
+// ReadUint8 reads the 8-bit register reg.
+func (m *Map) ReadUint8(reg byte) (uint8, error) {
+	v, err := m.readUint(reg, 1)
+	return uint8(v), err
+}
+
+// ReadUint16 reads the 16-bit register at reg.
+func (m *Map) ReadUint16(reg byte) (uint16, error) {
+	v, err := m.readUint(reg, 2)
+	return uint16(v), err
+}
+
+// ReadUint24 reads the 24-bit register at reg.
+func (m *Map) ReadUint24(reg byte) (uint32, error) {
+	return m.readUint(reg, 3)
+}
+
+// ReadUint32 reads the 32-bit register at reg.
+func (m *Map) ReadUint32(reg byte) (uint32, error) {
+	return m.readUint(reg, 4)
+}
+
+// WriteUint8 writes v to the 8-bit register reg.
+func (m *Map) WriteUint8(reg byte, v uint8) error {
+	return m.Write(reg, v)
+}
+
+// WriteUint16 writes v to the 16-bit register at reg.
+func (m *Map) WriteUint16(reg byte, v uint16) error {
+	return m.Write(reg, m.encode(uint32(v), 2)...)
+}
+
+// WriteUint24 writes the low 24 bits of v to the register at reg.
+func (m *Map) WriteUint24(reg byte, v uint32) error {
+	return m.Write(reg, m.encode(v, 3)...)
+}
+
+// WriteUint32 writes v to the 32-bit register at reg.
+func (m *Map) WriteUint32(reg byte, v uint32) error {
+	return m.Write(reg, m.encode(v, 4)...)
+}
+
+// Update sets the bits of the 8-bit register reg selected by mask to
+// those of v, leaving the others unchanged.
+func (m *Map) Update(reg byte, mask, v uint8) error {
+	cur, err := m.ReadUint8(reg)
+	if err != nil {
+		return err
+	}
+	return m.WriteUint8(reg, cur&^mask|v&mask)
+}
+
+func (m *Map) readUint(reg byte, n int) (uint32, error) {
+	b, err := m.Read(reg, n)
+	if err != nil {
+		return 0, err
+	}
+	return m.decode(b), nil
+}
+
+// decode joins b in the byte order of m.
+func (m *Map) decode(b []byte) uint32 {
+	var v uint32
+	for i := range b {
+		j := i
+		if m.Order == LittleEndian {
+			j = len(b) - 1 - i
+		}
+		v = v<<8 | uint32(b[j])
+	}
+	return v
+}
+
+// encode splits the low n bytes of v in the byte order of m.
+func (m *Map) encode(v uint32, n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		j := n - 1 - i
+		if m.Order == LittleEndian {
+			j = i
+		}
+		b[j] = byte(v >> uint(8*i))
+	}
+	return b
+}
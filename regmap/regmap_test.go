@@ -0,0 +1,145 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regmap
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/simulator"
+)
+
+const addr = 0x40
+
+var uintTests = []struct {
+	order Order
+	size  int
+	raw   []byte
+	v     uint32
+}{
+	{BigEndian, 1, []byte{0xA5}, 0xA5},
+	{LittleEndian, 1, []byte{0xA5}, 0xA5},
+	{BigEndian, 2, []byte{0x12, 0x34}, 0x1234},
+	{LittleEndian, 2, []byte{0x12, 0x34}, 0x3412},
+	{BigEndian, 2, []byte{0xFF, 0xFE}, 0xFFFE},
+	{BigEndian, 3, []byte{0x12, 0x34, 0x56}, 0x123456},
+	{LittleEndian, 3, []byte{0x12, 0x34, 0x56}, 0x563412},
+	{BigEndian, 4, []byte{0x12, 0x34, 0x56, 0x78}, 0x12345678},
+	{LittleEndian, 4, []byte{0x12, 0x34, 0x56, 0x78}, 0x78563412},
+	{LittleEndian, 4, []byte{0xFF, 0xFF, 0xFF, 0xFF}, 0xFFFFFFFF},
+}
+
+func newMap(t *testing.T) (*Map, *simulator.Board) {
+	b := simulator.New(nil)
+	c, err := firmata.NewClientConn(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if err := c.I2CConfig(0); err != nil {
+		t.Fatal(err)
+	}
+	return New(c, addr), b
+}
+
+func (m *Map) readSized(reg byte, size int) (uint32, error) {
+	switch size {
+	case 1:
+		v, err := m.ReadUint8(reg)
+		return uint32(v), err
+	case 2:
+		v, err := m.ReadUint16(reg)
+		return uint32(v), err
+	case 3:
+		return m.ReadUint24(reg)
+	default:
+		return m.ReadUint32(reg)
+	}
+}
+
+func (m *Map) writeSized(reg byte, size int, v uint32) error {
+	switch size {
+	case 1:
+		return m.WriteUint8(reg, uint8(v))
+	case 2:
+		return m.WriteUint16(reg, uint16(v))
+	case 3:
+		return m.WriteUint24(reg, v)
+	default:
+		return m.WriteUint32(reg, v)
+	}
+}
+
+func TestReadUint(t *testing.T) {
+	m, sim := newMap(t)
+	for _, tt := range uintTests {
+		m.Order = tt.order
+		sim.SetI2C(addr, 0x10, tt.raw...)
+		got, err := m.readSized(0x10, tt.size)
+		if err != nil {
+			t.Fatalf("reading %d bytes %v: %v", tt.size, tt.raw, err)
+		}
+		if got != tt.v {
+			t.Errorf("reading %d bytes %#v in order %d = %#x; want %#x", tt.size, tt.raw, tt.order, got, tt.v)
+		}
+	}
+}
+
+func TestWriteUint(t *testing.T) {
+	m, _ := newMap(t)
+	for _, tt := range uintTests {
+		m.Order = tt.order
+		if err := m.writeSized(0x20, tt.size, tt.v); err != nil {
+			t.Fatalf("writing %#x: %v", tt.v, err)
+		}
+		got, err := m.Read(0x20, tt.size)
+		if err != nil {
+			t.Fatalf("reading back %#x: %v", tt.v, err)
+		}
+		if !bytes.Equal(got, tt.raw) {
+			t.Errorf("writing %#x in %d bytes in order %d = %#v; want %#v", tt.v, tt.size, tt.order, got, tt.raw)
+		}
+	}
+}
+
+func TestWriteUint24Truncates(t *testing.T) {
+	m, _ := newMap(t)
+	if err := m.WriteUint24(0x30, 0xAB123456); err != nil {
+		t.Fatal(err)
+	}
+	got, err := m.Read(0x30, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x12, 0x34, 0x56, 0x00}; !bytes.Equal(got, want) {
+		t.Errorf("registers = %#v; want %#v", got, want)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	m, sim := newMap(t)
+	sim.SetI2C(addr, 0x01, 0xF0)
+	if err := m.Update(0x01, 0x3C, 0x0F); err != nil {
+		t.Fatal(err)
+	}
+	got, err := m.ReadUint8(0x01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := uint8(0xCC); got != want {
+		t.Errorf("register = %#x; want %#x", got, want)
+	}
+}
@@ -0,0 +1,297 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package relay shares a single board between multiple network clients.
+// Commands from all clients are serialized onto the board connection
+// and everything the board reports is broadcast to every client.
+package relay
+
+import (
+	"io"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// Server relays the Firmata stream of a board to TCP clients.
+//
+// A SYSTEM_RESET sent by a client would wipe the pin configuration of
+// every other client, and the replies to the queries of a client's
+// handshake would look like a board reset to the others. So the server
+// answers resets and version, firmware, capability and analog mapping
+// queries itself, to the asking client alone, with what the board
+// reported. The board is only asked for what isn't known yet, and the
+// version and firmware when the server starts.
+type Server struct {
+	board io.ReadWriteCloser
+	enc   *wire.Encoder
+
+	wmu sync.Mutex // serializes writes to the board
+
+	mu      sync.Mutex
+	clients map[net.Conn]chan []byte
+	cache   map[byte][]byte                   // last reply of the board, by kind
+	asked   map[byte]bool                     // queries the board has yet to answer
+	waiting map[byte]map[net.Conn]chan []byte // clients waiting for a reply, by kind
+	done    chan struct{}                     // closed when reading the board fails
+	err     error
+	once    sync.Once
+}
+
+// Kinds of the replies the server caches, which are their command bytes.
+const (
+	reportVersion         = 0xF9
+	reportFirmware        = 0x79
+	capabilityQuery       = 0x6B
+	capabilityResponse    = 0x6C
+	analogMappingQuery    = 0x69
+	analogMappingResponse = 0x6A
+)
+
+// queries are the queries of the cached replies, by kind. The firmware
+// ignores the data bytes of a version query.
+var queries = map[byte]wire.Message{
+	reportVersion:         wire.Version{},
+	reportFirmware:        wire.SysEx{Command: reportFirmware},
+	capabilityResponse:    wire.SysEx{Command: capabilityQuery},
+	analogMappingResponse: wire.SysEx{Command: analogMappingQuery},
+}
+
+// NewServer returns a server relaying the board connection.
+func NewServer(board io.ReadWriteCloser) *Server {
+	return &Server{
+		board:   board,
+		enc:     wire.NewEncoder(board),
+		clients: make(map[net.Conn]chan []byte),
+		cache:   make(map[byte][]byte),
+		asked:   make(map[byte]bool),
+		waiting: make(map[byte]map[net.Conn]chan []byte),
+		done:    make(chan struct{}),
+	}
+}
+
+// ListenAndServe listens on the TCP network address addr and serves
+// clients connecting to it.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts client connections on l until it fails or reading from
+// the board fails, in which case l is closed and the read error is
+// returned.
+func (s *Server) Serve(l net.Listener) error {
+	s.once.Do(func() {
+		s.mu.Lock()
+		s.asked[reportVersion] = true
+		s.asked[reportFirmware] = true
+		s.mu.Unlock()
+		go s.readBoard()
+		if err := s.write(queries[reportVersion], queries[reportFirmware]); err != nil {
+			log.Printf("relay: querying board: %v", err)
+		}
+	})
+	go func() {
+		<-s.done
+		l.Close()
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+				return s.err
+			default:
+				return err
+			}
+		}
+		go s.serveClient(conn)
+	}
+}
+
+func (s *Server) serveClient(conn net.Conn) {
+	out := make(chan []byte, 256)
+	s.mu.Lock()
+	select {
+	case <-s.done:
+		s.mu.Unlock()
+		conn.Close()
+		return
+	default:
+	}
+	s.clients[conn] = out
+	s.mu.Unlock()
+
+	go func() {
+		for b := range out {
+			if _, err := conn.Write(b); err != nil {
+				conn.Close()
+				return
+			}
+		}
+	}()
+
+	defer s.remove(conn)
+	d := wire.NewDecoder(conn)
+	for {
+		m, err := d.Decode()
+		if err != nil {
+			return
+		}
+		var kinds []byte
+		switch m := m.(type) {
+		case wire.Reset:
+			kinds = []byte{reportVersion, reportFirmware}
+		case wire.Version:
+			kinds = []byte{reportVersion}
+		case wire.SysEx:
+			switch {
+			case m.Command == reportFirmware && len(m.Data) == 0:
+				kinds = []byte{reportFirmware}
+			case m.Command == capabilityQuery:
+				kinds = []byte{capabilityResponse}
+			case m.Command == analogMappingQuery:
+				kinds = []byte{analogMappingResponse}
+			}
+		}
+		if kinds == nil {
+			err = s.write(m)
+		} else {
+			err = s.answer(conn, out, kinds...)
+		}
+		if err != nil {
+			log.Printf("relay: writing to board: %v", err)
+			return
+		}
+	}
+}
+
+// answer sends the cached replies of kinds to a client. For the replies
+// not known yet, the client waits for the board, which is asked unless
+// another client already did.
+func (s *Server) answer(conn net.Conn, out chan []byte, kinds ...byte) error {
+	var ask []wire.Message
+	s.mu.Lock()
+	for _, kind := range kinds {
+		if b := s.cache[kind]; b != nil && s.waiting[kind] == nil {
+			send(conn, out, b)
+			continue
+		}
+		if s.waiting[kind] == nil {
+			s.waiting[kind] = make(map[net.Conn]chan []byte)
+		}
+		s.waiting[kind][conn] = out
+		if !s.asked[kind] {
+			s.asked[kind] = true
+			ask = append(ask, queries[kind])
+		}
+	}
+	s.mu.Unlock()
+	return s.write(ask...)
+}
+
+// send queues b for a client, disconnecting it if it can't keep up.
+// s.mu must be held.
+func send(conn net.Conn, out chan []byte, b []byte) {
+	select {
+	case out <- b:
+	default:
+		conn.Close()
+	}
+}
+
+func (s *Server) remove(conn net.Conn) {
+	s.mu.Lock()
+	if out, ok := s.clients[conn]; ok {
+		close(out)
+		delete(s.clients, conn)
+	}
+	for _, w := range s.waiting {
+		delete(w, conn)
+	}
+	s.mu.Unlock()
+	conn.Close()
+}
+
+func (s *Server) write(msgs ...wire.Message) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	for _, m := range msgs {
+		if err := s.enc.Encode(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// kindOf returns the kind of a cached reply, or 0 for other messages.
+func kindOf(m wire.Message) byte {
+	switch m := m.(type) {
+	case wire.Version:
+		return reportVersion
+	case wire.SysEx:
+		switch m.Command {
+		case reportFirmware, capabilityResponse, analogMappingResponse:
+			return m.Command
+		}
+	}
+	return 0
+}
+
+// readBoard broadcasts the frames of the board. The replies to the
+// queries of the server go to the clients waiting for them alone; a
+// version or firmware report nobody asked for means the board was reset
+// and is broadcast. Clients that can't keep up are disconnected rather
+// than stalling the others. When reading fails, every client is
+// disconnected and no more are accepted.
+func (s *Server) readBoard() {
+	d := wire.NewDecoder(s.board)
+	for {
+		m, err := d.Decode()
+		if err != nil {
+			log.Printf("relay: reading from board: %v", err)
+			s.mu.Lock()
+			s.err = err
+			close(s.done)
+			for conn := range s.clients {
+				conn.Close()
+			}
+			s.mu.Unlock()
+			return
+		}
+		b := m.Bytes()
+		s.mu.Lock()
+		if kind := kindOf(m); kind != 0 {
+			s.cache[kind] = b
+			if s.asked[kind] {
+				s.asked[kind] = false
+				for conn, out := range s.waiting[kind] {
+					send(conn, out, b)
+				}
+				delete(s.waiting, kind)
+				s.mu.Unlock()
+				continue
+			}
+		}
+		for conn, out := range s.clients {
+			send(conn, out, b)
+		}
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relay_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/relay"
+	"github.com/rakyll/go-firmata/simulator"
+	"github.com/rakyll/go-firmata/wire"
+)
+
+func serve(t *testing.T) (addr string, board *simulator.Board) {
+	board = simulator.New(nil)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go relay.NewServer(board).Serve(l)
+	t.Cleanup(func() {
+		l.Close()
+		board.Close()
+	})
+	return l.Addr().String(), board
+}
+
+func dial(t *testing.T, addr string) *firmata.Client {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := firmata.NewClientConn(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestQueriesOfPeersAreNotResets(t *testing.T) {
+	addr, _ := serve(t)
+	c := dial(t, addr)
+	diag := c.Diagnostics()
+
+	peer := dial(t, addr)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := peer.QueryFirmware(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := peer.QueryCapabilities(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	enc := wire.NewEncoder(raw)
+	if err := enc.Encode(wire.Version{}); err != nil {
+		t.Fatal(err)
+	}
+	raw.SetReadDeadline(time.Now().Add(2 * time.Second))
+	d := wire.NewDecoder(raw)
+	for {
+		m, err := d.Decode()
+		if err != nil {
+			t.Fatalf("no version reply: %v", err)
+		}
+		if _, ok := m.(wire.Version); ok {
+			break
+		}
+	}
+
+	timeout := time.After(200 * time.Millisecond)
+	for {
+		select {
+		case ev := <-diag:
+			if ev.Kind == firmata.UnexpectedReset {
+				t.Fatalf("query of a peer taken for a reset: %s", ev.Detail)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
@@ -15,94 +15,229 @@
 package firmata
 
 import (
-	"bufio"
 	"fmt"
+	"io"
+
+	"github.com/rakyll/go-firmata/wire"
 )
 
+// ValueKind tells digital and analog values apart.
+type ValueKind byte
+
+const (
+	KindDigital ValueKind = iota
+	KindAnalog
+)
+
+// FirmataValue is a digital or analog report received from the board.
 type FirmataValue struct {
-	valueType            FirmataCommand
-	value                int
-	analogChannelPinsMap map[byte]int
+	Kind ValueKind
+
+	// Pin and Channel identify the pin of an analog value. Pin is -1
+	// when the channel is not mapped to a pin.
+	Pin     int
+	Channel byte
+
+	// Port is the port of a digital value.
+	Port byte
+
+	// Raw is the analog reading or, for a digital value, the pin levels
+	// of the port with bit n for pin Port*8+n.
+	Raw int
+
+	// Levels holds the decoded pin levels of a digital value.
+	Levels [8]bool
 }
 
 func (v FirmataValue) IsAnalog() bool {
-	return (v.valueType & 0xF0) == AnalogMessage
+	return v.Kind == KindAnalog
 }
 
+// AnalogValue returns the pin and the reading of an analog value.
 func (v FirmataValue) AnalogValue() (pin int, val int, err error) {
 	if !v.IsAnalog() {
 		return 0, 0, fmt.Errorf("cannot get analog value for digital pin")
 	}
-	return v.analogChannelPinsMap[byte(v.valueType & ^AnalogMessage)], v.value, nil
+	return v.Pin, v.Raw, nil
 }
 
+// DigitalValue returns the port of a digital value and the level of
+// each of its pins, keyed by pin number.
 func (v FirmataValue) DigitalValue() (port byte, val map[byte]interface{}, err error) {
 	if v.IsAnalog() {
 		return byte(0), nil, fmt.Errorf("Cannot get digital value for analog pin")
 	}
 
-	port = byte(v.valueType & ^DigitalMessage)
 	val = make(map[byte]interface{})
-	mask := 0x01
-	for i := byte(0); i < 8; i++ {
-		val[port*8+i] = ((v.value & mask) > 0)
-		mask = mask * 2
+	for i, high := range v.Levels {
+		val[v.Port*8+byte(i)] = high
 	}
-	return
+	return v.Port, val, nil
 }
 
 func (v FirmataValue) String() string {
 	if v.IsAnalog() {
-		p, v, _ := v.AnalogValue()
-		return fmt.Sprintf("Analog value %v = %v", p, v)
-	} else {
-		p, v, _ := v.AnalogValue()
-		return fmt.Sprintf("Digital port %v = %b", p, v)
+		return fmt.Sprintf("Analog value %v = %v", v.Pin, v.Raw)
 	}
+	return fmt.Sprintf("Digital port %v = %08b", v.Port, v.Raw)
 }
 
+// valuesBuffer is the channel buffer size of Values.
+const valuesBuffer = 256
+
+// subscribeValues adds a subscription delivering FirmataValues to a new
+// channel.
+func (c *Client) subscribeValues() chan FirmataValue {
+	ch := make(chan FirmataValue, valuesBuffer)
+	c.bus.add(&subscription{
+		key: (<-chan FirmataValue)(ch),
+		deliver: func(ev Event) bool {
+			var v FirmataValue
+			switch e := eventOf(ev).(type) {
+			case DigitalEvent:
+				v = FirmataValue{Kind: KindDigital, Pin: -1, Port: e.Port, Raw: int(e.Value)}
+				for i := range v.Levels {
+					v.Levels[i] = e.Value&(1<<uint(i)) != 0
+				}
+			case AnalogEvent:
+				v = FirmataValue{Kind: KindAnalog, Pin: e.Pin, Channel: e.Channel, Raw: e.Value}
+			default:
+				return true
+			}
+			select {
+			case ch <- v:
+				return true
+			default:
+				return false
+			}
+		},
+		close: func() { close(ch) },
+	})
+	return ch
+}
+
+// markInited signals the end of the handshake. It is safe to call it
+// more than once.
+func (c *Client) markInited() {
+	c.initOnce.Do(func() { close(c.inited) })
+}
+
+// replyReader starts the goroutine reading from the board. Read errors
+// are published as ErrorEvents. The reader fails over to the standby
+// connection or reconnects if the client is configured to, and
+// otherwise stops and closes c.done.
 func (c *Client) replyReader() chan struct{} {
-	done := make(chan struct{})
+	c.inited = make(chan struct{})
+	c.done = make(chan struct{})
 
 	go func() {
-		r := bufio.NewReader(c.conn)
+		defer close(c.done)
+		c.connMu.Lock()
+		conn, gen := c.conn, c.connGen
+		c.connMu.Unlock()
 
-		var init bool
+		// Messages received before the board reports its version are
+		// leftovers from an earlier session.
+		init := false
 		for {
-			b, err := r.ReadByte()
-			if err != nil {
-				// TODO(jbd): Handle error somehow
-				panic(err)
+			err := c.readFrom(conn, &init)
+			if next, ok := c.swapped(&gen); ok {
+				conn = next
+				continue
 			}
-			cmd := FirmataCommand(b)
-			if !init {
-				if cmd != ReportVersion {
-					continue
-				} else {
-					init = true
-				}
+			c.readErr = err
+			c.bus.publish(ErrorEvent{Header{c.clock.Now()}, err})
+			if conn = c.failover(&gen, err); conn != nil {
+				continue
 			}
+			if conn = c.reconnect(&gen); conn == nil {
+				return
+			}
+		}
+	}()
+	return c.inited
+}
 
-			switch {
-			case cmd == ReportVersion:
-				c.protocolVersion = make([]byte, 2)
-				c.protocolVersion[0], err = r.ReadByte()
-				c.protocolVersion[1], err = r.ReadByte()
-			case cmd == StartSysEx:
-				var sysExData []byte
-				sysExData, err = r.ReadSlice(byte(EndSysEx))
-				if err == nil {
-					c.parseSysEx(sysExData[0 : len(sysExData)-1])
-					if c.analogMappingDone && c.capabilityDone {
-						close(done)
-					}
-				}
-			case (cmd&DigitalMessage) > 0 || byte(cmd&AnalogMessage) > 0:
-				b1, _ := r.ReadByte()
-				b2, _ := r.ReadByte()
-				c.valueChan <- FirmataValue{cmd, int(from7Bit(b1, b2)), c.analogChannelPinsMap}
+// readFrom handles the messages read from conn until it fails.
+func (c *Client) readFrom(conn io.Reader, init *bool) error {
+	d := wire.NewDecoder(countingReader{conn, &c.counters.bytesReceived})
+	d.SetLimits(c.limits)
+	d.SetStats(&c.parserStats)
+	last := [2]uint64{c.parserStats.Truncated.Load(), c.parserStats.Overflows.Load()}
+	for {
+		m, err := d.Decode()
+		c.diagnoseFrames(&last)
+		if err != nil {
+			return err
+		}
+		c.counters.count(&c.counters.received, messageType(m))
+		if !*init {
+			if _, ok := m.(wire.Version); !ok {
+				continue
 			}
+			*init = true
+		}
+		c.handle(m)
+	}
+}
+
+// handle processes a message from the board. A panic while handling it,
+// for instance in a HandleSysEx callback, is reported as an ErrorEvent
+// rather than crashing the program.
+func (c *Client) handle(m wire.Message) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.bus.publish(ErrorEvent{Header{c.clock.Now()}, fmt.Errorf("handling %v: %v", m, r)})
 		}
 	}()
-	return done
+
+	switch m := m.(type) {
+	case wire.Version:
+		c.protocolVersion = []byte{m.Major, m.Minor}
+		if c.versionQueried.Swap(false) {
+			// A reply to a query.
+			break
+		}
+		if c.isInited() {
+			c.suspectReset()
+		}
+	case wire.SysEx:
+		c.parseSysEx(m)
+		if c.analogMappingDone && c.capabilityDone {
+			c.markInited()
+		}
+	case wire.Digital:
+		port := m.Port & 0x0F
+		if c.capabilityDone && int(port)*8 >= len(c.board().modes) {
+			c.diagnose(PinOutOfRange, "report of digital port %d", port)
+		}
+		c.stateMu.Lock()
+		changed := c.digitalInputState[port] ^ m.Value
+		c.digitalInputState[port] = m.Value
+		initial := c.initialMask(port)
+		c.stateMu.Unlock()
+		ev := digitalEvents.Get().(*DigitalEvent)
+		*ev = DigitalEvent{Header: Header{c.eventTime()}, Port: port, Value: m.Value, Changed: changed}
+		if initial != 0 {
+			ev.Changed |= initial
+			ev.Initial = true
+		}
+		c.bus.publish(ev)
+		digitalEvents.Put(ev)
+	case wire.Analog:
+		pin, ok := c.board().pins[m.Channel]
+		if !ok {
+			pin = -1
+			if c.analogMappingDone {
+				c.diagnose(PinOutOfRange, "report of unmapped analog channel %d", m.Channel)
+			}
+		}
+		ev := analogEvents.Get().(*AnalogEvent)
+		*ev = AnalogEvent{Header: Header{c.eventTime()}, Pin: pin, Channel: m.Channel, Value: int(m.Value)}
+		if c.transform(ev) {
+			c.bus.publish(ev)
+			c.checkSlope(*ev)
+		}
+		analogEvents.Put(ev)
+	}
 }
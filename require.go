@@ -0,0 +1,56 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RequireFeatures reports an error listing the modes no pin of the board
+// supports, such as I2C or Servo, so programs can fail fast on a board
+// with the wrong firmware instead of having commands silently ignored.
+func (c *Client) RequireFeatures(modes ...PinMode) error {
+	b := c.board()
+	var missing []string
+	for _, mode := range modes {
+		found := false
+		for _, pm := range b.modes {
+			if pm[mode] != nil {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, mode.String())
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	fw := "the firmware"
+	if len(c.firmwareVersion) == 2 {
+		fw = fmt.Sprintf("firmware %s %d.%d", c.firmwareName, c.firmwareVersion[0], c.firmwareVersion[1])
+	}
+	return fmt.Errorf("%s does not support %s", fw, strings.Join(missing, ", "))
+}
+
+// WithRequiredFeatures makes the client check RequireFeatures at the
+// end of the handshake and fail to connect if a mode is missing.
+func WithRequiredFeatures(modes ...PinMode) Option {
+	return func(c *Client) {
+		c.required = append(c.required, modes...)
+	}
+}
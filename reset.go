@@ -0,0 +1,84 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"context"
+	"time"
+)
+
+// resetWindow is how long a version report nobody asked for waits for
+// the firmware report that boards send after it when they start.
+const resetWindow = 500 * time.Millisecond
+
+// suspectReset handles a version report after the handshake that
+// answers no query of the client. Boards send one when they restart,
+// but on a shared link it may answer the query of a peer, so the
+// configuration is only replayed once the reset is corroborated: by an
+// unsolicited firmware report within resetWindow or, failing that, by a
+// pin that lost the mode the client set or a pin state query the board
+// doesn't answer.
+func (c *Client) suspectReset() {
+	if c.resetSuspected.Swap(true) {
+		return
+	}
+	go func() {
+		select {
+		case <-c.clock.After(resetWindow):
+		case <-c.done:
+			return
+		}
+		if !c.resetSuspected.Swap(false) {
+			// Corroborated by the firmware report.
+			return
+		}
+		if pin, ok := c.lostPin(); ok {
+			c.confirmReset("board reported its version and pin %d lost its mode", pin)
+		}
+	}()
+}
+
+// confirmReset reports a reset of the board and replays the
+// configuration.
+func (c *Client) confirmReset(format string, args ...interface{}) {
+	c.diagnose(UnexpectedReset, format, args...)
+	go c.replayAfterReset()
+}
+
+// lostPin queries the state of the lowest pin the client set the mode
+// of, and returns it if the board doesn't have that mode anymore or
+// doesn't answer.
+func (c *Client) lostPin() (uint8, bool) {
+	c.stateMu.Lock()
+	var pin uint8
+	var mode PinMode
+	found := false
+	for p, m := range c.modes {
+		if !found || p < pin {
+			pin, mode, found = p, m, true
+		}
+	}
+	c.stateMu.Unlock()
+	if !found {
+		return 0, false
+	}
+	st, err := c.QueryPinState(context.Background(), pin)
+	select {
+	case <-c.done:
+		return 0, false
+	default:
+	}
+	return pin, err != nil || st.Mode != mode
+}
@@ -0,0 +1,123 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// waitReset reports whether the client diagnoses a reset within d.
+func waitReset(diag <-chan firmata.DiagnosticEvent, d time.Duration) bool {
+	timeout := time.After(d)
+	for {
+		select {
+		case ev := <-diag:
+			if ev.Kind == firmata.UnexpectedReset {
+				return true
+			}
+		case <-timeout:
+			return false
+		}
+	}
+}
+
+// replayed reports whether the client sets the mode of pin within d.
+func replayed(r *recorder, pin uint8, d time.Duration) bool {
+	deadline := time.Now().Add(d)
+	for time.Now().Before(deadline) {
+		for _, m := range r.messages() {
+			if pm, ok := m.(wire.PinMode); ok && pm.Pin == pin {
+				return true
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestReset(t *testing.T) {
+	tests := []struct {
+		name  string
+		board func(r *recorder) // what the board does after the handshake
+		reset bool
+	}{
+		{
+			name: "version and firmware",
+			board: func(r *recorder) {
+				// The simulator answers a reset like a restarting board.
+				r.Board.Write(wire.Reset{}.Bytes())
+			},
+			reset: true,
+		},
+		{
+			name: "version alone",
+			board: func(r *recorder) {
+				r.Inject(wire.Version{Major: 2, Minor: 5}.Bytes())
+			},
+		},
+		{
+			name: "version and lost pin mode",
+			board: func(r *recorder) {
+				r.Board.Write(wire.PinMode{Pin: 13, Mode: byte(firmata.Output)}.Bytes())
+				r.Inject(wire.Version{Major: 2, Minor: 5}.Bytes())
+			},
+			reset: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRecorder()
+			c, err := firmata.NewClientConn(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			diag := c.Diagnostics()
+			if err := c.SetPinMode(13, firmata.Input); err != nil {
+				t.Fatal(err)
+			}
+			r.messages()
+
+			tt.board(r)
+			if got := waitReset(diag, time.Second); got != tt.reset {
+				t.Fatalf("reset diagnosed = %v, want %v", got, tt.reset)
+			}
+			if got := replayed(r, 13, 500*time.Millisecond); got != tt.reset {
+				t.Errorf("configuration replayed = %v, want %v", got, tt.reset)
+			}
+		})
+	}
+}
+
+func TestQueriedVersionIsNotReset(t *testing.T) {
+	r := newRecorder()
+	c, err := firmata.NewClientConn(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	diag := c.Diagnostics()
+	if _, err := c.QueryFirmware(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if waitReset(diag, time.Second) {
+		t.Error("reply to a firmware query taken for a reset")
+	}
+}
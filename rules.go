@@ -0,0 +1,170 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"sync"
+	"time"
+)
+
+// ruleState holds the latest values seen by a rule.
+type ruleState struct {
+	ports  Ports
+	analog map[int]int
+}
+
+// Condition is a predicate over the latest pin values, built with Pin
+// and combined with And, Or and Not.
+type Condition struct {
+	eval func(s *ruleState) bool
+}
+
+// And returns a condition that holds when both c and d hold.
+func (c Condition) And(d Condition) Condition {
+	return Condition{func(s *ruleState) bool { return c.eval(s) && d.eval(s) }}
+}
+
+// Or returns a condition that holds when c or d holds.
+func (c Condition) Or(d Condition) Condition {
+	return Condition{func(s *ruleState) bool { return c.eval(s) || d.eval(s) }}
+}
+
+// Not returns a condition that holds when c doesn't.
+func (c Condition) Not() Condition {
+	return Condition{func(s *ruleState) bool { return !c.eval(s) }}
+}
+
+// PinRef refers to a pin in conditions.
+type PinRef int
+
+// Pin refers to pin in conditions, as in Pin(2).IsHigh() or
+// Pin(14).Above(600).
+func Pin(pin int) PinRef {
+	return PinRef(pin)
+}
+
+// IsHigh holds while the digital pin is high.
+func (p PinRef) IsHigh() Condition {
+	return Condition{func(s *ruleState) bool { return s.ports.High(int(p)) }}
+}
+
+// IsLow holds while the digital pin is low.
+func (p PinRef) IsLow() Condition {
+	return p.IsHigh().Not()
+}
+
+// Above holds while the analog pin reads more than v. It doesn't hold
+// before the first reading.
+func (p PinRef) Above(v int) Condition {
+	return Condition{func(s *ruleState) bool {
+		cur, ok := s.analog[int(p)]
+		return ok && cur > v
+	}}
+}
+
+// Below holds while the analog pin reads less than v. It doesn't hold
+// before the first reading.
+func (p PinRef) Below(v int) Condition {
+	return Condition{func(s *ruleState) bool {
+		cur, ok := s.analog[int(p)]
+		return ok && cur < v
+	}}
+}
+
+// Rule runs an action when a condition holds, built with When.
+type Rule struct {
+	c    *Client
+	cond Condition
+	hold time.Duration
+}
+
+// When starts a rule on cond:
+//
+//	c.When(firmata.Pin(14).Above(600)).And(firmata.Pin(2).IsHigh()).For(2 * time.Second).Do(fn)
+func (c *Client) When(cond Condition) *Rule {
+	return &Rule{c: c, cond: cond}
+}
+
+// And adds a condition that must hold too.
+func (r *Rule) And(cond Condition) *Rule {
+	r.cond = r.cond.And(cond)
+	return r
+}
+
+// For makes the rule wait until the condition held for d.
+func (r *Rule) For(d time.Duration) *Rule {
+	r.hold = d
+	return r
+}
+
+// Do evaluates the rule against the events of the board and calls fn,
+// on its own goroutine, each time the condition starts to hold, or has
+// held for the duration given to For. It returns a function that stops
+// the rule.
+func (r *Rule) Do(fn func()) (stop func()) {
+	events := Subscribe[Event](r.c, Filter{Types: []Event{DigitalEvent{}, AnalogEvent{}}})
+	done := make(chan struct{})
+	go func() {
+		s := &ruleState{ports: r.c.PortSnapshot(), analog: make(map[int]int)}
+		var timer <-chan time.Time
+		holding, fired := false, false
+		for {
+			select {
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+				switch e := ev.(type) {
+				case DigitalEvent:
+					s.ports[e.Port&0x0F] = e.Value
+				case AnalogEvent:
+					s.analog[e.Pin] = e.Value
+				}
+			case <-timer:
+				timer = nil
+				if holding && !fired {
+					fired = true
+					go fn()
+				}
+				continue
+			case <-done:
+				return
+			}
+
+			if !r.cond.eval(s) {
+				holding, fired, timer = false, false, nil
+				continue
+			}
+			if holding {
+				continue
+			}
+			holding = true
+			if r.hold > 0 {
+				timer = r.c.clock.After(r.hold)
+				continue
+			}
+			fired = true
+			go fn()
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			Unsubscribe(r.c, events)
+		})
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// SafeState is the SysEx command of the keep-alive extension found in
+// contrib/SafeState. The firmware drives the registered pins to their
+// safe levels when no ping arrives within the keep-alive timeout.
+const SafeState SysExCommand = 0x0B
+
+// Subcommands of SafeState.
+const (
+	safeStateSet     = 0x01
+	safeStateTimeout = 0x02
+	safeStatePing    = 0x03
+)
+
+// SetSafeState registers the level digital output pin is driven to
+// when the client is closed and, with firmware that supports SafeState,
+// when the host stops sending keep-alive pings.
+func (c *Client) SetSafeState(pin uint8, high bool) error {
+	if err := c.checkPin(int(pin)); err != nil {
+		return err
+	}
+	c.stateMu.Lock()
+	if c.safeStates == nil {
+		c.safeStates = make(map[uint8]bool)
+	}
+	c.safeStates[pin] = high
+	keepAlive := c.keepAlive
+	c.stateMu.Unlock()
+
+	if !keepAlive {
+		return nil
+	}
+	var v byte
+	if high {
+		v = 1
+	}
+	m := wire.SysEx{Command: byte(SafeState), Data: []byte{safeStateSet, pin, v}}
+	return c.sendConfig(fmt.Sprintf("safestate/%d", pin), m)
+}
+
+// KeepAlive makes the firmware apply the safe states if it hears nothing
+// from the host for timeout, and pings it often enough until the
+// connection is closed. It requires the SafeState firmware extension.
+func (c *Client) KeepAlive(timeout time.Duration) error {
+	c.stateMu.Lock()
+	states := make(map[uint8]bool, len(c.safeStates))
+	for pin, high := range c.safeStates {
+		states[pin] = high
+	}
+	started := c.keepAlive
+	c.keepAlive = true
+	c.stateMu.Unlock()
+
+	for pin, high := range states {
+		var v byte
+		if high {
+			v = 1
+		}
+		m := wire.SysEx{Command: byte(SafeState), Data: []byte{safeStateSet, pin, v}}
+		if err := c.sendConfig(fmt.Sprintf("safestate/%d", pin), m); err != nil {
+			return err
+		}
+	}
+	ms := wire.IntTo7Bit(int(timeout / time.Millisecond))
+	m := wire.SysEx{Command: byte(SafeState), Data: []byte{safeStateTimeout, ms[0], ms[1], ms[2]}}
+	if err := c.sendConfig("keepalive", m); err != nil {
+		return err
+	}
+	if started {
+		return nil
+	}
+	go func() {
+		for {
+			select {
+			case <-c.clock.After(timeout / 3):
+				c.sendSysEx(SafeState, safeStatePing)
+			case <-c.done:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// safeStateConfirmTimeout bounds the time spent confirming the safe states
+// when the client is closed.
+const safeStateConfirmTimeout = 2 * time.Second
+
+// writeSafeStates drives the pins registered with SetSafeState to their
+// safe levels, confirming the writes as critical ones while the board
+// is connected.
+func (c *Client) writeSafeStates() error {
+	c.stateMu.Lock()
+	states := make(map[uint8]bool, len(c.safeStates))
+	for pin, high := range c.safeStates {
+		states[pin] = high
+	}
+	c.stateMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), safeStateConfirmTimeout)
+	defer cancel()
+	var err error
+	for pin, high := range states {
+		pin, high := pin, high
+		write := func() error { return c.digitalWrite(pin, high) }
+		werr := write()
+		if werr == nil && !c.readerDone() {
+			v := 0
+			if high {
+				v = 1
+			}
+			werr = c.confirm(ctx, pin, v, write)
+		}
+		if werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+// readerDone reports whether the goroutine reading from the board
+// stopped.
+func (c *Client) readerDone() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// Scheduler subcommands.
+const (
+	schedCreateTask = 0x00
+	schedDeleteTask = 0x01
+	schedAddToTask  = 0x02
+	schedDelayTask  = 0x03
+	schedSchedule   = 0x04
+	schedQueryAll   = 0x05
+	schedQueryTask  = 0x06
+	schedReset      = 0x07
+
+	schedErrorReply    = 0x08
+	schedQueryAllReply = 0x09
+	schedQueryReply    = 0x0A
+)
+
+// allTasks is the query id of the list of tasks.
+const allTasks = -1
+
+// Task is a sequence of commands stored and run by the scheduler of
+// the board, so its timing doesn't depend on the link to the host.
+// It needs firmware with the Firmata scheduler, such as
+// ConfigurableFirmata.
+type Task struct {
+	c  *Client
+	ID byte
+}
+
+// TaskInfo is the state of a task on the board.
+type TaskInfo struct {
+	ID byte
+
+	// Time is the board uptime at which the task runs next.
+	Time time.Duration
+
+	// Length is the size of the commands and Position the offset of
+	// the next one to run.
+	Length   int
+	Position int
+	Commands []byte
+}
+
+// TaskErrorEvent is published when a task failed on the board, for
+// instance because it holds an invalid command.
+type TaskErrorEvent struct {
+	Header
+	Task TaskInfo
+}
+
+// CreateTask allocates a task of length bytes of commands on the board.
+// The commands are then uploaded with Add and the task started with
+// Schedule.
+func (c *Client) CreateTask(length int) (*Task, error) {
+	if length <= 0 || length > 0x3FFF {
+		return nil, errors.New("firmata: invalid task length")
+	}
+	t := &Task{c: c, ID: c.newTaskID()}
+	if err := c.sendSysEx(Scheduler, append([]byte{schedCreateTask, t.ID}, wire.EncodeUint(uint64(length), 2)...)...); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Add appends commands, encoded Firmata messages, to the task.
+func (t *Task) Add(commands []byte) error {
+	// Keep each SysEx message small enough for the input buffer of
+	// the firmware.
+	const chunk = 28
+	for i := 0; i < len(commands); i += chunk {
+		end := i + chunk
+		if end > len(commands) {
+			end = len(commands)
+		}
+		data := append([]byte{schedAddToTask, t.ID}, wire.Encode7BitStream(commands[i:end])...)
+		if err := t.c.sendSysEx(Scheduler, data...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Schedule runs the task after delay. A task that ends with a delay
+// command, see DelayCommand, starts over after it; other tasks run
+// once.
+func (t *Task) Schedule(delay time.Duration) error {
+	data := append([]byte{schedSchedule, t.ID}, encodeTime(uint32(delay/time.Millisecond))...)
+	return t.c.sendSysEx(Scheduler, data...)
+}
+
+// Query asks the board for the state of the task.
+func (t *Task) Query(ctx context.Context) (TaskInfo, error) {
+	v, err := t.c.query(ctx, queryKey{Scheduler, int(t.ID)}, func() error {
+		return t.c.sendSysEx(Scheduler, schedQueryTask, t.ID)
+	})
+	if err != nil {
+		return TaskInfo{}, err
+	}
+	info, ok := v.(TaskInfo)
+	if !ok {
+		return TaskInfo{}, fmt.Errorf("no task %d on the board", t.ID)
+	}
+	return info, nil
+}
+
+// Delete stops the task and frees it on the board.
+func (t *Task) Delete() error {
+	return t.c.sendSysEx(Scheduler, schedDeleteTask, t.ID)
+}
+
+// Tasks asks the board for the tasks it holds.
+func (c *Client) Tasks(ctx context.Context) ([]*Task, error) {
+	v, err := c.query(ctx, queryKey{Scheduler, allTasks}, func() error {
+		return c.sendSysEx(Scheduler, schedQueryAll)
+	})
+	if err != nil {
+		return nil, err
+	}
+	ids := v.([]byte)
+	tasks := make([]*Task, len(ids))
+	for i, id := range ids {
+		tasks[i] = &Task{c: c, ID: id}
+	}
+	return tasks, nil
+}
+
+// ResetTasks deletes all the tasks on the board.
+func (c *Client) ResetTasks() error {
+	return c.sendSysEx(Scheduler, schedReset)
+}
+
+// DelayCommand is the command that suspends a running task for d, with
+// millisecond resolution.
+func DelayCommand(d time.Duration) []byte {
+	return delayCommand(d)
+}
+
+func (c *Client) parseScheduler(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	switch data[0] {
+	case schedQueryAllReply:
+		c.pending.resolve(queryKey{Scheduler, allTasks}, append([]byte(nil), data[1:]...))
+	case schedQueryReply, schedErrorReply:
+		if len(data) < 2 {
+			return
+		}
+		id := data[1]
+		info, ok := parseTaskInfo(id, data[2:])
+		if data[0] == schedErrorReply {
+			c.bus.publish(TaskErrorEvent{Header{c.eventTime()}, info})
+			return
+		}
+		if !ok {
+			// The board has no such task.
+			c.pending.resolve(queryKey{Scheduler, int(id)}, nil)
+			return
+		}
+		c.pending.resolve(queryKey{Scheduler, int(id)}, info)
+	}
+}
+
+// parseTaskInfo decodes the time, length, position and commands of a
+// task. It reports false if data is too short.
+func parseTaskInfo(id byte, data []byte) (TaskInfo, bool) {
+	b := wire.Decode7BitStream(data)
+	if len(b) < 8 {
+		return TaskInfo{ID: id}, false
+	}
+	return TaskInfo{
+		ID:       id,
+		Time:     time.Duration(binary.LittleEndian.Uint32(b)) * time.Millisecond,
+		Length:   int(binary.LittleEndian.Uint16(b[4:])),
+		Position: int(binary.LittleEndian.Uint16(b[6:])),
+		Commands: b[8:],
+	}, true
+}
+
+// encodeTime encodes a scheduler time in milliseconds.
+func encodeTime(ms uint32) []byte {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], ms)
+	return wire.Encode7BitStream(b[:])
+}
+
+// delayCommand is the command that suspends a running task for d.
+func delayCommand(d time.Duration) []byte {
+	m := wire.SysEx{Command: byte(Scheduler), Data: append([]byte{schedDelayTask}, encodeTime(uint32(d/time.Millisecond))...)}
+	return m.Bytes()
+}
+
+// newTaskID returns an id for a new task. Ids are reused once all 128
+// were handed out.
+func (c *Client) newTaskID() byte {
+	c.sysExMu.Lock()
+	defer c.sysExMu.Unlock()
+	id := c.nextTask & 0x7F
+	c.nextTask++
+	return id
+}
+
+// createTask uploads the commands of task id and starts it after delay.
+func (c *Client) createTask(id byte, commands []byte, delay time.Duration) (*Task, error) {
+	if len(commands) > 0x3FFF {
+		return nil, errors.New("firmata: task too long")
+	}
+	t := &Task{c: c, ID: id}
+	if err := c.sendSysEx(Scheduler, append([]byte{schedCreateTask, t.ID}, wire.EncodeUint(uint64(len(commands)), 2)...)...); err != nil {
+		return nil, err
+	}
+	if err := t.Add(commands); err != nil {
+		return nil, err
+	}
+	if err := t.Schedule(delay); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// PulseTrain toggles the digital output pin on the board itself: it is
+// driven high for periods[0], low for periods[1], high for periods[2]
+// and so on, and the sequence is played repeat times, or forever if
+// repeat is zero, until the returned task is deleted. The pin is left
+// low at the end. Periods have millisecond resolution.
+func (c *Client) PulseTrain(pin uint8, periods []time.Duration, repeat int) (*Task, error) {
+	if err := c.checkPin(int(pin)); err != nil {
+		return nil, err
+	}
+	c.stateMu.Lock()
+	mode := c.modes[pin]
+	c.stateMu.Unlock()
+	if mode != Output {
+		return nil, fmt.Errorf("pin %v is not a digital output", pin)
+	}
+	if len(periods) == 0 || repeat < 0 {
+		return nil, errors.New("firmata: invalid pulse train")
+	}
+	var train []byte
+	for i, p := range periods {
+		if p < time.Millisecond {
+			return nil, errors.New("firmata: pulse periods must be at least 1ms")
+		}
+		train = append(train, wire.DigitalPin{Pin: pin, Value: i%2 == 0}.Bytes()...)
+		train = append(train, delayCommand(p)...)
+	}
+
+	id := c.newTaskID()
+	var commands []byte
+	if repeat == 0 {
+		// A task that delays itself starts over when it reaches its
+		// end.
+		commands = train
+	} else {
+		for i := 0; i < repeat; i++ {
+			commands = append(commands, train...)
+		}
+		commands = append(commands, wire.DigitalPin{Pin: pin, Value: false}.Bytes()...)
+		commands = append(commands, wire.SysEx{Command: byte(Scheduler), Data: []byte{schedDeleteTask, id}}.Bytes()...)
+	}
+	return c.createTask(id, commands, 0)
+}
@@ -0,0 +1,132 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !js
+
+package firmata
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/rakyll/go-firmata/wire"
+	"github.com/tarm/serial"
+)
+
+// NewClient creates a new Client and connects to the Arduino board
+// over specified serial port. It blocks till a connection is
+// succesfully established and pin mappings are retrieved.
+func NewClient(dev string, baud int, opts ...Option) (*Client, error) {
+	var r rediscovery
+	dial := func() (io.ReadWriteCloser, error) {
+		return r.open(dev, baud)
+	}
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	client, err := NewClientConn(conn, append([]Option{WithDialer(dial)}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	client.dev = dev
+	client.baud = baud
+	if err := client.checkSerial(dev); err != nil {
+		client.Close()
+		return nil, err
+	}
+	if client.rediscover {
+		serial, err := usbSerial(dev)
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("cannot rediscover %v: %v", dev, err)
+		}
+		r.setSerial(serial)
+	}
+	return client, nil
+}
+
+// WithPortRediscovery makes a client created by NewClient look for its
+// board by USB serial number when reconnecting fails to open the port,
+// in case the board came back on another one. Windows often assigns a
+// new COM port to a board that was reset or plugged back in. It needs
+// WithReconnect to take effect.
+func WithPortRediscovery() Option {
+	return func(c *Client) {
+		c.rediscover = true
+	}
+}
+
+// rediscovery opens the serial port of a board, found again by its USB
+// serial number if it moved.
+type rediscovery struct {
+	mu     sync.Mutex
+	serial string // empty until known
+	port   string // last port found, if it moved
+}
+
+func (r *rediscovery) setSerial(serial string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.serial = serial
+}
+
+func (r *rediscovery) open(dev string, baud int) (io.ReadWriteCloser, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.port != "" {
+		dev = r.port
+	}
+	conn, err := serial.OpenPort(&serial.Config{Name: dev, Baud: baud})
+	if err == nil || r.serial == "" {
+		return conn, err
+	}
+	port, ferr := findUSBPort(r.serial)
+	if ferr != nil || port == dev {
+		return nil, err
+	}
+	conn, err = serial.OpenPort(&serial.Config{Name: port, Baud: baud})
+	if err == nil {
+		r.port = port
+	}
+	return conn, err
+}
+
+// NewClientAnyBaud is like NewClient but tries each of bauds, or
+// CandidateBauds if none are given, until the handshake succeeds. A
+// baud rate that produces mostly invalid bytes is abandoned early.
+func NewClientAnyBaud(dev string, bauds []int, opts ...Option) (*Client, error) {
+	if len(bauds) == 0 {
+		bauds = CandidateBauds
+	}
+	// Fail fast on garbage, unless the caller set limits.
+	opts = append([]Option{WithParserLimits(wire.Limits{MaxSkip: 256})}, opts...)
+	var err error
+	for _, baud := range bauds {
+		var c *Client
+		c, err = NewClient(dev, baud, opts...)
+		if err == nil {
+			return c, nil
+		}
+		var perr *os.PathError
+		if errors.As(err, &perr) {
+			// The port cannot be opened, other rates won't help.
+			return nil, err
+		}
+	}
+	return nil, err
+}
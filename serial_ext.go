@@ -14,32 +14,122 @@
 
 package firmata
 
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
 type SerialSubCommand byte
 
-// Configure a builtin or soft serial port. This command must be called before sending serial data.
-// Set txPin and rxPin to 0x00 for builtin serial ports.
+// Modes of SerialRead.
+const (
+	serialReadContinuously = 0x00
+	serialStopReading      = 0x01
+)
+
+// SerialEvent is data received on a serial port of the board.
+type SerialEvent struct {
+	Header
+	Port SerialPort
+	Data []byte
+}
+
+// Configure a builtin or soft serial port and start reading from it.
+// This command must be called before sending serial data. txPin and
+// rxPin are only used by software serial ports.
 func (c *Client) SerialConfig(port SerialPort, baud int, txPin byte, rxPin byte) (err error) {
-	baudBytes := intto7Bit(baud)
-	bufferSize := intto7Bit(1024)
-	termChar := to7Bit('\n')
-	c.serialChan = make(chan string, 10)
+	if err := checkSerialPort(port); err != nil {
+		return err
+	}
+	data := append([]byte{byte(SerialConfig) | byte(port)}, wire.EncodeUint(uint64(baud), 3)...)
+	if port >= SoftSerial {
+		data = append(data, rxPin, txPin)
+	}
+	m := wire.SysEx{Command: byte(Serial), Data: data}
+	if err := c.sendConfig(fmt.Sprintf("serial/%d/", port), m); err != nil {
+		return err
+	}
+	return c.SerialRead(port, true)
+}
 
-	err = c.sendSysEx(Serial, byte(SerialConfig)|byte(port),
-		baudBytes[0], baudBytes[1], baudBytes[2],
-		bufferSize[0], bufferSize[1], bufferSize[2],
-		termChar[0], termChar[1])
-	return
+// SerialWrite sends data out of a serial port.
+func (c *Client) SerialWrite(port SerialPort, data []byte) error {
+	if err := checkSerialPort(port); err != nil {
+		return err
+	}
+	return c.sendSysEx(Serial, append([]byte{byte(SerialComm) | byte(port)}, wire.EncodeBytes(data)...)...)
 }
 
+// SerialRead starts or stops the continuous reading of a serial port.
+// Data read is published as SerialEvents.
+func (c *Client) SerialRead(port SerialPort, on bool) error {
+	if err := checkSerialPort(port); err != nil {
+		return err
+	}
+	mode := byte(serialStopReading)
+	if on {
+		mode = serialReadContinuously
+	}
+	m := wire.SysEx{Command: byte(Serial), Data: []byte{byte(SerialRead) | byte(port), mode}}
+	return c.sendConfig(fmt.Sprintf("serial-read/%d/", port), m)
+}
+
+// SerialFlush discards the data waiting in the buffers of a serial port.
+func (c *Client) SerialFlush(port SerialPort) error {
+	if err := checkSerialPort(port); err != nil {
+		return err
+	}
+	return c.sendSysEx(Serial, byte(SerialFlush)|byte(port))
+}
+
+// SerialClose stops reading and closes a serial port.
+func (c *Client) SerialClose(port SerialPort) error {
+	if err := checkSerialPort(port); err != nil {
+		return err
+	}
+	c.journal.forget(fmt.Sprintf("serial/%d/", port))
+	c.journal.forget(fmt.Sprintf("serial-read/%d/", port))
+	return c.sendSysEx(Serial, byte(SerialClose)|byte(port))
+}
+
+// SerialData returns the channel of the data received on all serial
+// ports. All callers share the same channel. Data is dropped when it is
+// full; subscribe to SerialEvents to tell the ports apart.
 func (c *Client) SerialData() <-chan string {
+	c.serialOnce.Do(func() {
+		ch := make(chan string, subscriptionBuffer)
+		c.bus.add(&subscription{
+			key:    (<-chan string)(ch),
+			filter: Filter{Types: []Event{SerialEvent{}}},
+			deliver: func(ev Event) bool {
+				select {
+				case ch <- string(ev.(SerialEvent).Data):
+					return true
+				default:
+					return false
+				}
+			},
+			close: func() { close(ch) },
+		})
+		c.serialChan = ch
+	})
 	return c.serialChan
 }
 
 func (c *Client) parseSerialResponse(data7bit []byte) {
-	// TODO(jbd): Make the byte slice with the right length.
-	data := make([]byte, 0)
-	for i := 1; i < len(data7bit); i = i + 2 {
-		data = append(data, byte(from7Bit(data7bit[i], data7bit[i+1])))
+	if len(data7bit) == 0 || SerialSubCommand(data7bit[0]&0xF0) != SerialReply {
+		return
+	}
+	port := SerialPort(data7bit[0] & 0x0F)
+	data := wire.DecodeBytes(data7bit[1:])
+	c.bus.publish(SerialEvent{Header{c.eventTime()}, port, data})
+}
+
+func checkSerialPort(port SerialPort) error {
+	if port > 0x0F {
+		return errors.New("firmata: invalid serial port")
 	}
-	c.serialChan <- string(data)
+	return nil
 }
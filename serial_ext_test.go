@@ -0,0 +1,105 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata_test
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/simulator"
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// recorder is a simulated board that keeps what the client writes.
+type recorder struct {
+	*simulator.Board
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func newRecorder() *recorder {
+	return &recorder{Board: simulator.New(nil)}
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	r.buf.Write(p)
+	r.mu.Unlock()
+	return r.Board.Write(p)
+}
+
+// messages returns the messages written since the last call.
+func (r *recorder) messages() []wire.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d := wire.NewDecoder(bytes.NewReader(r.buf.Bytes()))
+	r.buf.Reset()
+	var msgs []wire.Message
+	for {
+		m, err := d.Decode()
+		if err != nil {
+			return msgs
+		}
+		msgs = append(msgs, m)
+	}
+}
+
+func TestSerialCloseKeepsOtherPorts(t *testing.T) {
+	r := newRecorder()
+	c, err := firmata.NewClientConn(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.SerialConfig(firmata.HardSerial1, 9600, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SerialConfig(firmata.SoftSerial2, 9600, 10, 11); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SerialClose(firmata.HardSerial1); err != nil {
+		t.Fatal(err)
+	}
+	r.messages()
+	if err := c.Replay(); err != nil {
+		t.Fatal(err)
+	}
+
+	configured := make(map[firmata.SerialPort]bool)
+	reading := make(map[firmata.SerialPort]bool)
+	for _, m := range r.messages() {
+		s, ok := m.(wire.SysEx)
+		if !ok || s.Command != byte(firmata.Serial) || len(s.Data) == 0 {
+			continue
+		}
+		port := firmata.SerialPort(s.Data[0] & 0x0F)
+		switch firmata.SerialSubCommand(s.Data[0] & 0xF0) {
+		case firmata.SerialConfig:
+			configured[port] = true
+		case firmata.SerialRead:
+			reading[port] = true
+		}
+	}
+	if !configured[firmata.SoftSerial2] || !reading[firmata.SoftSerial2] {
+		t.Errorf("port %v not replayed after closing port %v", firmata.SoftSerial2, firmata.HardSerial1)
+	}
+	if configured[firmata.HardSerial1] || reading[firmata.HardSerial1] {
+		t.Errorf("closed port %v replayed", firmata.HardSerial1)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// Default pulse widths of the Arduino Servo library, in microseconds.
+const (
+	DefaultServoMinPulse = 544
+	DefaultServoMaxPulse = 2400
+)
+
+// ServoConfig sets the pulse widths, in microseconds, the servo on pin
+// is driven with at 0 and 180 degrees, and sets the pin to servo mode.
+func (c *Client) ServoConfig(pin uint8, minPulse, maxPulse int) error {
+	if err := c.checkPin(int(pin)); err != nil {
+		return err
+	}
+	if !c.board().supports(int(pin), Servo) {
+		return fmt.Errorf("pin %v doesn't support servos", pin)
+	}
+	if minPulse <= 0 || maxPulse <= minPulse || maxPulse > 0x3FFF {
+		return errors.New("firmata: invalid servo pulse range")
+	}
+	data := append([]byte{pin & 0x7F}, wire.EncodeUint(uint64(minPulse), 2)...)
+	data = append(data, wire.EncodeUint(uint64(maxPulse), 2)...)
+	m := wire.SysEx{Command: byte(ServoConfig), Data: data}
+	// Recorded as the mode of the pin, so that replaying it restores
+	// both the range and servo mode, and a later mode replaces it.
+	if err := c.sendConfig(fmt.Sprintf("mode/%d", pin), m); err != nil {
+		return err
+	}
+	// The firmware attaches the servo and switches the pin to servo
+	// mode.
+	c.modeSet(pin, Servo)
+	return nil
+}
+
+// ServoWrite moves the servo on pin to angle, in degrees from 0 to 180,
+// setting the pin to servo mode first if needed.
+func (c *Client) ServoWrite(pin uint8, angle int) error {
+	if angle < 0 || angle > 180 {
+		return errors.New("firmata: servo angle out of range")
+	}
+	if err := c.checkPin(int(pin)); err != nil {
+		return err
+	}
+	c.stateMu.Lock()
+	mode, ok := c.modes[pin]
+	c.stateMu.Unlock()
+	if !ok || mode != Servo {
+		if err := c.SetPinMode(pin, Servo); err != nil {
+			return err
+		}
+	}
+	return c.AnalogWrite(uint(pin), byte(angle))
+}
@@ -0,0 +1,57 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// GracefulShutdown makes an interrupt or termination signal drive pins,
+// and the pins registered with SetSafeState, to their safe levels, close
+// c and exit the program, so Ctrl-C doesn't leave motors running. pins
+// without a registered safe state are driven low. It returns a function
+// that removes the handler.
+func GracefulShutdown(c *Client, pins ...uint8) (stop func()) {
+	for _, pin := range pins {
+		c.stateMu.Lock()
+		_, ok := c.safeStates[pin]
+		c.stateMu.Unlock()
+		if !ok {
+			c.SetSafeState(pin, false)
+		}
+	}
+
+	sig := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sig:
+			c.Close()
+			os.Exit(1)
+		case <-done:
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(sig)
+			close(done)
+		})
+	}
+}
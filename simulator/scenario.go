@@ -0,0 +1,222 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scenario is a script of timed events played on a Board. Scenarios are
+// written one step per line, each starting with its offset from the
+// start of the scenario:
+//
+//	# a button press, a noisy sensor and an unplugged cable
+//	seed 42
+//	100ms digital 2 1
+//	150ms digital 2 0
+//	200ms analog 14 512
+//	250ms analog 14 rand 500 524
+//	300ms delay 50ms
+//	400ms garbage 8
+//	1s error cable unplugged
+//
+// digital sets an input level, analog sets an analog reading, either
+// fixed or drawn uniformly from a range, delay sets how late the board
+// answers queries, garbage sends random bytes and error disconnects the
+// board. Random values come from the seed, so a scenario plays the same
+// way every time.
+type Scenario struct {
+	Seed  int64
+	Steps []Step
+}
+
+// Step is a single line of a Scenario.
+type Step struct {
+	At   time.Duration
+	Op   string
+	Args []string
+	Line int
+}
+
+// LoadScenario reads a scenario from a file.
+func LoadScenario(path string) (*Scenario, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseScenario(f)
+}
+
+// ParseScenario reads a scenario from r. Steps are sorted by time,
+// keeping the order of the file for steps at the same time.
+func ParseScenario(r io.Reader) (*Scenario, error) {
+	s := &Scenario{}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		f := strings.Fields(line)
+		if f[0] == "seed" {
+			if len(f) != 2 {
+				return nil, fmt.Errorf("line %d: seed needs a value", n)
+			}
+			seed, err := strconv.ParseInt(f[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			s.Seed = seed
+			continue
+		}
+		if len(f) < 2 {
+			return nil, fmt.Errorf("line %d: missing operation", n)
+		}
+		at, err := time.ParseDuration(f[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		step := Step{At: at, Op: f[1], Args: f[2:], Line: n}
+		if err := step.check(); err != nil {
+			return nil, err
+		}
+		s.Steps = append(s.Steps, step)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(s.Steps, func(i, j int) bool { return s.Steps[i].At < s.Steps[j].At })
+	return s, nil
+}
+
+func (s Step) check() error {
+	var want int
+	switch s.Op {
+	case "digital", "analog":
+		want = 2
+		if len(s.Args) == 4 && s.Op == "analog" && s.Args[1] == "rand" {
+			want = 4
+		}
+	case "delay", "garbage":
+		want = 1
+	case "error":
+		if len(s.Args) == 0 {
+			return fmt.Errorf("line %d: error needs a message", s.Line)
+		}
+		return nil
+	default:
+		return fmt.Errorf("line %d: unknown operation %q", s.Line, s.Op)
+	}
+	if len(s.Args) != want {
+		return fmt.Errorf("line %d: %s takes %d arguments", s.Line, s.Op, want)
+	}
+	if s.Op == "garbage" {
+		n, err := strconv.Atoi(s.Args[0])
+		if err != nil || n < 0 {
+			return fmt.Errorf("line %d: invalid garbage count %q", s.Line, s.Args[0])
+		}
+	}
+	return nil
+}
+
+// Run plays s on the board, sleeping on the board clock between steps,
+// and returns once the last step ran. With a fake clock, the test
+// drives the scenario by advancing the clock.
+func (b *Board) Run(s *Scenario) error {
+	rnd := rand.New(rand.NewSource(s.Seed))
+	var elapsed time.Duration
+	for _, step := range s.Steps {
+		if d := step.At - elapsed; d > 0 {
+			b.clock.Sleep(d)
+			elapsed = step.At
+		}
+		select {
+		case <-b.closed:
+			return errors.New("simulator: board closed")
+		default:
+		}
+		if err := b.apply(step, rnd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Board) apply(s Step, rnd *rand.Rand) error {
+	ints := func(args ...string) ([]int, error) {
+		vals := make([]int, len(args))
+		for i, a := range args {
+			v, err := strconv.Atoi(a)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", s.Line, err)
+			}
+			vals[i] = v
+		}
+		return vals, nil
+	}
+
+	switch s.Op {
+	case "digital":
+		v, err := ints(s.Args...)
+		if err != nil {
+			return err
+		}
+		b.SetDigital(v[0], v[1] != 0)
+	case "analog":
+		if len(s.Args) == 4 {
+			v, err := ints(s.Args[0], s.Args[2], s.Args[3])
+			if err != nil {
+				return err
+			}
+			if v[2] < v[1] {
+				return fmt.Errorf("line %d: empty range", s.Line)
+			}
+			b.SetAnalog(v[0], v[1]+rnd.Intn(v[2]-v[1]+1))
+			return nil
+		}
+		v, err := ints(s.Args...)
+		if err != nil {
+			return err
+		}
+		b.SetAnalog(v[0], v[1])
+	case "delay":
+		d, err := time.ParseDuration(s.Args[0])
+		if err != nil {
+			return fmt.Errorf("line %d: %v", s.Line, err)
+		}
+		b.SetReplyDelay(d)
+	case "garbage":
+		v, err := ints(s.Args...)
+		if err != nil {
+			return err
+		}
+		p := make([]byte, v[0])
+		rnd.Read(p)
+		b.Inject(p)
+	case "error":
+		b.Fail(errors.New(strings.Join(s.Args, " ")))
+	}
+	return nil
+}
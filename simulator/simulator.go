@@ -0,0 +1,481 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package simulator implements a simulated Firmata board for tests and
+// demos. A Board is an io.ReadWriteCloser that can be passed to
+// firmata.NewClientConn; it answers the handshake and queries like
+// StandardFirmata on an Arduino Uno, and its inputs are driven either
+// directly or by a Scenario.
+package simulator
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/rakyll/go-firmata"
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// Firmata pin modes used in the capability response.
+const (
+	modeInput  = 0x00
+	modeOutput = 0x01
+	modeAnalog = 0x02
+	modePWM    = 0x03
+	modeServo  = 0x04
+	modeI2C    = 0x06
+)
+
+// Board is a simulated Uno: digital pins 0 to 13, with PWM on 3, 5, 6, 9,
+// 10 and 11, and analog inputs 0 to 5 on pins 14 to 19, with I2C on 18
+// and 19.
+type Board struct {
+	// Firmware is the name reported in the handshake.
+	Firmware string
+
+	clock firmata.Clock
+
+	toBoard   *io.PipeReader
+	fromHost  *io.PipeWriter
+	toHost    *io.PipeReader
+	fromBoard *io.PipeWriter
+	out       chan []byte // queue of writes to the host
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	mu               sync.Mutex
+	modes            [20]byte
+	levels           [20]bool
+	analog           [6]int
+	outputs          [20]int
+	digitalReporting [16]bool
+	analogReporting  [16]bool
+	replyDelay       time.Duration
+	i2c              map[byte]map[int]byte
+	i2cReads         map[byte][][2]int // continuous reads: register, count
+}
+
+const pins = 20
+
+// New returns a simulated board measuring time with clock. A nil clock
+// uses the system clock.
+func New(clock firmata.Clock) *Board {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	b := &Board{
+		Firmware: "Simulator",
+		clock:    clock,
+		i2c:      make(map[byte]map[int]byte),
+		out:      make(chan []byte, 256),
+		closed:   make(chan struct{}),
+	}
+	b.toBoard, b.fromHost = io.Pipe()
+	b.toHost, b.fromBoard = io.Pipe()
+	go b.serve()
+	go b.write()
+	return b
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (systemClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+
+// Read reads the messages sent by the board.
+func (b *Board) Read(p []byte) (int, error) {
+	return b.toHost.Read(p)
+}
+
+// Write sends messages to the board.
+func (b *Board) Write(p []byte) (int, error) {
+	return b.fromHost.Write(p)
+}
+
+// Close disconnects the board.
+func (b *Board) Close() error {
+	b.Fail(nil)
+	return nil
+}
+
+// Fail disconnects the board as if it was unplugged: reads by the host
+// fail with err, or io.EOF if err is nil.
+func (b *Board) Fail(err error) {
+	b.closeOnce.Do(func() { close(b.closed) })
+	b.fromBoard.CloseWithError(err)
+	b.fromHost.CloseWithError(err)
+}
+
+// SetReplyDelay delays the replies to queries by d.
+func (b *Board) SetReplyDelay(d time.Duration) {
+	b.mu.Lock()
+	b.replyDelay = d
+	b.mu.Unlock()
+}
+
+// SetDigital sets the level of an input pin and reports its port if the
+// host enabled reporting.
+func (b *Board) SetDigital(pin int, high bool) {
+	if pin < 0 || pin >= pins {
+		return
+	}
+	b.mu.Lock()
+	b.levels[pin] = high
+	report := b.digitalReporting[pin/8]
+	value := b.portValue(pin / 8)
+	b.mu.Unlock()
+	if report {
+		b.send(wire.Digital{Port: byte(pin / 8), Value: value})
+	}
+}
+
+// SetAnalog sets the reading of analog pin, 14 to 19, and reports it if
+// the host enabled reporting.
+func (b *Board) SetAnalog(pin int, value int) {
+	ch := pin - 14
+	if ch < 0 || ch >= len(b.analog) {
+		return
+	}
+	b.mu.Lock()
+	b.analog[ch] = value
+	report := b.analogReporting[ch]
+	b.mu.Unlock()
+	if report {
+		b.send(wire.Analog{Channel: byte(ch), Value: uint16(value)})
+	}
+}
+
+// SetI2C sets the registers of the I2C device at addr starting at reg.
+// Continuous reads of the device are answered with the new values.
+func (b *Board) SetI2C(addr byte, reg int, data ...byte) {
+	b.mu.Lock()
+	regs := b.i2c[addr]
+	if regs == nil {
+		regs = make(map[int]byte)
+		b.i2c[addr] = regs
+	}
+	for i, v := range data {
+		regs[reg+i] = v
+	}
+	reads := b.i2cReads[addr]
+	b.mu.Unlock()
+	for _, r := range reads {
+		b.replyI2C(addr, r[0], r[1])
+	}
+}
+
+// Output returns the value last written by the host to pin: 0 or 1 for
+// digital outputs, the duty cycle or angle for PWM and servo pins.
+func (b *Board) Output(pin int) int {
+	if pin < 0 || pin >= pins {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.outputs[pin]
+}
+
+// Mode returns the mode the host set pin to.
+func (b *Board) Mode(pin int) firmata.PinMode {
+	if pin < 0 || pin >= pins {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return firmata.PinMode(b.modes[pin])
+}
+
+// Inject sends raw bytes to the host, for instance to test how it copes
+// with noise on the line.
+func (b *Board) Inject(p []byte) {
+	select {
+	case b.out <- p:
+	case <-b.closed:
+	}
+}
+
+// write copies the queued output to the host, so the board never
+// blocks on a host that is itself busy writing to the board.
+func (b *Board) write() {
+	for {
+		select {
+		case p := <-b.out:
+			if _, err := b.fromBoard.Write(p); err != nil {
+				return
+			}
+		case <-b.closed:
+			return
+		}
+	}
+}
+
+// portValue returns the pin levels of port. b.mu must be held.
+func (b *Board) portValue(port int) byte {
+	var v byte
+	for i := 0; i < 8; i++ {
+		pin := port*8 + i
+		if pin < pins && b.levels[pin] {
+			v |= 1 << uint(i)
+		}
+	}
+	return v
+}
+
+func (b *Board) send(m wire.Message) {
+	b.Inject(m.Bytes())
+}
+
+// reply sends a reply to a query after the configured delay.
+func (b *Board) reply(m wire.Message) {
+	b.mu.Lock()
+	d := b.replyDelay
+	b.mu.Unlock()
+	if d > 0 {
+		b.clock.Sleep(d)
+	}
+	b.send(m)
+}
+
+func (b *Board) serve() {
+	d := wire.NewDecoder(b.toBoard)
+	for {
+		m, err := d.Decode()
+		if err != nil {
+			return
+		}
+		b.handle(m)
+	}
+}
+
+func (b *Board) handle(m wire.Message) {
+	switch m := m.(type) {
+	case wire.Reset:
+		b.mu.Lock()
+		b.digitalReporting = [16]bool{}
+		b.analogReporting = [16]bool{}
+		b.i2cReads = nil
+		b.mu.Unlock()
+		b.send(wire.Version{Major: firmata.ProtocolMajorVersion, Minor: firmata.ProtocolMinorVersion})
+		b.reply(b.firmware())
+	case wire.Version:
+		b.send(wire.Version{Major: firmata.ProtocolMajorVersion, Minor: firmata.ProtocolMinorVersion})
+	case wire.PinMode:
+		if int(m.Pin) < pins {
+			b.mu.Lock()
+			b.modes[m.Pin] = m.Mode
+			b.mu.Unlock()
+		}
+	case wire.Digital:
+		b.mu.Lock()
+		for i := 0; i < 8; i++ {
+			pin := int(m.Port)*8 + i
+			if pin < pins && b.modes[pin] == modeOutput {
+				high := m.Value&(1<<uint(i)) != 0
+				b.levels[pin] = high
+				b.outputs[pin] = 0
+				if high {
+					b.outputs[pin] = 1
+				}
+			}
+		}
+		b.mu.Unlock()
+	case wire.DigitalPin:
+		b.mu.Lock()
+		if int(m.Pin) < pins && b.modes[m.Pin] == modeOutput {
+			b.levels[m.Pin] = m.Value
+			b.outputs[m.Pin] = 0
+			if m.Value {
+				b.outputs[m.Pin] = 1
+			}
+		}
+		b.mu.Unlock()
+	case wire.Analog:
+		if int(m.Channel) < pins {
+			b.mu.Lock()
+			b.outputs[m.Channel] = int(m.Value)
+			b.mu.Unlock()
+		}
+	case wire.DigitalReport:
+		b.mu.Lock()
+		b.digitalReporting[m.Port&0x0F] = m.Enable
+		value := b.portValue(int(m.Port))
+		b.mu.Unlock()
+		if m.Enable {
+			b.send(wire.Digital{Port: m.Port, Value: value})
+		}
+	case wire.AnalogReport:
+		ch := int(m.Channel & 0x0F)
+		b.mu.Lock()
+		b.analogReporting[ch] = m.Enable
+		value := 0
+		if ch < len(b.analog) {
+			value = b.analog[ch]
+		}
+		b.mu.Unlock()
+		if m.Enable && ch < len(b.analog) {
+			// Like the firmware, report a first sample right away.
+			b.send(wire.Analog{Channel: byte(ch), Value: uint16(value)})
+		}
+	case wire.SysEx:
+		b.handleSysEx(m)
+	}
+}
+
+func (b *Board) handleSysEx(m wire.SysEx) {
+	switch firmata.SysExCommand(m.Command) {
+	case firmata.ReportFirmware:
+		b.reply(b.firmware())
+	case firmata.ServoConfig:
+		if len(m.Data) >= 5 && int(m.Data[0]) < pins {
+			b.mu.Lock()
+			b.modes[m.Data[0]] = byte(firmata.Servo)
+			b.mu.Unlock()
+		}
+	case firmata.CapabilityQuery:
+		b.reply(wire.SysEx{Command: byte(firmata.CapabilityResponse), Data: capabilities()})
+	case firmata.AnalogMappingQuery:
+		data := make([]byte, pins)
+		for pin := range data {
+			data[pin] = 127
+			if pin >= 14 {
+				data[pin] = byte(pin - 14)
+			}
+		}
+		b.reply(wire.SysEx{Command: byte(firmata.AnalogMappingResponse), Data: data})
+	case firmata.PinStateQuery:
+		if len(m.Data) < 1 {
+			return
+		}
+		pin := int(m.Data[0])
+		if pin >= pins {
+			// Like StandardFirmata, answer with the pin alone.
+			b.reply(wire.SysEx{Command: byte(firmata.PinStateResponse), Data: []byte{byte(pin)}})
+			return
+		}
+		b.mu.Lock()
+		mode, state := b.modes[pin], b.outputs[pin]
+		b.mu.Unlock()
+		data := []byte{byte(pin), mode}
+		for {
+			data = append(data, byte(state&0x7F))
+			state >>= 7
+			if state == 0 {
+				break
+			}
+		}
+		b.reply(wire.SysEx{Command: byte(firmata.PinStateResponse), Data: data})
+	case firmata.I2CRequest:
+		b.handleI2C(m.Data)
+	case firmata.Echo:
+		b.reply(m)
+	}
+}
+
+// handleI2C answers I2C reads with the registers set with SetI2C, zero
+// for the others. Writes of a register address followed by data set the
+// registers. Continuous reads are answered when they start and whenever
+// SetI2C changes the device.
+func (b *Board) handleI2C(data []byte) {
+	if len(data) < 2 {
+		return
+	}
+	addr := data[0]
+	if data[1]&0x18 == 0x00 {
+		if w := wire.DecodeBytes(data[2:]); len(w) > 1 {
+			b.SetI2C(addr, int(w[0]), w[1:]...)
+		}
+		return
+	}
+	if data[1]&0x18 == 0x18 {
+		b.mu.Lock()
+		delete(b.i2cReads, addr)
+		b.mu.Unlock()
+		return
+	}
+	args := data[2:]
+	reg := -1 // no register
+	if len(args) >= 4 {
+		reg = int(wire.From7Bit(args[0], args[1]))
+		args = args[2:]
+	}
+	if len(args) < 2 {
+		return
+	}
+	n := int(wire.From7Bit(args[0], args[1]))
+	if data[1]&0x18 == 0x10 {
+		b.mu.Lock()
+		if b.i2cReads == nil {
+			b.i2cReads = make(map[byte][][2]int)
+		}
+		b.i2cReads[addr] = append(b.i2cReads[addr], [2]int{reg, n})
+		b.mu.Unlock()
+	}
+	b.replyI2C(addr, reg, n)
+}
+
+// replyI2C replies with n registers of the device at addr from reg.
+// Reads without a register, a negative reg, start at register 0 and are
+// reported as reads of register 0xFF, as by StandardFirmata.
+func (b *Board) replyI2C(addr byte, reg, n int) {
+	reported := reg
+	if reg < 0 {
+		reg, reported = 0, 0xFF
+	}
+	reply := append(wire.To7Bit(addr), wire.EncodeUint(uint64(reported), 2)...)
+	b.mu.Lock()
+	for i := 0; i < n; i++ {
+		reply = append(reply, wire.To7Bit(b.i2c[addr][reg+i])...)
+	}
+	b.mu.Unlock()
+	b.reply(wire.SysEx{Command: byte(firmata.I2CReply), Data: reply})
+}
+
+func (b *Board) firmware() wire.SysEx {
+	data := []byte{firmata.ProtocolMajorVersion, firmata.ProtocolMinorVersion}
+	for _, r := range b.Firmware {
+		data = append(data, wire.EncodeUint(uint64(r), 2)...)
+	}
+	return wire.SysEx{Command: byte(firmata.ReportFirmware), Data: data}
+}
+
+// capabilities returns the capability response of an Uno.
+func capabilities() []byte {
+	var data []byte
+	for pin := 0; pin < pins; pin++ {
+		if pin < 2 {
+			// Like StandardFirmata, the pins of the serial port
+			// have no modes.
+			data = append(data, 127)
+			continue
+		}
+		data = append(data, modeInput, 1, modeOutput, 1)
+		switch pin {
+		case 3, 5, 6, 9, 10, 11:
+			data = append(data, modePWM, 8)
+		}
+		if pin >= 2 && pin < 14 {
+			data = append(data, modeServo, 14)
+		}
+		if pin >= 14 {
+			data = append(data, modeAnalog, 10)
+		}
+		if pin == 18 || pin == 19 {
+			data = append(data, modeI2C, 1)
+		}
+		data = append(data, 127)
+	}
+	return data
+}
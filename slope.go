@@ -0,0 +1,79 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"math"
+	"time"
+)
+
+// SlopeEvent is published when the value of an analog pin changes
+// faster than the slope set with WatchSlope.
+type SlopeEvent struct {
+	Header
+	Pin   int
+	Value int
+
+	// Slope is the rate of change since the previous sample, in
+	// counts per second.
+	Slope float64
+}
+
+type slopeState struct {
+	limit float64
+	last  int
+	at    time.Time
+	seen  bool
+}
+
+// WatchSlope makes the client publish a SlopeEvent whenever the value of
+// the analog pin changes by more than limit counts per second between
+// two samples, in either direction. A limit of zero stops watching.
+// Analog reporting of the pin must be enabled separately.
+func (c *Client) WatchSlope(pin int, limit float64) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if limit == 0 {
+		delete(c.slopes, pin)
+		return
+	}
+	if c.slopes == nil {
+		c.slopes = make(map[int]*slopeState)
+	}
+	c.slopes[pin] = &slopeState{limit: math.Abs(limit)}
+}
+
+// checkSlope publishes a SlopeEvent if the analog sample ev exceeds the
+// slope watched on its pin.
+func (c *Client) checkSlope(ev AnalogEvent) {
+	c.stateMu.Lock()
+	s := c.slopes[ev.Pin]
+	if s == nil {
+		c.stateMu.Unlock()
+		return
+	}
+	last, at, seen := s.last, s.at, s.seen
+	s.last, s.at, s.seen = ev.Value, ev.Time, true
+	c.stateMu.Unlock()
+
+	dt := ev.Time.Sub(at).Seconds()
+	if !seen || dt <= 0 {
+		return
+	}
+	slope := float64(ev.Value-last) / dt
+	if math.Abs(slope) > s.limit {
+		c.bus.publish(SlopeEvent{ev.Header, ev.Pin, ev.Value, slope})
+	}
+}
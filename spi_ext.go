@@ -14,42 +14,53 @@
 
 package firmata
 
+import (
+	"context"
+	"fmt"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
 type SPISubCommand byte
 
 // Enable SPI communication for selected chip-select pin
 func (c *Client) SPIConfig(csPin byte, spiMode byte) (err error) {
-	csPinBytes := to7Bit(csPin)
-	spiModeBytes := to7Bit(spiMode)
-	c.spiChan = make(chan []byte)
+	csPinBytes := wire.To7Bit(csPin)
+	spiModeBytes := wire.To7Bit(spiMode)
 
-	err = c.sendSysEx(SysExSPI, byte(SPIConfig),
+	m := wire.SysEx{Command: byte(SysExSPI), Data: []byte{byte(SPIConfig),
 		csPinBytes[0], csPinBytes[1],
-		spiModeBytes[0], spiModeBytes[1])
-	return
+		spiModeBytes[0], spiModeBytes[1]}}
+	return c.sendConfig(fmt.Sprintf("spi/%d", csPin), m)
 }
 
-// Read and write data to SPI device
+// Read and write data to SPI device. It blocks until the board replies
+// or the transfer times out.
 func (c *Client) SPIReadWrite(csPin byte, data []byte) (dataOut []byte, err error) {
-	csPinBytes := to7Bit(csPin)
+	return c.SPIReadWriteContext(context.Background(), csPin, data)
+}
+
+// SPIReadWriteContext is like SPIReadWrite but fails when ctx is done.
+func (c *Client) SPIReadWriteContext(ctx context.Context, csPin byte, data []byte) ([]byte, error) {
 	data7Bit := []byte{byte(SPIComm)}
+	data7Bit = append(data7Bit, wire.To7Bit(csPin)...)
+	data7Bit = append(data7Bit, wire.EncodeBytes(data)...)
 
-	data7Bit = append(data7Bit, csPinBytes...)
-	for i := 0; i < len(data); i++ {
-		bytes := to7Bit(data[i])
-		data7Bit = append(data7Bit, bytes...)
+	v, err := c.query(ctx, queryKey{cmd: SysExSPI, id: int(csPin)}, func() error {
+		return c.sendSysEx(SysExSPI, data7Bit...)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("spi transfer on pin %d: %v", csPin, err)
 	}
-
-	err = c.sendSysEx(SysExSPI, data7Bit...)
-	dataOut = <-c.spiChan
-	return
+	return v.([]byte), nil
 }
 
+// parseSPIResponse decodes the reply to a transfer: the subcommand, the
+// chip-select pin and the data read, as 7-bit pairs.
 func (c *Client) parseSPIResponse(data7bit []byte) {
-	data := make([]byte, 0)
-	for i, _ := range data7bit {
-		if i >= 3 && i%2 != 0 {
-			data = append(data, from7Bit(data7bit[i], data7bit[i+1]))
-		}
+	if len(data7bit) < 3 {
+		return
 	}
-	c.spiChan <- data
+	csPin := int(wire.From7Bit(data7bit[1], data7bit[2]))
+	c.pending.resolve(queryKey{cmd: SysExSPI, id: csPin}, wire.DecodeBytes(data7bit[3:]))
 }
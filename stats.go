@@ -0,0 +1,193 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// WithParserLimits sets the limits of the parser of the messages from
+// the board. With l.MaxSkip set, a stream that is mostly not Firmata,
+// as when the baud rate is wrong, fails the connection with
+// wire.ErrGarbage instead of being skipped forever.
+func WithParserLimits(l wire.Limits) Option {
+	return func(c *Client) {
+		c.limits = l
+	}
+}
+
+// Stats are counters of the client since it was created.
+type Stats struct {
+	// Messages is the number of messages received.
+	Messages uint64
+
+	// FramesSent and FramesReceived count the messages by type, as in
+	// "digital", "analog" or "sysex 0x77".
+	FramesSent     map[string]uint64
+	FramesReceived map[string]uint64
+
+	BytesSent     uint64
+	BytesReceived uint64
+
+	// Dropped is the number of events not delivered to subscribers
+	// whose buffer was full.
+	Dropped uint64
+
+	// Reconnects is the number of successful reconnections and
+	// Failovers the number of switches to the standby connection.
+	Reconnects uint64
+	Failovers  uint64
+
+	// PendingQueries is the number of queries waiting for a reply and
+	// CallbackBacklog the number of OnEvent callbacks waiting for a
+	// worker.
+	PendingQueries  int
+	CallbackBacklog int
+
+	// Skipped is the number of received bytes that were not part of a
+	// valid message.
+	Skipped uint64
+
+	// SysExOverflows counts the SysEx messages discarded for being too
+	// long and SysExTruncated the ones that lost their end.
+	SysExOverflows uint64
+	SysExTruncated uint64
+}
+
+// Stats returns the counters of the client.
+func (c *Client) Stats() Stats {
+	s := Stats{
+		Messages:        c.parserStats.Messages.Load(),
+		Skipped:         c.parserStats.Skipped.Load(),
+		SysExOverflows:  c.parserStats.Overflows.Load(),
+		SysExTruncated:  c.parserStats.Truncated.Load(),
+		BytesSent:       c.counters.bytesSent.Load(),
+		BytesReceived:   c.counters.bytesReceived.Load(),
+		Reconnects:      c.counters.reconnects.Load(),
+		Failovers:       c.counters.failovers.Load(),
+		PendingQueries:  c.pending.len(),
+		CallbackBacklog: c.workers.backlog(),
+	}
+	c.counters.mu.Lock()
+	s.FramesSent = copyCounts(c.counters.sent)
+	s.FramesReceived = copyCounts(c.counters.received)
+	c.counters.mu.Unlock()
+	c.bus.mu.Lock()
+	s.Dropped = c.bus.dropped
+	c.bus.mu.Unlock()
+	return s
+}
+
+// counters are the traffic counters of a client.
+type counters struct {
+	bytesSent     atomic.Uint64
+	bytesReceived atomic.Uint64
+	reconnects    atomic.Uint64
+	failovers     atomic.Uint64
+
+	mu       sync.Mutex
+	sent     map[string]uint64
+	received map[string]uint64
+}
+
+func (k *counters) count(m *map[string]uint64, typ string) {
+	k.mu.Lock()
+	if *m == nil {
+		*m = make(map[string]uint64)
+	}
+	(*m)[typ]++
+	k.mu.Unlock()
+}
+
+func copyCounts(m map[string]uint64) map[string]uint64 {
+	cp := make(map[string]uint64, len(m))
+	for k, v := range m {
+		cp[k] = v
+	}
+	return cp
+}
+
+// frameType names the message starting with the command byte of frame.
+func frameType(frame []byte) string {
+	if len(frame) == 0 {
+		return "empty"
+	}
+	switch b := frame[0]; {
+	case b == wire.StartSysEx:
+		if len(frame) < 2 {
+			return "sysex"
+		}
+		return fmt.Sprintf("sysex %#02x", frame[1])
+	case b == wire.ReportVersion:
+		return "version"
+	case b == wire.SystemReset:
+		return "reset"
+	case b == wire.SetPinMode:
+		return "pin_mode"
+	case b == wire.SetDigitalPin:
+		return "digital_pin"
+	case b&0xF0 == wire.DigitalMessage:
+		return "digital"
+	case b&0xF0 == wire.AnalogMessage:
+		return "analog"
+	case b&0xF0 == wire.ReportAnalog:
+		return "report_analog"
+	case b&0xF0 == wire.ReportDigital:
+		return "report_digital"
+	}
+	return "other"
+}
+
+// messageType names m like frameType names its encoding.
+func messageType(m wire.Message) string {
+	switch m := m.(type) {
+	case wire.SysEx:
+		return fmt.Sprintf("sysex %#02x", m.Command)
+	case wire.Version:
+		return "version"
+	case wire.Reset:
+		return "reset"
+	case wire.PinMode:
+		return "pin_mode"
+	case wire.DigitalPin:
+		return "digital_pin"
+	case wire.Digital:
+		return "digital"
+	case wire.Analog:
+		return "analog"
+	case wire.AnalogReport:
+		return "report_analog"
+	case wire.DigitalReport:
+		return "report_digital"
+	}
+	return "other"
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n *atomic.Uint64
+}
+
+func (r countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n.Add(uint64(n))
+	return n, err
+}
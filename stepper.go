@@ -0,0 +1,142 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// Subcommands of StepperData.
+const (
+	stepperConfig = 0x00
+	stepperStep   = 0x01
+)
+
+// MaxSteppers is the number of steppers the legacy StepperFirmata drives.
+const MaxSteppers = 6
+
+// StepperInterface is how a stepper motor is wired to the board.
+type StepperInterface byte
+
+const (
+	StepperDriver    StepperInterface = 0x01 // a driver board, with direction and step pins
+	StepperTwoWire   StepperInterface = 0x02
+	StepperThreeWire StepperInterface = 0x03 // AccelStepper only
+	StepperFourWire  StepperInterface = 0x04
+)
+
+// StepperConfig describes a stepper motor for the legacy StepperFirmata
+// feature of older ConfigurableFirmata builds.
+type StepperConfig struct {
+	// Device is the number of the motor, from 0 to MaxSteppers-1.
+	Device int
+
+	Interface   StepperInterface
+	StepsPerRev int
+
+	// Pins are the direction and step pins of a driver, or the motor
+	// pins of a two or four wire stepper.
+	Pins []uint8
+}
+
+// StepperEvent is published when a stepper completed the steps given to
+// StepperStep.
+type StepperEvent struct {
+	Header
+	Device int
+}
+
+// ConfigureStepper sets up the stepper motor described by cfg.
+func (c *Client) ConfigureStepper(cfg StepperConfig) error {
+	if cfg.Device < 0 || cfg.Device >= MaxSteppers {
+		return fmt.Errorf("invalid stepper device %d", cfg.Device)
+	}
+	pins := 2
+	switch cfg.Interface {
+	case StepperFourWire:
+		pins = 4
+	case StepperThreeWire:
+		return errors.New("firmata: StepperFirmata has no three wire interface")
+	}
+	if len(cfg.Pins) != pins {
+		return fmt.Errorf("stepper interface %d needs %d pins, got %d", cfg.Interface, pins, len(cfg.Pins))
+	}
+	if cfg.StepsPerRev <= 0 || cfg.StepsPerRev > 0x3FFF {
+		return errors.New("firmata: invalid steps per revolution")
+	}
+	data := []byte{stepperConfig, byte(cfg.Device), byte(cfg.Interface)}
+	data = append(data, wire.EncodeUint(uint64(cfg.StepsPerRev), 2)...)
+	for _, pin := range cfg.Pins {
+		if err := c.checkPin(int(pin)); err != nil {
+			return err
+		}
+		data = append(data, pin)
+	}
+	m := wire.SysEx{Command: byte(StepperData), Data: data}
+	if err := c.sendConfig(fmt.Sprintf("stepper/%d", cfg.Device), m); err != nil {
+		return err
+	}
+	for _, pin := range cfg.Pins {
+		c.modeSet(pin, Stepper)
+	}
+	return nil
+}
+
+// StepperStep moves a stepper by steps, counterclockwise if negative, at
+// speed radians per second. Non-zero accel and decel, in radians per
+// second squared, ramp the speed up and down. A StepperEvent is
+// published when the move completes.
+func (c *Client) StepperStep(device int, steps int, speed, accel, decel float64) error {
+	if device < 0 || device >= MaxSteppers {
+		return fmt.Errorf("invalid stepper device %d", device)
+	}
+	dir := byte(1)
+	if steps < 0 {
+		dir, steps = 0, -steps
+	}
+	if steps >= 1<<21 {
+		return errors.New("firmata: too many steps")
+	}
+	data := []byte{stepperStep, byte(device), dir}
+	data = append(data, wire.EncodeUint(uint64(steps), 3)...)
+	v, err := stepperValue(speed)
+	if err != nil {
+		return err
+	}
+	data = append(data, v...)
+	if accel != 0 || decel != 0 {
+		for _, x := range []float64{accel, decel} {
+			v, err := stepperValue(x)
+			if err != nil {
+				return err
+			}
+			data = append(data, v...)
+		}
+	}
+	return c.sendSysEx(StepperData, data...)
+}
+
+// stepperValue encodes x in hundredths as a 14-bit value.
+func stepperValue(x float64) ([]byte, error) {
+	v := math.Round(x * 100)
+	if v < 0 || v > 0x3FFF {
+		return nil, fmt.Errorf("stepper value %v out of range", x)
+	}
+	return wire.EncodeUint(uint64(v), 2), nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"strings"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// StringEvent is a text message from the board, such as the errors and
+// debug output StandardFirmata sends.
+type StringEvent struct {
+	Header
+	Text string
+}
+
+// Strings returns the channel of the text messages from the board. All
+// callers share the same channel. Messages are dropped when it is full.
+func (c *Client) Strings() <-chan StringEvent {
+	c.stringOnce.Do(func() { c.stringChan = Subscribe[StringEvent](c, Filter{}) })
+	return c.stringChan
+}
+
+// SendString sends s to the board in a STRING_DATA message, each byte of
+// its UTF-8 encoding as a pair of 7-bit bytes. It is meant for custom
+// firmware that accepts text commands.
+func (c *Client) SendString(s string) error {
+	return c.sendSysEx(StringData, wire.EncodeBytes([]byte(s))...)
+}
+
+// parseString decodes a STRING_DATA message, whose characters are sent
+// as pairs of 7-bit bytes.
+func (c *Client) parseString(data []byte) {
+	text := strings.TrimRight(string(wire.DecodeBytes(data)), "\x00")
+	c.bus.publish(StringEvent{Header{c.eventTime()}, text})
+}
@@ -0,0 +1,61 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"errors"
+	"io"
+)
+
+// SwapTransport replaces the connection to the board with conn, for
+// instance when a board moves from USB to TCP, and closes the previous
+// one. Subscriptions and the state of the client are kept and its
+// configuration is replayed on conn. A client whose reader stopped
+// after losing its connection can't be revived; clients expecting the
+// device path to change should be created WithReconnect, and can swap
+// the transport while they try to reconnect.
+func (c *Client) SwapTransport(conn io.ReadWriteCloser) error {
+	if c.readerDone() {
+		return errors.New("firmata: connection to the board was lost")
+	}
+	c.connMu.Lock()
+	if c.closing {
+		c.connMu.Unlock()
+		return errors.New("firmata: client is closed")
+	}
+	old := c.conn
+	c.conn = conn
+	c.connGen++
+	c.stuckWrite = nil
+	c.connMu.Unlock()
+
+	// Closing the previous connection unblocks the reader, which then
+	// reads from conn.
+	old.Close()
+	return c.Replay()
+}
+
+// swapped reports whether the connection was replaced since the reader
+// started using the connection identified by gen, and if so returns the
+// new connection and updates gen.
+func (c *Client) swapped(gen *int) (io.ReadWriteCloser, bool) {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	if c.closing || c.connGen == *gen {
+		return nil, false
+	}
+	*gen = c.connGen
+	return c.conn, true
+}
@@ -14,80 +14,128 @@
 
 package firmata
 
-import (
-	"bytes"
-	"fmt"
-)
+import "github.com/rakyll/go-firmata/wire"
 
-func (c *Client) parseSysEx(data []byte) {
-	cmd := SysExCommand(data[0])
-	data = data[1:]
-
-	bStr := ""
-	for _, b := range data {
-		bStr = bStr + fmt.Sprintf(" %#2x", b)
-	}
+func (c *Client) parseSysEx(m wire.SysEx) {
+	cmd := SysExCommand(m.Command)
+	data := m.Data
 
 	switch {
 	case cmd == StringData:
-		// do nothing?
+		c.parseString(data)
 	case cmd == CapabilityResponse:
-		dataBuf := bytes.NewBuffer(data)
-		c.pinModes = make([]map[PinMode]interface{}, 0)
-
-		pin := 0
-		var err error
-		var modes []byte
-		for ; err == nil; modes, err = dataBuf.ReadBytes(127) {
-			pinModes := make(map[PinMode]interface{})
-			if len(modes) < 2 {
-				continue
-			}
-
-			modes = modes[0 : len(modes)-1]
-			for i := 0; i < len(modes); i = i + 2 {
-				pinModes[PinMode(modes[i])] = modes[i+1]
-			}
-			c.pinModes = append(c.pinModes, pinModes)
-			pin = pin + 1
-		}
+		modes := parseCapabilities(data)
+		c.setBoard(func(b *boardPins) { b.modes = modes })
 		c.capabilityDone = true
+		c.capsReported = true
+		if c.cache != nil && c.mappingReported {
+			c.refreshCache()
+		}
+		c.pending.resolve(queryKey{cmd: CapabilityResponse}, nil)
 	case cmd == AnalogMappingResponse:
-		c.analogPinsChannelMap = make(map[int]byte)
-		c.analogChannelPinsMap = make(map[byte]int)
+		mapping := make(map[int]byte, len(data))
 		for pin, channel := range data {
-			if channel != 127 {
-				c.analogPinsChannelMap[pin] = channel
-				c.analogChannelPinsMap[channel] = pin
-			}
+			mapping[pin] = channel
 		}
+		c.setBoard(func(b *boardPins) { b.setAnalogMapping(mapping) })
 		c.analogMappingDone = true
+		c.mappingReported = true
+		if c.cache != nil && c.capsReported {
+			c.refreshCache()
+		}
 	case cmd == ReportFirmware:
+		// The firmware report ends the reply to a query.
+		c.versionQueried.Store(false)
+		solicited := c.firmwareQueried.Swap(false)
+		if len(data) < 2 {
+			return
+		}
 		c.firmwareVersion = make([]int, 2)
 		c.firmwareVersion[0] = int(data[0])
 		c.firmwareVersion[1] = int(data[1])
 		data = data[2:]
-		c.firmwareName = multibyteString(data)
+		c.firmwareName = wire.MultibyteString(data)
+		if !solicited && c.resetSuspected.Swap(false) {
+			// The board starts by reporting its version, then its
+			// firmware: it has been reset and lost its configuration.
+			c.confirmReset("board reported version %d.%d and firmware %s", c.protocolVersion[0], c.protocolVersion[1], c.firmwareName)
+		}
+		if c.pending.resolve(queryKey{cmd: ReportFirmware}, Firmware{c.firmwareName, c.firmwareVersion[0], c.firmwareVersion[1]}) {
+			break
+		}
+		if c.cache != nil && c.loadCachedCapabilities() {
+			c.markInited()
+		}
 		c.sendSysEx(AnalogMappingQuery)
 		c.sendSysEx(CapabilityQuery)
+	case cmd == PinStateResponse:
+		c.parsePinStateResponse(data)
+	case cmd == I2CReply:
+		c.parseI2CReply(data)
 	case cmd == Serial:
 		c.parseSerialResponse(data)
 	case cmd == SysExSPI:
 		c.parseSPIResponse(data)
+	case cmd == Uptime:
+		c.parseUptime(data)
+	case cmd == Echo:
+		c.parseEcho(data)
+	case cmd == PulseIn:
+		c.parsePulseIn(data)
+	case cmd == Timestamp:
+		c.parseTimestamp(data)
+	case cmd == StepperData:
+		if len(data) >= 1 {
+			c.bus.publish(StepperEvent{Header{c.eventTime()}, int(data[0])})
+		}
+	case cmd == AccelStepperData:
+		c.parseAccelStepper(data)
+	case cmd == EncoderData:
+		c.parseEncoder(data)
+	case cmd == Scheduler:
+		c.parseScheduler(data)
+	default:
+		c.sysExMu.Lock()
+		f := c.features[cmd]
+		fn := c.sysExHandlers[cmd]
+		c.sysExMu.Unlock()
+		if f != nil {
+			if ev := f.Decode(cmd, data); ev != nil {
+				c.bus.publish(ev)
+			}
+			break
+		}
+		if fn != nil {
+			fn(data)
+			break
+		}
+		c.diagnose(UnknownSysEx, "sysex 0x%02x with %d bytes", byte(cmd), len(data))
 	}
 }
 
-func (c *Client) sendSysEx(cmd SysExCommand, data ...byte) (err error) {
-	var b bytes.Buffer
-	b.WriteByte(byte(StartSysEx))
-	b.WriteByte(byte(cmd))
-	b.Write(data)
-	b.WriteByte(byte(EndSysEx))
+// SendSysEx sends a SysEx message with a 7-bit data payload. It is meant
+// for firmware extensions the client has no built-in support for.
+func (c *Client) SendSysEx(cmd SysExCommand, data ...byte) error {
+	return c.sendSysEx(cmd, data...)
+}
 
-	bStr := ""
-	for _, b := range b.Bytes() {
-		bStr = bStr + fmt.Sprintf(" %#2x", b)
+// HandleSysEx registers fn to be called with the payload of incoming
+// SysEx messages with command cmd. Commands the client handles itself
+// or that are claimed by a registered Feature are never passed to fn. fn runs on the goroutine reading from the
+// board and must not block. A nil fn removes the handler.
+func (c *Client) HandleSysEx(cmd SysExCommand, fn func(data []byte)) {
+	c.sysExMu.Lock()
+	defer c.sysExMu.Unlock()
+	if c.sysExHandlers == nil {
+		c.sysExHandlers = make(map[SysExCommand]func([]byte))
+	}
+	if fn == nil {
+		delete(c.sysExHandlers, cmd)
+		return
 	}
-	_, err = b.WriteTo(c.conn)
-	return
+	c.sysExHandlers[cmd] = fn
+}
+
+func (c *Client) sendSysEx(cmd SysExCommand, data ...byte) error {
+	return c.send(wire.SysEx{Command: byte(cmd), Data: data})
 }
@@ -0,0 +1,123 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// Timestamp is the SysEx command of the timestamp extension found in
+// contrib/Timestamp. The firmware sends micros() as five 7-bit bytes,
+// LSB first, right before each report it stamps.
+const Timestamp SysExCommand = 0x0F
+
+// TimestampSource selects the clock the Time of received events is
+// taken from.
+type TimestampSource int
+
+const (
+	// TimestampHost stamps events with the client clock when they are
+	// received, the default.
+	TimestampHost TimestampSource = iota
+
+	// TimestampMonotonic stamps events with the time they are
+	// received as an offset from the creation of the client, so that
+	// changes of the wall clock don't affect their spacing.
+	TimestampMonotonic
+
+	// TimestampBoard stamps events with the time the firmware took the
+	// reading, using the timestamp extension. Events the firmware
+	// doesn't stamp fall back to the client clock. Board time is
+	// converted with the TimeSync given to SetTimeSync or, until then,
+	// estimated from the arrival of the first stamp.
+	TimestampBoard
+)
+
+// WithTimestamps makes the client stamp received events with src.
+func WithTimestamps(src TimestampSource) Option {
+	return func(c *Client) {
+		c.stamps.src = src
+	}
+}
+
+// SetTimeSync sets how board timestamps are converted to client time,
+// usually to the result of SyncTime.
+func (c *Client) SetTimeSync(s TimeSync) {
+	c.stamps.mu.Lock()
+	defer c.stamps.mu.Unlock()
+	c.stamps.sync = s
+	c.stamps.synced = true
+}
+
+// stamper keeps the state of the timestamp sources.
+type stamper struct {
+	src TimestampSource
+
+	mu     sync.Mutex
+	epoch  time.Time // creation of the client, for TimestampMonotonic
+	sync   TimeSync
+	synced bool
+
+	pending bool          // a stamp awaits the next report
+	stamp   time.Duration // board uptime of the pending stamp
+	lastRaw uint32
+	wraps   int64 // wraparounds of micros()
+}
+
+// eventTime returns the Time of an event received now.
+func (c *Client) eventTime() time.Time {
+	now := c.clock.Now()
+	s := &c.stamps
+	if s.src == TimestampHost {
+		return now
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch s.src {
+	case TimestampMonotonic:
+		return s.epoch.Add(now.Sub(s.epoch))
+	case TimestampBoard:
+		if !s.pending {
+			return now
+		}
+		s.pending = false
+		if !s.synced {
+			s.sync = TimeSync{Boot: now.Add(-s.stamp)}
+			s.synced = true
+		}
+		return s.sync.HostTime(s.stamp)
+	}
+	return now
+}
+
+// parseTimestamp records the board time of the next report.
+func (c *Client) parseTimestamp(data []byte) {
+	if len(data) < 5 {
+		return
+	}
+	raw := uint32(wire.DecodeUint(data[:5]))
+	s := &c.stamps
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if raw < s.lastRaw {
+		s.wraps++
+	}
+	s.lastRaw = raw
+	s.stamp = time.Duration(s.wraps<<32|int64(raw)) * time.Microsecond
+	s.pending = true
+}
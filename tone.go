@@ -0,0 +1,103 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"errors"
+	"time"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// Subcommands of ToneData.
+const (
+	toneTone   = 0x00
+	toneNoTone = 0x01
+)
+
+// maxEmulatedTone is the highest frequency in Hz the host toggles a pin
+// at when emulating tones.
+const maxEmulatedTone = 500
+
+// Tone plays a square wave of freq Hz on digital output pin for d, or
+// until NoTone if d is zero. It needs firmware with the tone extension
+// unless the client emulates it, see WithEmulation; emulated tones are
+// limited to 500Hz.
+func (c *Client) Tone(pin uint8, freq int, d time.Duration) error {
+	if err := c.checkPin(int(pin)); err != nil {
+		return err
+	}
+	ms := int(d / time.Millisecond)
+	if freq <= 0 || freq > 0x3FFF || ms < 0 || ms > 0x3FFF {
+		return errors.New("firmata: tone out of range")
+	}
+	if !c.emulation.enabled {
+		f, t := wire.IntTo7Bit(freq), wire.IntTo7Bit(ms)
+		return c.sendSysEx(ToneData, toneTone, pin, f[0], f[1], t[0], t[1])
+	}
+	if freq > maxEmulatedTone {
+		return errors.New("firmata: emulated tones are limited to 500Hz")
+	}
+	c.warnEmulated("tone")
+	c.NoTone(pin)
+	stop := make(chan struct{})
+	c.emulation.mu.Lock()
+	if c.emulation.tones == nil {
+		c.emulation.tones = make(map[uint8]chan struct{})
+	}
+	c.emulation.tones[pin] = stop
+	c.emulation.mu.Unlock()
+
+	go func() {
+		half := time.Second / time.Duration(2*freq)
+		var end <-chan time.Time
+		if d > 0 {
+			end = c.clock.After(d)
+		}
+		high := true
+		for {
+			if c.digitalWrite(pin, high) != nil {
+				return
+			}
+			high = !high
+			select {
+			case <-c.clock.After(half):
+			case <-end:
+				c.digitalWrite(pin, false)
+				return
+			case <-stop:
+				c.digitalWrite(pin, false)
+				return
+			case <-c.done:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// NoTone stops the tone played on pin.
+func (c *Client) NoTone(pin uint8) error {
+	if !c.emulation.enabled {
+		return c.sendSysEx(ToneData, toneNoTone, pin&0x7F)
+	}
+	c.emulation.mu.Lock()
+	defer c.emulation.mu.Unlock()
+	if stop := c.emulation.tones[pin]; stop != nil {
+		close(stop)
+		delete(c.emulation.tones, pin)
+	}
+	return nil
+}
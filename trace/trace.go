@@ -0,0 +1,231 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package trace records Firmata sessions to a compact binary file and
+// reads them back, so complete traces can be attached to bug reports
+// and inspected with "firmata decode".
+//
+// A trace starts with the 8 byte magic "FMTRACE1" and the start time as
+// big endian Unix nanoseconds, followed by records: a direction byte,
+// the offset from the start in microseconds and the data length as
+// uvarints, and the data.
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
+const magic = "FMTRACE1"
+
+// Direction tells which side sent the data of a record.
+type Direction byte
+
+const (
+	ToBoard   Direction = 0
+	FromBoard Direction = 1
+)
+
+func (d Direction) String() string {
+	if d == ToBoard {
+		return "->"
+	}
+	return "<-"
+}
+
+// Record is a chunk of data sent in a session.
+type Record struct {
+	Time time.Time
+	Dir  Direction
+	Data []byte
+}
+
+// Conn is a connection whose traffic is recorded.
+type Conn struct {
+	conn  io.ReadWriteCloser
+	start time.Time
+
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+// NewConn returns a connection that records the traffic of conn to w.
+// Recording errors don't affect the connection; see Err.
+func NewConn(conn io.ReadWriteCloser, w io.Writer) *Conn {
+	c := &Conn{conn: conn, w: w, start: time.Now()}
+	hdr := make([]byte, len(magic)+8)
+	copy(hdr, magic)
+	binary.BigEndian.PutUint64(hdr[len(magic):], uint64(c.start.UnixNano()))
+	_, c.err = w.Write(hdr)
+	return c
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	n, err := c.conn.Read(p)
+	if n > 0 {
+		c.record(FromBoard, p[:n])
+	}
+	return n, err
+}
+
+func (c *Conn) Write(p []byte) (int, error) {
+	n, err := c.conn.Write(p)
+	if n > 0 {
+		c.record(ToBoard, p[:n])
+	}
+	return n, err
+}
+
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// Err returns the first error writing the trace.
+func (c *Conn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *Conn) record(dir Direction, p []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	buf := make([]byte, 1, 1+2*binary.MaxVarintLen64+len(p))
+	buf[0] = byte(dir)
+	buf = binary.AppendUvarint(buf, uint64(time.Since(c.start)/time.Microsecond))
+	buf = binary.AppendUvarint(buf, uint64(len(p)))
+	buf = append(buf, p...)
+	_, c.err = c.w.Write(buf)
+}
+
+// Reader reads the records of a trace.
+type Reader struct {
+	r     *bufio.Reader
+	start time.Time
+}
+
+// NewReader checks the header of the trace in r and returns a reader of
+// its records.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	hdr := make([]byte, len(magic)+8)
+	if _, err := io.ReadFull(br, hdr); err != nil {
+		return nil, err
+	}
+	if string(hdr[:len(magic)]) != magic {
+		return nil, errors.New("trace: not a trace file")
+	}
+	start := time.Unix(0, int64(binary.BigEndian.Uint64(hdr[len(magic):])))
+	return &Reader{r: br, start: start}, nil
+}
+
+// Next returns the next record, or io.EOF at the end of the trace.
+func (r *Reader) Next() (Record, error) {
+	dir, err := r.r.ReadByte()
+	if err != nil {
+		return Record{}, err
+	}
+	us, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return Record{}, io.ErrUnexpectedEOF
+	}
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil || n > 1<<20 {
+		return Record{}, io.ErrUnexpectedEOF
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return Record{}, io.ErrUnexpectedEOF
+	}
+	t := r.start.Add(time.Duration(us) * time.Microsecond)
+	return Record{Time: t, Dir: Direction(dir), Data: data}, nil
+}
+
+// Frame is a decoded message of a trace.
+type Frame struct {
+	Time    time.Time
+	Dir     Direction
+	Message wire.Message
+	Raw     []byte
+}
+
+// Framer reassembles the messages of a trace, which may be split across
+// records.
+type Framer struct {
+	pending [2][]byte
+}
+
+// Add adds the data of rec and returns the messages it completed, with
+// the time of rec. Bytes that don't form a message are returned as
+// frames with a nil Message.
+func (f *Framer) Add(rec Record) []Frame {
+	buf := append(f.pending[rec.Dir&1], rec.Data...)
+	var frames []Frame
+	for len(buf) > 0 {
+		n := frameLen(buf)
+		if n == 0 {
+			break
+		}
+		raw := buf[:n]
+		var m wire.Message
+		if raw[0] >= 0x80 {
+			m, _ = wire.NewDecoder(bytes.NewReader(raw)).Decode()
+		}
+		frames = append(frames, Frame{rec.Time, rec.Dir, m, append([]byte(nil), raw...)})
+		buf = buf[n:]
+	}
+	f.pending[rec.Dir&1] = append([]byte(nil), buf...)
+	return frames
+}
+
+// frameLen returns the length of the message at the start of buf, 1 for
+// a stray data byte, or 0 if the message is incomplete.
+func frameLen(buf []byte) int {
+	var n int
+	switch b := buf[0]; {
+	case b < 0x80:
+		return 1
+	case b == wire.StartSysEx:
+		i := bytes.IndexByte(buf, wire.EndSysEx)
+		if i < 0 {
+			return 0
+		}
+		return i + 1
+	case b == wire.SystemReset:
+		n = 1
+	case b == wire.ReportVersion, b == wire.SetPinMode, b == wire.SetDigitalPin:
+		n = 3
+	case b&0xF0 == wire.DigitalMessage, b&0xF0 == wire.AnalogMessage:
+		n = 3
+	case b&0xF0 == wire.ReportAnalog, b&0xF0 == wire.ReportDigital:
+		n = 2
+	default:
+		return 1
+	}
+	if len(buf) < n {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,117 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// CRCFrame is the user-defined SysEx command that wraps a checked frame.
+// The payload is the original frame split into 7-bit LSB/MSB pairs
+// followed by the CRC-8 of the original frame as one more pair.
+const CRCFrame = 0x0C
+
+// CRCConn is a connection that protects every Firmata frame with a
+// CRC-8 checksum. The board must run firmware with the companion
+// snippet from contrib/CRCFrame.
+type CRCConn struct {
+	conn io.ReadWriteCloser
+	dec  *wire.Decoder
+	buf  bytes.Buffer
+
+	mu      sync.Mutex
+	dropped int
+}
+
+// CRC wraps conn with the CRC framing layer. Each Write call must carry
+// whole Firmata frames and is sent as one checked frame. Checked frames
+// read from conn are verified and unwrapped; corrupted frames are
+// dropped. Unwrapped frames are passed through as is.
+func CRC(conn io.ReadWriteCloser) *CRCConn {
+	return &CRCConn{conn: conn, dec: wire.NewDecoder(conn)}
+}
+
+func (c *CRCConn) Read(p []byte) (int, error) {
+	for c.buf.Len() == 0 {
+		m, err := c.dec.Decode()
+		if err != nil {
+			return 0, err
+		}
+		sysex, ok := m.(wire.SysEx)
+		if !ok || sysex.Command != CRCFrame {
+			c.buf.Write(m.Bytes())
+			continue
+		}
+		frame, ok := unwrapCRC(sysex.Data)
+		if !ok {
+			c.mu.Lock()
+			c.dropped++
+			c.mu.Unlock()
+			continue
+		}
+		c.buf.Write(frame)
+	}
+	return c.buf.Read(p)
+}
+
+func (c *CRCConn) Write(p []byte) (int, error) {
+	data := wire.EncodeBytes(append(p[:len(p):len(p)], crc8(p)))
+	if _, err := c.conn.Write(wire.SysEx{Command: CRCFrame, Data: data}.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *CRCConn) Close() error {
+	return c.conn.Close()
+}
+
+// Dropped returns the number of frames discarded because of a
+// checksum mismatch.
+func (c *CRCConn) Dropped() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped
+}
+
+func unwrapCRC(data []byte) ([]byte, bool) {
+	if len(data) < 2 || len(data)%2 != 0 {
+		return nil, false
+	}
+	frame := wire.DecodeBytes(data)
+	sum := frame[len(frame)-1]
+	frame = frame[:len(frame)-1]
+	return frame, crc8(frame) == sum
+}
+
+// crc8 computes the CRC-8 (polynomial 0x07) of data.
+func crc8(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,181 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sync"
+)
+
+// FramedConn is a connection that carries the Firmata stream in
+// delimited frames, as used by SLIP and COBS. A lost or corrupted frame
+// is discarded and the reader resynchronizes at the next delimiter.
+type FramedConn struct {
+	conn   io.ReadWriteCloser
+	r      *bufio.Reader
+	delim  byte
+	encode func(p []byte) []byte
+	decode func(frame []byte) ([]byte, bool)
+	buf    bytes.Buffer
+
+	mu      sync.Mutex
+	dropped int
+}
+
+func (c *FramedConn) Read(p []byte) (int, error) {
+	for c.buf.Len() == 0 {
+		frame, err := c.r.ReadBytes(c.delim)
+		if err != nil {
+			return 0, err
+		}
+		frame = frame[:len(frame)-1]
+		if len(frame) == 0 {
+			continue
+		}
+		data, ok := c.decode(frame)
+		if !ok {
+			c.mu.Lock()
+			c.dropped++
+			c.mu.Unlock()
+			continue
+		}
+		c.buf.Write(data)
+	}
+	return c.buf.Read(p)
+}
+
+// Write sends p as a single frame.
+func (c *FramedConn) Write(p []byte) (int, error) {
+	if _, err := c.conn.Write(c.encode(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *FramedConn) Close() error {
+	return c.conn.Close()
+}
+
+// Dropped returns the number of frames discarded because they could
+// not be decoded.
+func (c *FramedConn) Dropped() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped
+}
+
+const (
+	slipEnd    = 0xC0
+	slipEsc    = 0xDB
+	slipEscEnd = 0xDC
+	slipEscEsc = 0xDD
+)
+
+// SLIP wraps conn with SLIP (RFC 1055) framing.
+func SLIP(conn io.ReadWriteCloser) *FramedConn {
+	return &FramedConn{
+		conn:   conn,
+		r:      bufio.NewReader(conn),
+		delim:  slipEnd,
+		encode: slipEncode,
+		decode: slipDecode,
+	}
+}
+
+func slipEncode(p []byte) []byte {
+	b := make([]byte, 0, len(p)+2)
+	b = append(b, slipEnd)
+	for _, v := range p {
+		switch v {
+		case slipEnd:
+			b = append(b, slipEsc, slipEscEnd)
+		case slipEsc:
+			b = append(b, slipEsc, slipEscEsc)
+		default:
+			b = append(b, v)
+		}
+	}
+	return append(b, slipEnd)
+}
+
+func slipDecode(frame []byte) ([]byte, bool) {
+	data := make([]byte, 0, len(frame))
+	for i := 0; i < len(frame); i++ {
+		if frame[i] != slipEsc {
+			data = append(data, frame[i])
+			continue
+		}
+		if i++; i == len(frame) {
+			return nil, false
+		}
+		switch frame[i] {
+		case slipEscEnd:
+			data = append(data, slipEnd)
+		case slipEscEsc:
+			data = append(data, slipEsc)
+		default:
+			return nil, false
+		}
+	}
+	return data, true
+}
+
+// COBS wraps conn with Consistent Overhead Byte Stuffing framing. Frames
+// are delimited by zero bytes.
+func COBS(conn io.ReadWriteCloser) *FramedConn {
+	return &FramedConn{
+		conn:   conn,
+		r:      bufio.NewReader(conn),
+		delim:  0x00,
+		encode: cobsEncode,
+		decode: cobsDecode,
+	}
+}
+
+func cobsEncode(p []byte) []byte {
+	b := make([]byte, 1, len(p)+len(p)/254+2)
+	code, codeAt := byte(1), 0
+	for _, v := range p {
+		if v != 0 {
+			b = append(b, v)
+			code++
+		}
+		if v == 0 || code == 0xFF {
+			b[codeAt] = code
+			code, codeAt = 1, len(b)
+			b = append(b, 0)
+		}
+	}
+	b[codeAt] = code
+	return append(b, 0x00)
+}
+
+func cobsDecode(frame []byte) ([]byte, bool) {
+	data := make([]byte, 0, len(frame))
+	for i := 0; i < len(frame); {
+		code := int(frame[i])
+		if code == 0 || i+code > len(frame) {
+			return nil, false
+		}
+		data = append(data, frame[i+1:i+code]...)
+		i += code
+		if code < 0xFF && i < len(frame) {
+			data = append(data, 0)
+		}
+	}
+	return data, true
+}
@@ -0,0 +1,198 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build js && wasm
+
+package transport
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"syscall/js"
+)
+
+// jsConn is a connection whose incoming bytes are pushed by JavaScript
+// callbacks.
+type jsConn struct {
+	mu      sync.Mutex
+	buf     []byte
+	err     error
+	ready   chan struct{} // signaled when buf or err changes
+	write   func(p []byte) error
+	close   func()
+	release []js.Func
+	once    sync.Once
+}
+
+func newJSConn() *jsConn {
+	return &jsConn{ready: make(chan struct{}, 1)}
+}
+
+func (c *jsConn) push(p []byte, err error) {
+	c.mu.Lock()
+	c.buf = append(c.buf, p...)
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+	c.mu.Unlock()
+	select {
+	case c.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (c *jsConn) Read(p []byte) (int, error) {
+	for {
+		c.mu.Lock()
+		if len(c.buf) > 0 {
+			n := copy(p, c.buf)
+			c.buf = c.buf[n:]
+			c.mu.Unlock()
+			return n, nil
+		}
+		err := c.err
+		c.mu.Unlock()
+		if err != nil {
+			return 0, err
+		}
+		<-c.ready
+	}
+}
+
+func (c *jsConn) Write(p []byte) (int, error) {
+	if err := c.write(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *jsConn) Close() error {
+	c.close()
+	c.push(nil, io.EOF)
+	return nil
+}
+
+// releaseFuncs releases the callbacks. Closing is asynchronous in
+// JavaScript, so it is called by the last callback of the connection
+// rather than by Close.
+func (c *jsConn) releaseFuncs() {
+	c.once.Do(func() {
+		for _, f := range c.release {
+			f.Release()
+		}
+	})
+}
+
+func (c *jsConn) funcOf(fn func(args []js.Value)) js.Func {
+	f := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		fn(args)
+		return nil
+	})
+	c.release = append(c.release, f)
+	return f
+}
+
+func bytesFromJS(v js.Value) []byte {
+	arr := js.Global().Get("Uint8Array").New(v)
+	p := make([]byte, arr.Get("length").Int())
+	js.CopyBytesToGo(p, arr)
+	return p
+}
+
+func bytesToJS(p []byte) js.Value {
+	arr := js.Global().Get("Uint8Array").New(len(p))
+	js.CopyBytesToJS(arr, p)
+	return arr
+}
+
+// WebSocket connects to url from a browser, where the Firmata stream is
+// carried in binary WebSocket messages, for instance by a WebSocket to
+// TCP proxy in front of firmata-relay. It blocks until the socket is
+// open and must not be called from a JavaScript callback.
+func WebSocket(url string) (io.ReadWriteCloser, error) {
+	ws := js.Global().Get("WebSocket").New(url)
+	ws.Set("binaryType", "arraybuffer")
+
+	c := newJSConn()
+	opened := make(chan error, 1)
+	ws.Set("onopen", c.funcOf(func([]js.Value) { opened <- nil }))
+	ws.Set("onerror", c.funcOf(func([]js.Value) {
+		err := errors.New("transport: websocket error")
+		select {
+		case opened <- err:
+		default:
+		}
+		c.push(nil, err)
+	}))
+	ws.Set("onclose", c.funcOf(func([]js.Value) {
+		c.push(nil, io.EOF)
+		c.releaseFuncs()
+	}))
+	ws.Set("onmessage", c.funcOf(func(args []js.Value) {
+		c.push(bytesFromJS(args[0].Get("data")), nil)
+	}))
+	c.write = func(p []byte) error {
+		ws.Call("send", bytesToJS(p))
+		return nil
+	}
+	c.close = func() { ws.Call("close") }
+
+	if err := <-opened; err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// WebSerial returns a connection over port, a Web Serial API SerialPort
+// the page has already opened. It must not be called from a JavaScript
+// callback.
+func WebSerial(port js.Value) (io.ReadWriteCloser, error) {
+	reader := port.Get("readable").Call("getReader")
+	writer := port.Get("writable").Call("getWriter")
+
+	c := newJSConn()
+	failed := c.funcOf(func(args []js.Value) {
+		msg := "transport: web serial read failed"
+		if len(args) > 0 {
+			msg += ": " + args[0].Call("toString").String()
+		}
+		c.push(nil, errors.New(msg))
+		c.releaseFuncs()
+	})
+	var read js.Func
+	read = c.funcOf(func(args []js.Value) {
+		res := args[0]
+		if res.Get("done").Bool() {
+			c.push(nil, io.EOF)
+			c.releaseFuncs()
+			return
+		}
+		c.push(bytesFromJS(res.Get("value")), nil)
+		reader.Call("read").Call("then", read, failed)
+	})
+	reader.Call("read").Call("then", read, failed)
+
+	c.write = func(p []byte) error {
+		writer.Call("write", bytesToJS(p))
+		return nil
+	}
+	c.close = func() {
+		reader.Call("cancel")
+		reader.Call("releaseLock")
+		writer.Call("releaseLock")
+	}
+	return c, nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sync"
+)
+
+// RS485 shares a multi-drop RS-485 serial line between boards running
+// the addressing shim from contrib/RS485. Each frame on the line is
+// COBS encoded and holds the address of the board, the Firmata bytes
+// and a CRC-8 of both, so boards only act on frames sent to them and
+// corrupted frames are dropped. The line is half-duplex: the adapter
+// must switch direction by itself, as most USB adapters do.
+type RS485 struct {
+	conn io.ReadWriteCloser
+
+	wmu sync.Mutex // one talker on the line at a time
+
+	mu      sync.Mutex
+	drops   map[byte]*Drop
+	dropped int
+	err     error
+}
+
+// NewRS485 starts sharing the line conn.
+func NewRS485(conn io.ReadWriteCloser) *RS485 {
+	b := &RS485{conn: conn, drops: make(map[byte]*Drop)}
+	go b.read()
+	return b
+}
+
+// Drop is the connection to one board on an RS485 line. It can be
+// passed to firmata.NewClientConn.
+type Drop struct {
+	bus  *RS485
+	addr byte
+	r    *io.PipeReader
+	w    *io.PipeWriter
+}
+
+// Board returns the connection to the board at addr. It fails if the
+// address is in use or the line is closed.
+func (b *RS485) Board(addr byte) (*Drop, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return nil, b.err
+	}
+	if b.drops[addr] != nil {
+		return nil, errors.New("transport: rs485 address in use")
+	}
+	r, w := io.Pipe()
+	d := &Drop{bus: b, addr: addr, r: r, w: w}
+	b.drops[addr] = d
+	return d, nil
+}
+
+func (d *Drop) Read(p []byte) (int, error) {
+	return d.r.Read(p)
+}
+
+// Write sends p to the board as a single frame.
+func (d *Drop) Write(p []byte) (int, error) {
+	frame := make([]byte, 0, len(p)+2)
+	frame = append(frame, d.addr)
+	frame = append(frame, p...)
+	frame = append(frame, crc8(frame))
+
+	d.bus.wmu.Lock()
+	defer d.bus.wmu.Unlock()
+	if _, err := d.bus.conn.Write(cobsEncode(frame)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close detaches the board from the line, which stays open.
+func (d *Drop) Close() error {
+	d.bus.mu.Lock()
+	if d.bus.drops[d.addr] == d {
+		delete(d.bus.drops, d.addr)
+	}
+	d.bus.mu.Unlock()
+	d.r.Close()
+	return d.w.Close()
+}
+
+// Dropped returns the number of frames discarded because they were
+// corrupted or sent by an unknown board.
+func (b *RS485) Dropped() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
+
+// Close closes the line and the connections to all boards.
+func (b *RS485) Close() error {
+	err := b.conn.Close()
+	b.fail(errors.New("transport: rs485 line closed"))
+	return err
+}
+
+func (b *RS485) fail(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err == nil {
+		b.err = err
+	}
+	for addr, d := range b.drops {
+		d.w.CloseWithError(err)
+		delete(b.drops, addr)
+	}
+}
+
+// read dispatches the frames on the line to the boards.
+func (b *RS485) read() {
+	r := bufio.NewReader(b.conn)
+	for {
+		frame, err := r.ReadBytes(0x00)
+		if err != nil {
+			b.fail(err)
+			return
+		}
+		frame = frame[:len(frame)-1]
+		if len(frame) == 0 {
+			continue
+		}
+		data, ok := cobsDecode(frame)
+		if ok && (len(data) < 2 || crc8(data[:len(data)-1]) != data[len(data)-1]) {
+			ok = false
+		}
+		var d *Drop
+		b.mu.Lock()
+		if ok {
+			d = b.drops[data[0]]
+		}
+		if d == nil {
+			b.dropped++
+		}
+		b.mu.Unlock()
+		if d != nil {
+			d.w.Write(data[1 : len(data)-1])
+		}
+	}
+}
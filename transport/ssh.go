@@ -0,0 +1,78 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// CmdConn is a connection to the standard input and output of a
+// running command.
+type CmdConn struct {
+	cmd *exec.Cmd
+	io.Reader
+	io.WriteCloser
+}
+
+// Command starts the named program and returns a connection to its
+// standard input and output.
+func Command(name string, arg ...string) (*CmdConn, error) {
+	cmd := exec.Command(name, arg...)
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	r, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &CmdConn{cmd: cmd, Reader: r, WriteCloser: w}, nil
+}
+
+// Close closes the standard input of the command and stops it.
+func (c *CmdConn) Close() error {
+	err := c.WriteCloser.Close()
+	c.cmd.Process.Kill()
+	c.cmd.Wait()
+	return err
+}
+
+// SSH logs into host with the system ssh client and attaches to the
+// serial device dev on the remote machine, so a board plugged into a
+// remote computer can be used as if it was local. host may be any
+// destination ssh accepts, such as "pi@raspberrypi.local". socat must
+// be installed on the remote machine.
+func SSH(host, dev string, baud int) (*CmdConn, error) {
+	if host == "" || strings.HasPrefix(host, "-") {
+		return nil, fmt.Errorf("transport: invalid ssh host %q", host)
+	}
+	if strings.ContainsAny(dev, ",!") {
+		// socat would read them as address options.
+		return nil, fmt.Errorf("transport: invalid device %q", dev)
+	}
+	remote := "socat - " + shellQuote(fmt.Sprintf("FILE:%s,b%d,raw,echo=0", dev, baud))
+	return Command("ssh", "-T", "-o", "BatchMode=yes", "--", host, remote)
+}
+
+// shellQuote quotes s for a POSIX shell, which runs the remote command.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,94 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rakyll/go-firmata/wire"
+)
+
+// Uptime is the SysEx command of the uptime extension found in
+// contrib/Uptime. The board replies to an empty Uptime message with
+// millis() as five 7-bit bytes, LSB first.
+const Uptime SysExCommand = 0x0A
+
+type uptimeReply struct {
+	uptime time.Duration
+	at     time.Time
+}
+
+// QueryUptime asks the board for the time elapsed since it started. It
+// requires the Uptime firmware extension.
+func (c *Client) QueryUptime(ctx context.Context) (time.Duration, error) {
+	v, err := c.query(ctx, queryKey{cmd: Uptime}, func() error {
+		return c.sendSysEx(Uptime)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.(uptimeReply).uptime, nil
+}
+
+func (c *Client) parseUptime(data []byte) {
+	if len(data) < 5 {
+		return
+	}
+	ms := int64(wire.DecodeUint(data[:5]))
+	c.pending.resolve(queryKey{cmd: Uptime}, uptimeReply{time.Duration(ms) * time.Millisecond, c.clock.Now()})
+}
+
+// TimeSync relates the board clock to the client clock.
+type TimeSync struct {
+	// Boot is the client time at which the board uptime was zero.
+	Boot time.Time
+
+	// RTT is the round trip time of the query Boot was estimated from.
+	// The estimate is off by at most half of it.
+	RTT time.Duration
+}
+
+// HostTime converts a board uptime, such as a timestamp taken by the
+// firmware, to client time.
+func (s TimeSync) HostTime(uptime time.Duration) time.Time {
+	return s.Boot.Add(uptime)
+}
+
+// SyncTime estimates the offset between the board and client clocks
+// from n uptime queries, assuming the reply is taken half way through
+// the round trip. The query with the shortest round trip wins.
+func (c *Client) SyncTime(ctx context.Context, n int) (TimeSync, error) {
+	if n < 1 {
+		return TimeSync{}, errors.New("firmata: at least one sample is needed")
+	}
+	var best TimeSync
+	for i := 0; i < n; i++ {
+		sent := c.clock.Now()
+		v, err := c.query(ctx, queryKey{cmd: Uptime}, func() error {
+			return c.sendSysEx(Uptime)
+		})
+		if err != nil {
+			return TimeSync{}, err
+		}
+		r := v.(uptimeReply)
+		rtt := r.at.Sub(sent)
+		if i == 0 || rtt < best.RTT {
+			best = TimeSync{Boot: sent.Add(rtt / 2).Add(-r.uptime), RTT: rtt}
+		}
+	}
+	return best, nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package firmata
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// usbSerial returns the serial number of the USB device behind the tty
+// dev, read from sysfs.
+func usbSerial(dev string) (string, error) {
+	path, err := filepath.EvalSymlinks(dev)
+	if err != nil {
+		return "", err
+	}
+	iface, err := filepath.EvalSymlinks(filepath.Join("/sys/class/tty", filepath.Base(path), "device"))
+	if err != nil {
+		return "", err
+	}
+	// The tty belongs to an interface of the USB device, which holds
+	// the serial number.
+	b, err := os.ReadFile(filepath.Join(filepath.Dir(iface), "serial"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// findUSBPort returns the tty of the USB device with the serial number
+// serial.
+func findUSBPort(serial string) (string, error) {
+	ttys, err := os.ReadDir("/sys/class/tty")
+	if err != nil {
+		return "", err
+	}
+	for _, tty := range ttys {
+		dev := filepath.Join("/dev", tty.Name())
+		if s, err := usbSerial(dev); err == nil && s == serial {
+			return dev, nil
+		}
+	}
+	return "", fmt.Errorf("no serial port with usb serial number %q", serial)
+}
@@ -12,26 +12,19 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package firmata
+//go:build !linux && !windows
 
-func from7Bit(b0 byte, b1 byte) byte {
-	return (b0 & 0x7F) | ((b1 & 0x7F) << 7)
-}
+package firmata
 
-func to7Bit(i byte) []byte {
-	return []byte{i & 0x7f, (i >> 7) & 0x7f}
-}
+import (
+	"errors"
+	"runtime"
+)
 
-func intto7Bit(i int) []byte {
-	return []byte{byte(i & 0x7f), byte((i >> 7) & 0x7f), byte((i >> 14) & 0x7f)}
+func usbSerial(dev string) (string, error) {
+	return "", errors.New("reading usb serial numbers is not supported on " + runtime.GOOS)
 }
 
-func multibyteString(data []byte) (str string) {
-	if len(data)%2 != 0 {
-		data = append(data, 0)
-	}
-	for i := 0; i < len(data); i = i + 2 {
-		str = str + string(from7Bit(data[i], data[i+1]))
-	}
-	return
+func findUSBPort(serial string) (string, error) {
+	return "", errors.New("finding serial ports by usb serial number is not supported on " + runtime.GOOS)
 }
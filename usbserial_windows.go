@@ -0,0 +1,108 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package firmata
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// usbEnum is the registry key listing the USB devices Windows has seen,
+// by vendor and product id and then by instance id, which is the serial
+// number of devices that have one.
+const usbEnum = `SYSTEM\CurrentControlSet\Enum\USB`
+
+// usbSerial returns the serial number of the USB device behind the COM
+// port dev.
+func usbSerial(dev string) (string, error) {
+	var serial string
+	err := walkUSBPorts(func(instance, port string) bool {
+		if strings.EqualFold(port, strings.TrimPrefix(dev, `\\.\`)) {
+			serial = instance
+			return true
+		}
+		return false
+	})
+	if err != nil {
+		return "", err
+	}
+	if serial == "" {
+		return "", fmt.Errorf("%v is not a usb serial port", dev)
+	}
+	return serial, nil
+}
+
+// findUSBPort returns the COM port currently assigned to the USB device
+// with the serial number serial.
+func findUSBPort(serial string) (string, error) {
+	var port string
+	err := walkUSBPorts(func(instance, p string) bool {
+		if instance == serial {
+			port = p
+			return true
+		}
+		return false
+	})
+	if err != nil {
+		return "", err
+	}
+	if port == "" {
+		return "", fmt.Errorf("no serial port with usb serial number %q", serial)
+	}
+	return port, nil
+}
+
+// walkUSBPorts calls fn with the instance id and the COM port of the
+// USB serial devices until fn returns true.
+func walkUSBPorts(fn func(instance, port string) bool) error {
+	enum, err := registry.OpenKey(registry.LOCAL_MACHINE, usbEnum, registry.ENUMERATE_SUB_KEYS)
+	if err != nil {
+		return err
+	}
+	defer enum.Close()
+	ids, err := enum.ReadSubKeyNames(-1)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if strings.Contains(id, "&MI_") {
+			// Interfaces of composite devices have generated instance
+			// ids, not serial numbers.
+			continue
+		}
+		dev, err := registry.OpenKey(enum, id, registry.ENUMERATE_SUB_KEYS)
+		if err != nil {
+			continue
+		}
+		instances, _ := dev.ReadSubKeyNames(-1)
+		dev.Close()
+		for _, instance := range instances {
+			params, err := registry.OpenKey(enum, id+`\`+instance+`\Device Parameters`, registry.QUERY_VALUE)
+			if err != nil {
+				continue
+			}
+			port, _, err := params.GetStringValue("PortName")
+			params.Close()
+			if err == nil && fn(instance, port) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AnyPin in a PinRequirement asks for a mode supported by some pin.
+const AnyPin = -1
+
+// PinRequirement is a pin and mode a program needs, as in
+// {Pin: 9, Mode: PWM, Name: "LED"}.
+type PinRequirement struct {
+	Pin  int // or AnyPin
+	Mode PinMode
+
+	// Resolution is the minimum resolution in bits the pin must have
+	// in Mode, if non-zero.
+	Resolution int
+
+	// Name describes the use of the pin in the report.
+	Name string
+}
+
+// ValidationError lists the requirements the board doesn't meet.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "board doesn't meet the requirements of the program:\n\t" + strings.Join(e.Problems, "\n\t")
+}
+
+// Validate checks the requirements of a program against the pins of the
+// board, so that kits can report a wiring or firmware mismatch, such
+// as "pin 9 lacks PWM", before the program runs. It returns a
+// *ValidationError listing every mismatch.
+func (c *Client) Validate(program []PinRequirement) error {
+	var problems []string
+	modes := make(map[int]PinRequirement)
+	for _, r := range program {
+		use := ""
+		if r.Name != "" {
+			use = " for " + r.Name
+		}
+		if r.Pin == AnyPin {
+			if !c.anyPinSupports(r.Mode, r.Resolution) {
+				problems = append(problems, fmt.Sprintf("no pin supports %v%s", r.Mode, use))
+			}
+			continue
+		}
+		if c.checkPin(r.Pin) != nil {
+			problems = append(problems, fmt.Sprintf("pin %d doesn't exist%s", r.Pin, use))
+			continue
+		}
+		if prev, ok := modes[r.Pin]; ok && prev.Mode != r.Mode {
+			problems = append(problems, fmt.Sprintf("pin %d is needed as both %v and %v", r.Pin, prev.Mode, r.Mode))
+			continue
+		}
+		modes[r.Pin] = r
+		res, ok := c.board().mode(r.Pin, r.Mode).(byte)
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("pin %d lacks %v%s", r.Pin, r.Mode, use))
+		case r.Resolution > 0 && int(res) < r.Resolution:
+			problems = append(problems, fmt.Sprintf("pin %d has %d-bit %v, %d bits are needed%s", r.Pin, res, r.Mode, r.Resolution, use))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: problems}
+}
+
+func (c *Client) anyPinSupports(mode PinMode, resolution int) bool {
+	for _, pm := range c.board().modes {
+		if res, ok := pm[mode].(byte); ok && int(res) >= resolution {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+// To7Bit splits b into its 7-bit LSB and MSB halves.
+func To7Bit(b byte) []byte {
+	return []byte{b & 0x7f, (b >> 7) & 0x7f}
+}
+
+// From7Bit joins a 7-bit LSB and MSB pair into a 14-bit value.
+func From7Bit(lsb, msb byte) uint16 {
+	return uint16(lsb&0x7F) | uint16(msb&0x7F)<<7
+}
+
+// IntTo7Bit splits the low 21 bits of i into three 7-bit bytes, LSB first.
+func IntTo7Bit(i int) []byte {
+	return EncodeUint(uint64(i), 3)
+}
+
+// EncodeUint splits the low 7*n bits of v into n 7-bit bytes, LSB first.
+// If n is 0, v is encoded in as few bytes as it needs, at least one, as
+// in extended analog messages.
+func EncodeUint(v uint64, n int) []byte {
+	if n > 0 {
+		out := make([]byte, n)
+		for i := range out {
+			out[i] = byte(v & 0x7F)
+			v >>= 7
+		}
+		return out
+	}
+	out := []byte{byte(v & 0x7F)}
+	for v >>= 7; v != 0; v >>= 7 {
+		out = append(out, byte(v&0x7F))
+	}
+	return out
+}
+
+// DecodeUint joins 7-bit bytes, LSB first, into a value. Bytes beyond
+// the 64 bits of the result are ignored.
+func DecodeUint(data []byte) uint64 {
+	var v uint64
+	for i, b := range data {
+		if i*7 >= 64 {
+			break
+		}
+		v |= uint64(b&0x7F) << uint(7*i)
+	}
+	return v
+}
+
+// EncodeBytes splits every byte of data into its 7-bit LSB and MSB, the
+// encoding of I2C, SPI and serial data.
+func EncodeBytes(data []byte) []byte {
+	out := make([]byte, 0, 2*len(data))
+	for _, b := range data {
+		out = append(out, b&0x7F, b>>7)
+	}
+	return out
+}
+
+// DecodeBytes reverses EncodeBytes. A trailing odd byte is ignored.
+func DecodeBytes(data []byte) []byte {
+	out := make([]byte, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		out = append(out, data[i]&0x7F|data[i+1]<<7)
+	}
+	return out
+}
+
+// MultibyteString decodes a string sent as 7-bit LSB/MSB pairs.
+func MultibyteString(data []byte) (str string) {
+	if len(data)%2 != 0 {
+		data = append(data, 0)
+	}
+	for i := 0; i < len(data); i = i + 2 {
+		str = str + string(rune(From7Bit(data[i], data[i+1])))
+	}
+	return
+}
+
+// Encode7BitStream packs the bits of data into 7-bit bytes, the
+// encoding of binary SysEx payloads such as scheduler tasks.
+func Encode7BitStream(data []byte) []byte {
+	out := make([]byte, 0, (len(data)*8+6)/7)
+	var shift uint
+	var prev byte
+	for _, b := range data {
+		if shift == 0 {
+			out = append(out, b&0x7F)
+			shift = 1
+			prev = b >> 7
+			continue
+		}
+		out = append(out, (b<<shift)&0x7F|prev)
+		if shift == 6 {
+			out = append(out, b>>1)
+			shift = 0
+		} else {
+			shift++
+			prev = b >> (8 - shift)
+		}
+	}
+	if shift > 0 {
+		out = append(out, prev)
+	}
+	return out
+}
+
+// Decode7BitStream reverses Encode7BitStream.
+func Decode7BitStream(data []byte) []byte {
+	out := make([]byte, len(data)*7/8)
+	for i := range out {
+		j := i * 8
+		pos, shift := j/7, uint(j%7)
+		b := data[pos] >> shift
+		if pos+1 < len(data) {
+			b |= data[pos+1] << (7 - shift)
+		}
+		out[i] = b
+	}
+	return out
+}
@@ -0,0 +1,187 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestEncodeUint(t *testing.T) {
+	tests := []struct {
+		v    uint64
+		n    int
+		want []byte
+	}{
+		{0, 0, []byte{0x00}},
+		{0x7F, 0, []byte{0x7F}},
+		{0x80, 0, []byte{0x00, 0x01}},
+		{0x3FFF, 0, []byte{0x7F, 0x7F}},
+		{0x4000, 0, []byte{0x00, 0x00, 0x01}},
+		{math.MaxUint64, 0, []byte{0x7F, 0x7F, 0x7F, 0x7F, 0x7F, 0x7F, 0x7F, 0x7F, 0x7F, 0x01}},
+		{0, 3, []byte{0x00, 0x00, 0x00}},
+		{57600, 3, []byte{0x00, 0x42, 0x03}},
+		{1<<21 - 1, 3, []byte{0x7F, 0x7F, 0x7F}},
+		{1 << 21, 3, []byte{0x00, 0x00, 0x00}}, // truncated to 21 bits
+		{0x1234, 1, []byte{0x34}},
+	}
+	for _, tt := range tests {
+		if got := EncodeUint(tt.v, tt.n); !bytes.Equal(got, tt.want) {
+			t.Errorf("EncodeUint(%#x, %d) = %#v; want %#v", tt.v, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeUint(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want uint64
+	}{
+		{nil, 0},
+		{[]byte{}, 0},
+		{[]byte{0x7F}, 0x7F},
+		{[]byte{0x00, 0x01}, 0x80},
+		{[]byte{0xFF, 0x80}, 0x7F}, // high bits of each byte are ignored
+		{[]byte{0x00, 0x42, 0x03}, 57600},
+		{[]byte{0x7F, 0x7F, 0x7F, 0x7F, 0x7F, 0x7F, 0x7F, 0x7F, 0x7F, 0x01}, math.MaxUint64},
+		{[]byte{0x01, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x7F}, 1}, // beyond 64 bits
+	}
+	for _, tt := range tests {
+		if got := DecodeUint(tt.data); got != tt.want {
+			t.Errorf("DecodeUint(%#v) = %#x; want %#x", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestUintRoundTrip(t *testing.T) {
+	for _, v := range []uint64{0, 1, 0x7F, 0x80, 0x3FFF, 0x4000, 1<<32 - 1, 1 << 63, math.MaxUint64} {
+		if got := DecodeUint(EncodeUint(v, 0)); got != v {
+			t.Errorf("DecodeUint(EncodeUint(%#x, 0)) = %#x", v, got)
+		}
+	}
+}
+
+func TestTo7Bit(t *testing.T) {
+	tests := []struct {
+		b    byte
+		want []byte
+	}{
+		{0x00, []byte{0x00, 0x00}},
+		{0x7F, []byte{0x7F, 0x00}},
+		{0x80, []byte{0x00, 0x01}},
+		{0xFF, []byte{0x7F, 0x01}},
+	}
+	for _, tt := range tests {
+		got := To7Bit(tt.b)
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("To7Bit(%#x) = %#v; want %#v", tt.b, got, tt.want)
+		}
+		if v := From7Bit(got[0], got[1]); v != uint16(tt.b) {
+			t.Errorf("From7Bit(%#x, %#x) = %#x; want %#x", got[0], got[1], v, tt.b)
+		}
+	}
+	if v := From7Bit(0xFF, 0xFF); v != 0x3FFF {
+		t.Errorf("From7Bit(0xff, 0xff) = %#x; want 0x3fff", v)
+	}
+}
+
+func TestEncodeBytes(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want []byte
+	}{
+		{nil, []byte{}},
+		{[]byte{0x00}, []byte{0x00, 0x00}},
+		{[]byte{0x7F, 0x80, 0xFF}, []byte{0x7F, 0x00, 0x00, 0x01, 0x7F, 0x01}},
+	}
+	for _, tt := range tests {
+		got := EncodeBytes(tt.data)
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("EncodeBytes(%#v) = %#v; want %#v", tt.data, got, tt.want)
+		}
+		if back := DecodeBytes(got); !bytes.Equal(back, tt.data) {
+			t.Errorf("DecodeBytes(%#v) = %#v; want %#v", got, back, tt.data)
+		}
+	}
+	if got := DecodeBytes([]byte{0x01, 0x00, 0x05}); !bytes.Equal(got, []byte{0x01}) {
+		t.Errorf("DecodeBytes with an odd length = %#v; want the trailing byte ignored", got)
+	}
+}
+
+func TestEncode7BitStream(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want []byte
+	}{
+		{nil, []byte{}},
+		{[]byte{0x00}, []byte{0x00, 0x00}},
+		{[]byte{0xFF}, []byte{0x7F, 0x01}},
+		{[]byte{0xFF, 0xFF}, []byte{0x7F, 0x7F, 0x03}},
+		{
+			[]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+			[]byte{0x7F, 0x7F, 0x7F, 0x7F, 0x7F, 0x7F, 0x7F, 0x7F},
+		},
+		{[]byte{0x01, 0x02, 0x03}, []byte{0x01, 0x04, 0x0C, 0x00}},
+	}
+	for _, tt := range tests {
+		if got := Encode7BitStream(tt.data); !bytes.Equal(got, tt.want) {
+			t.Errorf("Encode7BitStream(%#v) = %#v; want %#v", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestEncode7BitStreamRoundTrip(t *testing.T) {
+	for n := 0; n <= 30; n++ {
+		for _, fill := range []func(i int) byte{
+			func(int) byte { return 0x00 },
+			func(int) byte { return 0xFF },
+			func(i int) byte { return byte(i*37 + 11) },
+		} {
+			data := make([]byte, n)
+			for i := range data {
+				data[i] = fill(i)
+			}
+			enc := Encode7BitStream(data)
+			if want := (n*8 + 6) / 7; len(enc) != want {
+				t.Errorf("len(Encode7BitStream(%d bytes)) = %d; want %d", n, len(enc), want)
+			}
+			for _, b := range enc {
+				if b > 0x7F {
+					t.Fatalf("Encode7BitStream(%#v) = %#v; has a byte above 0x7f", data, enc)
+				}
+			}
+			if got := Decode7BitStream(enc); !bytes.Equal(got, data) {
+				t.Errorf("Decode7BitStream(Encode7BitStream(%#v)) = %#v", data, got)
+			}
+		}
+	}
+}
+
+func TestMultibyteString(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want string
+	}{
+		{nil, ""},
+		{[]byte{'o', 0, 'k', 0}, "ok"},
+		{[]byte{'o', 0, 'k'}, "ok"}, // missing MSB of the last character
+	}
+	for _, tt := range tests {
+		if got := MultibyteString(tt.data); got != tt.want {
+			t.Errorf("MultibyteString(%#v) = %q; want %q", tt.data, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import "fmt"
+
+// Message is a single Firmata protocol message.
+type Message interface {
+	// Bytes returns the wire encoding of the message.
+	Bytes() []byte
+}
+
+// Version is the REPORT_VERSION message carrying the protocol version.
+type Version struct {
+	Major, Minor byte
+}
+
+func (m Version) Bytes() []byte {
+	return []byte{ReportVersion, m.Major & 0x7F, m.Minor & 0x7F}
+}
+
+// Digital carries the pin values of a digital port, one bit per pin.
+type Digital struct {
+	Port  byte
+	Value byte
+}
+
+func (m Digital) Bytes() []byte {
+	d := To7Bit(m.Value)
+	return []byte{DigitalMessage | (m.Port & 0x0F), d[0], d[1]}
+}
+
+// DigitalPin sets the value of a single digital output pin, leaving
+// the other pins of its port alone.
+type DigitalPin struct {
+	Pin   byte
+	Value bool
+}
+
+func (m DigitalPin) Bytes() []byte {
+	return []byte{SetDigitalPin, m.Pin & 0x7F, boolByte(m.Value)}
+}
+
+// Analog carries the 14-bit value of an analog channel. When sent to
+// the board, it writes a PWM or servo value to the pin.
+type Analog struct {
+	Channel byte
+	Value   uint16
+}
+
+func (m Analog) Bytes() []byte {
+	return []byte{AnalogMessage | (m.Channel & 0x0F), byte(m.Value & 0x7F), byte((m.Value >> 7) & 0x7F)}
+}
+
+// AnalogReport enables or disables reporting of an analog channel.
+type AnalogReport struct {
+	Channel byte
+	Enable  bool
+}
+
+func (m AnalogReport) Bytes() []byte {
+	return []byte{ReportAnalog | (m.Channel & 0x0F), boolByte(m.Enable)}
+}
+
+// DigitalReport enables or disables reporting of a digital port.
+type DigitalReport struct {
+	Port   byte
+	Enable bool
+}
+
+func (m DigitalReport) Bytes() []byte {
+	return []byte{ReportDigital | (m.Port & 0x0F), boolByte(m.Enable)}
+}
+
+// PinMode sets the mode of a pin.
+type PinMode struct {
+	Pin  byte
+	Mode byte
+}
+
+func (m PinMode) Bytes() []byte {
+	return []byte{SetPinMode, m.Pin & 0x7F, m.Mode & 0x7F}
+}
+
+// Reset is the SYSTEM_RESET message.
+type Reset struct{}
+
+func (m Reset) Bytes() []byte {
+	return []byte{SystemReset}
+}
+
+// SysEx is a system exclusive message. Data holds the 7-bit payload
+// between the command byte and END_SYSEX.
+type SysEx struct {
+	Command byte
+	Data    []byte
+}
+
+func (m SysEx) Bytes() []byte {
+	b := make([]byte, 0, len(m.Data)+3)
+	b = append(b, StartSysEx, m.Command)
+	b = append(b, m.Data...)
+	return append(b, EndSysEx)
+}
+
+func (m SysEx) String() string {
+	return fmt.Sprintf("SysEx(0x%02x % x)", m.Command, m.Data)
+}
+
+func boolByte(v bool) byte {
+	if v {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,231 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sync/atomic"
+)
+
+// Encoder writes Firmata messages to an output stream.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the wire encoding of m to the stream in a single write.
+func (e *Encoder) Encode(m Message) error {
+	_, err := e.w.Write(m.Bytes())
+	return err
+}
+
+// DefaultMaxSysEx is the longest SysEx message accepted unless set
+// with Limits.
+const DefaultMaxSysEx = 4096
+
+// ErrGarbage is returned by Decode when more bytes than allowed by
+// Limits.MaxSkip in a row don't belong to a message, which usually means
+// the baud rate is wrong.
+var ErrGarbage = errors.New("wire: too many invalid bytes")
+
+// Limits protects a Decoder from streams that are not valid Firmata.
+type Limits struct {
+	// MaxSysEx is the length of the longest SysEx message accepted.
+	// Longer messages are discarded. Zero means DefaultMaxSysEx.
+	MaxSysEx int
+
+	// MaxSkip is the number of bytes in a row Decode skips before it
+	// fails with ErrGarbage. Zero means no limit.
+	MaxSkip int
+}
+
+// Stats counts what decoders read. A Stats can be shared by several
+// decoders and read while they run.
+type Stats struct {
+	Messages atomic.Uint64 // messages decoded
+	Skipped  atomic.Uint64 // bytes that don't belong to a message
+
+	// Overflows counts the SysEx messages longer than the limit and
+	// Truncated the ones interrupted by another command.
+	Overflows atomic.Uint64
+	Truncated atomic.Uint64
+}
+
+// Decoder reads and decodes Firmata messages from an input stream.
+type Decoder struct {
+	r      *bufio.Reader
+	buf    [2]byte
+	limits Limits
+	stats  *Stats
+	skip   int // bytes skipped in a row
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r), stats: new(Stats)}
+}
+
+// SetLimits sets the limits of the decoder.
+func (d *Decoder) SetLimits(l Limits) {
+	d.limits = l
+}
+
+// SetStats makes the decoder count into s.
+func (d *Decoder) SetStats(s *Stats) {
+	d.stats = s
+}
+
+// Stats returns the counters of the decoder.
+func (d *Decoder) Stats() *Stats {
+	return d.stats
+}
+
+// Decode reads the next message from the stream. Data bytes that
+// don't belong to a message and unknown command bytes are skipped.
+func (d *Decoder) Decode() (Message, error) {
+	m, err := d.decode()
+	if err == nil {
+		d.stats.Messages.Add(1)
+		d.skip = 0
+	}
+	return m, err
+}
+
+func (d *Decoder) decode() (Message, error) {
+	for {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case b == ReportVersion:
+			data, err := d.read(2)
+			if err != nil {
+				return nil, err
+			}
+			return Version{Major: data[0], Minor: data[1]}, nil
+		case b == SystemReset:
+			return Reset{}, nil
+		case b == SetPinMode:
+			data, err := d.read(2)
+			if err != nil {
+				return nil, err
+			}
+			return PinMode{Pin: data[0], Mode: data[1]}, nil
+		case b == SetDigitalPin:
+			data, err := d.read(2)
+			if err != nil {
+				return nil, err
+			}
+			return DigitalPin{Pin: data[0], Value: data[1] != 0}, nil
+		case b == StartSysEx:
+			data, err := d.readSysEx()
+			if err != nil {
+				return nil, err
+			}
+			if len(data) == 0 {
+				continue
+			}
+			return SysEx{Command: data[0], Data: data[1:]}, nil
+		case b&0xF0 == DigitalMessage:
+			data, err := d.read(2)
+			if err != nil {
+				return nil, err
+			}
+			return Digital{Port: b & 0x0F, Value: byte(From7Bit(data[0], data[1]))}, nil
+		case b&0xF0 == AnalogMessage:
+			data, err := d.read(2)
+			if err != nil {
+				return nil, err
+			}
+			return Analog{Channel: b & 0x0F, Value: From7Bit(data[0], data[1])}, nil
+		case b&0xF0 == ReportAnalog:
+			data, err := d.read(1)
+			if err != nil {
+				return nil, err
+			}
+			return AnalogReport{Channel: b & 0x0F, Enable: data[0] != 0}, nil
+		case b&0xF0 == ReportDigital:
+			data, err := d.read(1)
+			if err != nil {
+				return nil, err
+			}
+			return DigitalReport{Port: b & 0x0F, Enable: data[0] != 0}, nil
+		default:
+			if err := d.skipped(1); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// skipped counts n bytes that don't belong to a message.
+func (d *Decoder) skipped(n int) error {
+	d.stats.Skipped.Add(uint64(n))
+	d.skip += n
+	if d.limits.MaxSkip > 0 && d.skip > d.limits.MaxSkip {
+		d.skip = 0
+		return ErrGarbage
+	}
+	return nil
+}
+
+// readSysEx reads the body of a SysEx message up to EndSysEx. It
+// returns nil for a message that is too long or that is interrupted by
+// another command, which is left to be decoded next.
+func (d *Decoder) readSysEx() ([]byte, error) {
+	max := d.limits.MaxSysEx
+	if max <= 0 {
+		max = DefaultMaxSysEx
+	}
+	var data []byte
+	for n := 0; ; n++ {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case b == EndSysEx:
+			if n > max {
+				d.stats.Overflows.Add(1)
+				return nil, d.skipped(n + 2)
+			}
+			return data, nil
+		case b&0x80 != 0 && n > 0:
+			// The end of the message was lost. The command byte is
+			// exempt since some extensions use commands above 0x7F.
+			d.r.UnreadByte()
+			d.stats.Truncated.Add(1)
+			return nil, d.skipped(n + 1)
+		case n < max:
+			data = append(data, b)
+		}
+	}
+}
+
+// read reads n bytes, at most 2, into a buffer reused by later reads.
+func (d *Decoder) read(n int) ([]byte, error) {
+	data := d.buf[:n]
+	if _, err := io.ReadFull(d.r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
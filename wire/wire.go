@@ -0,0 +1,32 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package wire implements encoding and decoding of Firmata protocol
+// messages. It is independent of the firmata Client and can be used by
+// tools, tests and alternative transports that need to speak Firmata.
+package wire
+
+// Message command bytes (128-255/0x80-0xFF).
+const (
+	DigitalMessage = 0x90 // send data for a digital port
+	AnalogMessage  = 0xE0 // send data for an analog pin (or PWM)
+	ReportAnalog   = 0xC0 // enable analog input by pin #
+	ReportDigital  = 0xD0 // enable digital input by port pair
+	SetPinMode     = 0xF4 // set a pin to INPUT/OUTPUT/PWM/etc
+	SetDigitalPin  = 0xF5 // set the value of a single digital pin
+	ReportVersion  = 0xF9 // report protocol version
+	SystemReset    = 0xFF // reset from MIDI
+	StartSysEx     = 0xF0 // start a MIDI Sysex message
+	EndSysEx       = 0xF7 // end a MIDI Sysex message
+)
@@ -0,0 +1,84 @@
+// Copyright 2014 Krishna Raman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firmata
+
+import (
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// ErrWriteTimeout is returned when a command cannot be written within
+// the timeout set with WithWriteTimeout, usually because the board hung
+// and the OS buffer of the port is full.
+var ErrWriteTimeout = errors.New("firmata: write timed out")
+
+// WithWriteTimeout makes commands fail with ErrWriteTimeout when they
+// cannot be written within d. Transports with a SetWriteDeadline
+// method, such as network connections, abort the write; on others the
+// write goes on in the background and later commands wait for it, up
+// to d as well.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.writeTimeout = d
+	}
+}
+
+type writeDeadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// write writes b to conn, within the write timeout if there is one.
+// c.connMu must be held.
+func (c *Client) write(conn io.Writer, b []byte) (int, error) {
+	if c.writeTimeout <= 0 {
+		return conn.Write(b)
+	}
+	if dl, ok := conn.(writeDeadliner); ok {
+		if err := dl.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err == nil {
+			n, err := conn.Write(b)
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				err = ErrWriteTimeout
+			}
+			return n, err
+		}
+	}
+
+	timeout := c.clock.After(c.writeTimeout)
+	if c.stuckWrite != nil {
+		select {
+		case <-c.stuckWrite:
+			c.stuckWrite = nil
+		case <-timeout:
+			return 0, ErrWriteTimeout
+		}
+	}
+	done := make(chan struct{})
+	var n int
+	var err error
+	go func() {
+		n, err = conn.Write(b)
+		close(done)
+	}()
+	select {
+	case <-done:
+		return n, err
+	case <-timeout:
+		// Commands written meanwhile would be interleaved with b.
+		c.stuckWrite = done
+		return 0, ErrWriteTimeout
+	}
+}